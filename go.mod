@@ -1,3 +1,3 @@
 module github.com/mitranim/rout
 
-go 1.16
+go 1.20
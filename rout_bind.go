@@ -0,0 +1,204 @@
+package rout
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	r "reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Same as `Rou.ParamFunc`, but instead of a positional `[]string`, the handler
+receives a struct of type `T`, populated from captures. Exported fields of the
+struct are bound as follows:
+
+	* A field with a numeric tag such as `rout:"0"` is bound to the capture at
+	  the given index.
+
+	* A field with a non-numeric tag such as `rout:"id"` is bound to the capture
+	  group with the given name, such as "{id}" in `Pat` or "(?P<id>...)" in
+	  regexps.
+
+	* A field with the tag `rout:"-"` is ignored.
+
+	* An untagged field is bound to the capture group with the same name,
+	  case-insensitively, if any. Otherwise it's ignored.
+
+Supported field types: strings, booleans, integers, floats, and types
+implementing `encoding.TextUnmarshaler`. If a capture can't be converted to
+the field type, this panics with `ErrBadRequest`, which is normally returned by
+`Rou.Route` and written with status 400. Invalid struct definitions, such as
+tags referring to missing capture groups, cause a panic with a non-status
+error. Example:
+
+	type ArticleParams struct {
+		Id   uint64 `rout:"id"`
+		Page int    `rout:"page"`
+	}
+
+	rout.Bind(rou.Pat(`/articles/{id}/{page}`).Get(), articleGet)
+
+	func articleGet(rew http.ResponseWriter, req *http.Request, par ArticleParams) {}
+
+In "dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+*/
+func Bind[T any](rou Rou, fun func(http.ResponseWriter, *http.Request, T)) {
+	if rou.isDone() || rou.vis(fun) {
+		return
+	}
+
+	args := rou.Submatch()
+	if args == nil {
+		return
+	}
+
+	rou.done(fun)
+
+	var val T
+	try(rou.bind(r.ValueOf(&val).Elem(), args))
+
+	if fun != nil {
+		serve(&rou, bindFunc[T]{fun, val})
+	}
+}
+
+func (self *Rou) bind(tar r.Value, args []string) error {
+	if tar.Kind() != r.Struct {
+		return fmt.Errorf(`[rout] unable to bind captures to non-struct type %v`, tar.Type())
+	}
+
+	names := self.names()
+
+	for _, field := range cachedBindFields(tar.Type()) {
+		ind := field.Pos
+		if ind < 0 {
+			ind = bindIndex(names, field.Name, field.Fold)
+		}
+
+		if ind < 0 || ind >= len(args) {
+			if field.Fold {
+				continue
+			}
+			return fmt.Errorf(
+				`[rout] unable to bind field %q of %v: pattern %q has no capture %q`,
+				field.Field, tar.Type(), self.Pattern, field.Name,
+			)
+		}
+
+		err := bindText(tar.Field(field.Index), args[ind])
+		if err != nil {
+			meth, path := self.req()
+			return ErrBadRequest(Err(
+				fmt.Sprintf(`invalid path parameter %q: %v`, field.Name, err),
+				ErrBadRequest(``).HttpStatusCode(), meth, path,
+			))
+		}
+	}
+	return nil
+}
+
+type bindField struct {
+	Index int
+	Field string
+	Name  string
+	Pos   int
+	Fold  bool
+}
+
+var bindFieldCache sync.Map
+
+// Susceptible to "thundering herd" but probably good enough.
+func cachedBindFields(typ r.Type) []bindField {
+	val, ok := bindFieldCache.Load(typ)
+	if ok {
+		return val.([]bindField)
+	}
+
+	out := bindFields(typ)
+	bindFieldCache.Store(typ, out)
+	return out
+}
+
+func bindFields(typ r.Type) (out []bindField) {
+	for ind := 0; ind < typ.NumField(); ind++ {
+		field := typ.Field(ind)
+		if field.PkgPath != `` {
+			continue
+		}
+
+		tag := field.Tag.Get(`rout`)
+		if tag == `-` {
+			continue
+		}
+
+		val := bindField{Index: ind, Field: field.Name, Name: tag, Pos: -1}
+
+		if tag == `` {
+			val.Name = field.Name
+			val.Fold = true
+		} else if pos, err := strconv.Atoi(tag); err == nil && pos >= 0 {
+			val.Pos = pos
+		}
+
+		out = append(out, val)
+	}
+	return
+}
+
+func bindIndex(names []string, name string, fold bool) int {
+	for ind, val := range names {
+		if val == name || (fold && val != `` && strings.EqualFold(val, name)) {
+			return ind
+		}
+	}
+	return -1
+}
+
+func bindText(tar r.Value, src string) error {
+	if tar.CanAddr() {
+		val, ok := tar.Addr().Interface().(encoding.TextUnmarshaler)
+		if ok {
+			return val.UnmarshalText([]byte(src))
+		}
+	}
+
+	switch tar.Kind() {
+	case r.String:
+		tar.SetString(src)
+		return nil
+
+	case r.Bool:
+		val, err := strconv.ParseBool(src)
+		if err == nil {
+			tar.SetBool(val)
+		}
+		return err
+
+	case r.Int, r.Int8, r.Int16, r.Int32, r.Int64:
+		val, err := strconv.ParseInt(src, 10, tar.Type().Bits())
+		if err == nil {
+			tar.SetInt(val)
+		}
+		return err
+
+	case r.Uint, r.Uint8, r.Uint16, r.Uint32, r.Uint64, r.Uintptr:
+		val, err := strconv.ParseUint(src, 10, tar.Type().Bits())
+		if err == nil {
+			tar.SetUint(val)
+		}
+		return err
+
+	case r.Float32, r.Float64:
+		val, err := strconv.ParseFloat(src, tar.Type().Bits())
+		if err == nil {
+			tar.SetFloat(val)
+		}
+		return err
+
+	default:
+		panic(fmt.Errorf(`[rout] unable to bind path parameter to unsupported type %v`, tar.Type()))
+	}
+}
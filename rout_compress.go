@@ -0,0 +1,225 @@
+package rout
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+/*
+Interface of content codings used by `Rou.Compress`. `.Encoding` returns the
+token used in the "Accept-Encoding" and "Content-Encoding" headers, such as
+"gzip" or "br". `.Writer` returns a writer which compresses the data written to
+it into the given writer, and must flush all remaining data when closed.
+Implemented by `Gzip` and `Deflate`. Other codings, such as Brotli, may be
+supported by implementing this interface with third-party libraries.
+*/
+type Compressor interface {
+	Encoding() string
+	Writer(io.Writer) io.WriteCloser
+}
+
+/*
+Implementation of `Compressor` for the "gzip" coding. Zero level uses
+`gzip.DefaultCompression`.
+*/
+type Gzip struct{ Level int }
+
+// Implement `Compressor`.
+func (Gzip) Encoding() string { return `gzip` }
+
+// Implement `Compressor`.
+func (self Gzip) Writer(out io.Writer) io.WriteCloser {
+	val, err := gzip.NewWriterLevel(out, compressLevel(self.Level))
+	if err != nil {
+		return gzip.NewWriter(out)
+	}
+	return val
+}
+
+/*
+Implementation of `Compressor` for the "deflate" coding, which in HTTP means
+the "zlib" format. Zero level uses `zlib.DefaultCompression`.
+*/
+type Deflate struct{ Level int }
+
+// Implement `Compressor`.
+func (Deflate) Encoding() string { return `deflate` }
+
+// Implement `Compressor`.
+func (self Deflate) Writer(out io.Writer) io.WriteCloser {
+	val, err := zlib.NewWriterLevel(out, compressLevel(self.Level))
+	if err != nil {
+		return zlib.NewWriter(out)
+	}
+	return val
+}
+
+/*
+Returns a router that compresses responses of all routes declared downstream,
+including sub-routers, using the first of the given codings with the highest
+q-value in the request's "Accept-Encoding" header. Requests without this header
+are not compressed. Applies to all handler types, including `Rou.Func`,
+`Rou.Han`, and `Rou.Res`. A response is compressed only if its status allows a
+body, it doesn't already have "Content-Encoding", and its "Content-Type",
+detected from the body if missing, is compressible: "text/*", JavaScript,
+JSON, XML, SVG, and types with the suffixes "+json" and "+xml". When
+compressing, "Content-Length" is removed, and a strong "ETag" becomes weak.
+"Vary: Accept-Encoding" is always added, because the response depends on this
+header even when not compressed. HEAD requests are not compressed. Calling
+with no codings disables compression. Example:
+
+	rou.Sta(`/api`).Compress(rout.Gzip{}, rout.Deflate{}).Sub(routesApi)
+*/
+func (self Rou) Compress(vals ...Compressor) Rou {
+	self.setConf().Compressors = vals
+	return self
+}
+
+func (self *Rou) compressor() Compressor {
+	head := reqHeader(self.Req).Values(`Accept-Encoding`)
+	if headEmpty(head) {
+		return nil
+	}
+
+	var out Compressor
+	var best float64
+	for _, val := range self.conf().Compressors {
+		if val == nil {
+			continue
+		}
+		cur := acceptQ(head, val.Encoding())
+		if cur > best {
+			out, best = val, cur
+		}
+	}
+	return out
+}
+
+/*
+Response writer used by `Rou.Compress`. Decides whether to compress when the
+headers are written, either explicitly or by the first write.
+*/
+type compressWriter struct {
+	Rew     http.ResponseWriter
+	Comp    Compressor
+	Out     io.WriteCloser
+	Decided bool
+}
+
+func (self *compressWriter) Header() http.Header { return self.Rew.Header() }
+
+func (self *compressWriter) WriteHeader(status int) {
+	if !self.Decided && !isInfoStatus(status) {
+		self.decide(status, nil)
+	}
+	self.Rew.WriteHeader(status)
+}
+
+func (self *compressWriter) Write(val []byte) (int, error) {
+	if !self.Decided {
+		self.decide(http.StatusOK, val)
+	}
+	if self.Out != nil {
+		return self.Out.Write(val)
+	}
+	return self.Rew.Write(val)
+}
+
+func (self *compressWriter) Flush() {
+	if !self.Decided {
+		self.decide(http.StatusOK, nil)
+	}
+	val, ok := self.Out.(interface{ Flush() error })
+	if ok {
+		_ = val.Flush()
+	}
+	flush(self.Rew)
+}
+
+// Allows `http.ResponseController` to reach the underlying writer.
+func (self *compressWriter) Unwrap() http.ResponseWriter { return self.Rew }
+
+// Flushes the remaining compressed data. Must be called after serving.
+func (self *compressWriter) Close() {
+	if self.Out != nil {
+		_ = self.Out.Close()
+	}
+}
+
+func (self *compressWriter) decide(status int, body []byte) {
+	self.Decided = true
+
+	head := self.Rew.Header()
+	if !bodyAllowed(status) || head.Get(`Content-Encoding`) != `` {
+		return
+	}
+
+	typ := head.Get(`Content-Type`)
+	if typ == `` && len(body) > 0 {
+		typ = http.DetectContentType(body)
+		head.Set(`Content-Type`, typ)
+	}
+	if !compressible(typ) {
+		return
+	}
+
+	head.Del(`Content-Length`)
+	head.Set(`Content-Encoding`, self.Comp.Encoding())
+	tag := head.Get(`Etag`)
+	if tag != `` && !strings.HasPrefix(tag, `W/`) {
+		head.Set(`Etag`, `W/`+tag)
+	}
+	self.Out = self.Comp.Writer(self.Rew)
+}
+
+func compressLevel(val int) int {
+	if val == 0 {
+		return gzip.DefaultCompression
+	}
+	return val
+}
+
+func compressible(val string) bool {
+	typ, _, err := mime.ParseMediaType(val)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(typ, `text/`) ||
+		strings.HasSuffix(typ, `+json`) ||
+		strings.HasSuffix(typ, `+xml`) {
+		return true
+	}
+
+	switch typ {
+	case `application/json`,
+		`application/javascript`,
+		`application/x-javascript`,
+		`application/xml`:
+		return true
+	}
+	return false
+}
+
+func bodyAllowed(status int) bool {
+	return !isInfoStatus(status) &&
+		status != http.StatusNoContent &&
+		status != http.StatusNotModified
+}
+
+// Adds the given token to the "Vary" header, unless already present.
+func addVary(head http.Header, key string) {
+	for _, line := range head.Values(`Vary`) {
+		for _, val := range strings.Split(line, `,`) {
+			val = strings.TrimSpace(val)
+			if val == `*` || strings.EqualFold(val, key) {
+				return
+			}
+		}
+	}
+	head.Add(`Vary`, key)
+}
@@ -0,0 +1,266 @@
+package rout
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"unicode"
+)
+
+/*
+Describes two endpoints whose patterns overlap for the same method, as found
+by `Conflicts`. `.A` is declared before `.B`, which means that requests
+matching both are handled by `.A`. `.Path` is an example path matched by
+both. Implements `error`.
+*/
+type Conflict struct {
+	A    Endpoint
+	B    Endpoint
+	Path string
+}
+
+// Implement `error`.
+func (self Conflict) Error() string {
+	return fmt.Sprintf(
+		`[rout] route %q %v %q overlaps with earlier route %q %v %q; example path: %q`,
+		self.B.Method, self.B.Match, self.B.Pattern,
+		self.A.Method, self.A.Match, self.A.Pattern,
+		self.Path,
+	)
+}
+
+/*
+Tool for introspection. Visits all endpoints declared by the given routing
+function, via `Visit`, and returns every pair of endpoints whose patterns
+overlap for the same method, regardless of pattern style. For example,
+`Pat("/a/{}")` and `Reg("^/a/b$")` both match "/a/b". Endpoints without a
+method match any method, and endpoints without a pattern match any path.
+Each conflict includes an example path matched by both endpoints.
+
+Overlap is detected by generating example paths from each pattern, converted
+to a regexp, and matching them against the other pattern. This is
+approximate: it finds typical accidental duplicates, but isn't guaranteed to
+find every overlap between complex regexps. Conditions not represented by
+`Endpoint`, such as filters and host patterns, are ignored. Invalid patterns
+cause a panic; see `Precompile` for validation.
+
+Intended for tests, for example:
+
+	func TestRoutes(t *testing.T) {
+		for _, val := range rout.Conflicts(myRoutes) {
+			t.Error(val)
+		}
+	}
+*/
+func Conflicts(fun func(Rou)) []Conflict {
+	vals := Endpoints(fun)
+	paths := make([][]string, len(vals))
+	for ind, val := range vals {
+		paths[ind] = endpointExamples(val.Endpoint)
+	}
+
+	var out []Conflict
+	for indB, valB := range vals {
+		for indA, valA := range vals[:indB] {
+			if !methodsOverlap(valA.Method, valB.Method) {
+				continue
+			}
+			path, ok := endpointOverlap(valA.Endpoint, valB.Endpoint, paths[indA], paths[indB])
+			if ok {
+				out = append(out, Conflict{valA.Endpoint, valB.Endpoint, path})
+			}
+		}
+	}
+	return out
+}
+
+/*
+Shortcut for tests. Returns the conflicts found by `Conflicts`, combined via
+`errors.Join`, or nil if there are none.
+*/
+func CheckConflicts(fun func(Rou)) error {
+	vals := Conflicts(fun)
+	if len(vals) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(vals))
+	for ind, val := range vals {
+		errs[ind] = val
+	}
+	return errors.Join(errs...)
+}
+
+func methodsOverlap(one, two string) bool {
+	return one == `` || two == `` || one == two
+}
+
+/*
+Prefers examples of the later endpoint, which demonstrate that it's shadowed
+by the earlier one.
+*/
+func endpointOverlap(valA, valB Endpoint, pathsA, pathsB []string) (string, bool) {
+	for _, path := range pathsB {
+		if valA.Match.Match(valA.Pattern, path) {
+			return path, true
+		}
+	}
+	for _, path := range pathsA {
+		if valB.Match.Match(valB.Pattern, path) {
+			return path, true
+		}
+	}
+	return ``, false
+}
+
+/*
+Returns example paths matched by the endpoint's pattern. The examples are
+generated from the equivalent regexp and filtered through the actual matcher,
+which discards any inaccuracies of the generation.
+*/
+func endpointExamples(val Endpoint) []string {
+	if val.Pattern == `` {
+		return []string{`/`}
+	}
+
+	reg, ok := matchToReg(val.Match, val.Pattern)
+	if !ok {
+		return nil
+	}
+
+	re, err := syntax.Parse(reg, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	var out []string
+	for _, path := range regExamples(re.Simplify()) {
+		if val.Match.Match(val.Pattern, path) {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// Caps the number of examples generated for each regexp node.
+const exampleLimit = 16
+
+/*
+Generates short strings matched by the given regexp, covering each branch of
+alternations and either zero or one repetition of optional or repeated
+subexpressions, up to `exampleLimit`. Empty-width assertions are ignored.
+*/
+func regExamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return nil
+
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return nil
+		}
+		return classExamples(re.Rune)
+
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{`x`}
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return regExamples(re.Sub[0])
+
+	case syntax.OpQuest, syntax.OpStar:
+		return unionExamples([]string{``}, regExamples(re.Sub[0]))
+
+	case syntax.OpConcat:
+		out := []string{``}
+		for _, sub := range re.Sub {
+			out = productExamples(out, regExamples(sub))
+			if len(out) == 0 {
+				return nil
+			}
+		}
+		return out
+
+	case syntax.OpAlternate:
+		var out []string
+		for _, sub := range re.Sub {
+			out = unionExamples(out, regExamples(sub))
+		}
+		return out
+
+	default:
+		return []string{``}
+	}
+}
+
+/*
+Picks representative characters from a class, preferring characters typical
+for URL paths, followed by the bounds of each range. The class is a sorted
+list of inclusive ranges.
+*/
+func classExamples(ranges []rune) (out []string) {
+	add := func(char rune) {
+		if len(out) < classLimit && unicode.IsPrint(char) && char != '/' {
+			out = unionExamples(out, []string{string(char)})
+		}
+	}
+
+	for _, char := range `x1a-_.` {
+		if classHas(ranges, char) {
+			add(char)
+		}
+	}
+	for _, char := range ranges {
+		add(char)
+	}
+
+	if len(out) == 0 {
+		out = append(out, string(ranges[0]))
+	}
+	return
+}
+
+// Caps the number of examples generated for each character class.
+const classLimit = 4
+
+func classHas(ranges []rune, char rune) bool {
+	for ind := 0; ind+1 < len(ranges); ind += 2 {
+		if char >= ranges[ind] && char <= ranges[ind+1] {
+			return true
+		}
+	}
+	return false
+}
+
+func unionExamples(out, vals []string) []string {
+	for _, val := range vals {
+		if len(out) >= exampleLimit {
+			break
+		}
+		if !hasString(out, val) {
+			out = append(out, val)
+		}
+	}
+	return out
+}
+
+func productExamples(heads, tails []string) []string {
+	var out []string
+	for _, head := range heads {
+		for _, tail := range tails {
+			out = unionExamples(out, []string{head + tail})
+		}
+	}
+	return out
+}
+
+func hasString(vals []string, val string) bool {
+	for _, elem := range vals {
+		if elem == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package rout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+/*
+Test utility which records the endpoints exercised by requests, and reports
+the endpoints which were never exercised. Endpoints are recorded at serve time,
+from `Rou.Matched`, either via the handler returned by `Coverage.Handler`, or
+by calling `Coverage.Add` after routing. All endpoints are enumerated via
+`Visit`. Endpoints are identified by method, pattern type, and pattern, but
+not by handler, because handlers declared as closures in routing functions
+may be different on every call; endpoints which differ only by handler, for
+example due to filters, are considered the same. Requests matched via HEAD
+fallback count as GET. The zero value is ready to use. Safe for concurrent use.
+Must not be copied after first use. Example:
+
+	var coverage rout.Coverage
+
+	func TestMain(m *testing.M) {
+		server := httptest.NewServer(coverage.Handler(myRoutes))
+		code := m.Run()
+		server.Close()
+		if code == 0 {
+			err := coverage.Check(myRoutes)
+			if err != nil {
+				fmt.Println(err)
+				code = 1
+			}
+		}
+		os.Exit(code)
+	}
+*/
+type Coverage struct {
+	lock sync.Mutex
+	hits map[coverageKey]int
+}
+
+type coverageKey struct {
+	Method  string
+	Match   Match
+	Pattern string
+}
+
+func coverageKeyOf(val Endpoint) coverageKey {
+	return coverageKey{val.Method, val.Match, val.Pattern}
+}
+
+// Records a hit of the given endpoint, usually obtained from `Rou.Matched`.
+func (self *Coverage) Add(val Endpoint) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.hits == nil {
+		self.hits = map[coverageKey]int{}
+	}
+	self.hits[coverageKeyOf(val)]++
+}
+
+// Returns the number of hits recorded for the given endpoint.
+func (self *Coverage) Hits(val Endpoint) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.hits[coverageKeyOf(val)]
+}
+
+/*
+Returns an `http.Handler` which serves requests via `Rou.Serve` with the given
+routing function, like `RouFunc`, and records the matched endpoints.
+*/
+func (self *Coverage) Handler(fun func(Rou)) http.Handler {
+	return http.HandlerFunc(func(rew http.ResponseWriter, req *http.Request) {
+		rou := MakeRou(rew, req)
+		rou.Serve(fun)
+
+		end, ok := rou.Matched()
+		if ok {
+			self.Add(end)
+		}
+	})
+}
+
+/*
+Returns the endpoints of the given routing function which haven't been
+recorded, in order of declaration.
+*/
+func (self *Coverage) Untested(fun func(Rou)) []Endpoint {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var out []Endpoint
+	Visit(fun, VisitorFunc(func(val Endpoint) {
+		if self.hits[coverageKeyOf(val)] == 0 {
+			out = append(out, val)
+		}
+	}))
+	return out
+}
+
+/*
+Returns an error listing the endpoints of the given routing function which
+haven't been recorded, or nil if all endpoints were exercised.
+*/
+func (self *Coverage) Check(fun func(Rou)) error {
+	vals := self.Untested(fun)
+	if len(vals) == 0 {
+		return nil
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `[rout] %v untested endpoints:`, len(vals))
+	for _, val := range vals {
+		buf.WriteString("\n\t" + strings.ReplaceAll(endpointLabel(val, IdentName(val.Handler)), "\n", ` `))
+	}
+	return errors.New(buf.String())
+}
@@ -0,0 +1,142 @@
+package rout
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+Diagram of the routing tree, for architecture docs. Implements `InfoVisitor` by
+appending endpoints; should be populated via `Visit`, or via the shortcut
+`CollectDiagram`. Renders as a Mermaid flowchart via `Diagram.Mermaid`, or as
+a Graphviz graph via `Diagram.DOT`. The tree is reconstructed from
+`EndpointInfo.Parent`: blocks such as `Rou.Sub` become nodes labeled with
+their pattern, and endpoints become leaves labeled with their method,
+pattern, and handler name. Example:
+
+	fmt.Println(rout.CollectDiagram(myRoutes).Mermaid())
+*/
+type Diagram []EndpointInfo
+
+// Shortcut for making `Diagram` and populating it via `Visit`.
+func CollectDiagram(fun func(Rou)) Diagram {
+	var out Diagram
+	Visit(fun, &out)
+	return out
+}
+
+// Implement `Visitor` by appending an endpoint without introspection data.
+func (self *Diagram) Endpoint(val Endpoint) {
+	self.EndpointInfo(EndpointInfo{Endpoint: val})
+}
+
+// Implement `InfoVisitor` by appending an endpoint.
+func (self *Diagram) EndpointInfo(val EndpointInfo) { *self = append(*self, val) }
+
+/*
+Renders the diagram as a Mermaid flowchart, which can be embedded in Markdown
+via a "mermaid" code block.
+*/
+func (self Diagram) Mermaid() string {
+	var buf strings.Builder
+	buf.WriteString("flowchart LR\n")
+
+	self.walk(func(id int, label string) {
+		buf.WriteString("\tn" + strconv.Itoa(id) + `["` + mermaidEscape(label) + "\"]\n")
+	}, func(src, tar int) {
+		buf.WriteString("\tn" + strconv.Itoa(src) + ` --> n` + strconv.Itoa(tar) + "\n")
+	})
+	return buf.String()
+}
+
+/*
+Renders the diagram as a Graphviz graph in the DOT language, which can be
+converted to an image via the "dot" command.
+*/
+func (self Diagram) DOT() string {
+	var buf strings.Builder
+	buf.WriteString("digraph routes {\n\trankdir=LR;\n\tnode [shape=box];\n")
+
+	self.walk(func(id int, label string) {
+		buf.WriteString("\tn" + strconv.Itoa(id) + ` [label="` + dotEscape(label) + "\"];\n")
+	}, func(src, tar int) {
+		buf.WriteString("\tn" + strconv.Itoa(src) + ` -> n` + strconv.Itoa(tar) + ";\n")
+	})
+
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+/*
+Invokes the callbacks for each node and edge of the tree, in order of
+declaration. Node 0 is the root. Blocks are identified by their `*Scope`, and
+each block is visited once, before its first endpoint.
+*/
+func (self Diagram) walk(node func(int, string), edge func(int, int)) {
+	ids := map[*Scope]int{}
+	next := 1
+	node(0, `routes`)
+
+	var chain []*Scope
+	for _, val := range self {
+		chain = chain[:0]
+		for scope := val.Parent; scope != nil; scope = scope.Parent {
+			chain = append(chain, scope)
+		}
+
+		parent := 0
+		for ind := len(chain) - 1; ind >= 0; ind-- {
+			scope := chain[ind]
+			id, ok := ids[scope]
+			if !ok {
+				id = next
+				next++
+				ids[scope] = id
+				node(id, scopeLabel(scope.Match, scope.Pattern))
+				edge(parent, id)
+			}
+			parent = id
+		}
+
+		node(next, endpointLabel(val.Endpoint, val.HandlerName))
+		edge(parent, next)
+		next++
+	}
+}
+
+func scopeLabel(match Match, pattern string) string {
+	if pattern == `` {
+		return `*`
+	}
+	return match.String() + ` ` + pattern
+}
+
+func endpointLabel(val Endpoint, handlerName string) string {
+	meth := val.Method
+	if meth == `` {
+		meth = `ANY`
+	}
+
+	out := meth + ` ` + scopeLabel(val.Match, val.Pattern)
+	if handlerName != `` {
+		out += "\n" + handlerName
+	}
+	return out
+}
+
+var mermaidReplacer = strings.NewReplacer(
+	`"`, `#quot;`,
+	`<`, `#lt;`,
+	`>`, `#gt;`,
+	"\n", `<br/>`,
+)
+
+func mermaidEscape(val string) string { return mermaidReplacer.Replace(val) }
+
+var dotReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+)
+
+func dotEscape(val string) string { return dotReplacer.Replace(val) }
@@ -0,0 +1,239 @@
+package rout
+
+import (
+	"net/http"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+/*
+Route in a `Dispatch` table. Usually generated by `Gen` from a routing
+function, rather than written by hand. Exactly one of `.Func` and
+`.ParamFunc` should be set. `.ParamFunc` receives the captures produced by the
+pattern, like in `Rou.ParamFunc`. Empty `.Method` matches any method.
+*/
+type DispatchEntry struct {
+	Method    string
+	Match     Match
+	Pattern   string
+	Func      Func
+	ParamFunc ParamFunc
+}
+
+/*
+Flattened dispatch table, which serves requests by looking up routes by method
+and path, instead of running a routing function. Made via `MakeDispatch`,
+usually in code generated by `Gen`. Preserves the semantics of declaration
+order: if multiple routes match a request, the earliest one wins, just like
+with `Rou.Route`. Routes with literal patterns are found via a map; other
+routes are found via a trie of the path segments of their literal prefixes,
+and only the routes sharing a prefix with the request path are matched.
+Routes for GET also match HEAD requests, like in `Rou.Route`.
+
+Only routes and handlers are represented. Features such as middleware, guards,
+filters, and host patterns don't apply, and must be applied around the table.
+Requests without a matching route are not handled, allowing to fall back on a
+routing function, which also generates the appropriate errors:
+
+	if !dispatch.Serve(rew, req) {
+		rout.Serve(rew, req, myRoutes)
+	}
+
+Safe for concurrent use.
+*/
+type Dispatch struct {
+	entries []DispatchEntry
+	methods map[string]*dispatchTable
+	any     dispatchTable
+}
+
+/*
+Makes a dispatch table from the given routes, in declaration order. Panics if
+a pattern is invalid.
+*/
+func MakeDispatch(vals ...DispatchEntry) *Dispatch {
+	out := &Dispatch{entries: vals, methods: map[string]*dispatchTable{}}
+
+	for ind, val := range vals {
+		tab := &out.any
+		if val.Method != `` {
+			tab = out.methods[val.Method]
+			if tab == nil {
+				tab = new(dispatchTable)
+				out.methods[val.Method] = tab
+			}
+		}
+		tab.add(ind, val)
+	}
+	return out
+}
+
+/*
+Serves the request if a route matches, returning true. Otherwise returns false
+without writing anything.
+*/
+func (self *Dispatch) Serve(rew http.ResponseWriter, req *http.Request) bool {
+	if self == nil || req == nil || req.URL == nil {
+		return false
+	}
+
+	path := req.URL.Path
+	ind := self.any.find(path, -1)
+	ind = self.methods[req.Method].find(path, ind)
+
+	if req.Method == http.MethodHead {
+		ind = self.methods[http.MethodGet].find(path, ind)
+	}
+	if ind < 0 {
+		return false
+	}
+
+	val := self.entries[ind]
+	if val.ParamFunc != nil {
+		val.ParamFunc(rew, req, val.Match.Submatch(val.Pattern, path))
+	} else if val.Func != nil {
+		val.Func(rew, req)
+	}
+	return true
+}
+
+/*
+Implement `http.Handler`. Serves the request via `Dispatch.Serve`, responding
+with 404 via `WriteErr` if no route matches.
+*/
+func (self *Dispatch) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	if !self.Serve(rew, req) {
+		WriteErr(rew, NotFound(req.Method, req.URL.Path))
+	}
+}
+
+type dispatchTable struct {
+	exact map[string]int
+	root  dispatchNode
+}
+
+/*
+Node of the path trie. Routes are stored in the node corresponding to the
+full segments of their literal prefix, in declaration order.
+*/
+type dispatchNode struct {
+	children map[string]*dispatchNode
+	routes   []dispatchRoute
+}
+
+type dispatchRoute struct {
+	Index   int
+	Match   Match
+	Pattern string
+}
+
+func (self *dispatchTable) add(ind int, val DispatchEntry) {
+	prefix, complete := dispatchPrefix(val.Match, val.Pattern)
+
+	if complete {
+		if self.exact == nil {
+			self.exact = map[string]int{}
+		}
+		if _, ok := self.exact[prefix]; !ok {
+			self.exact[prefix] = ind
+		}
+		return
+	}
+
+	node := &self.root
+	for _, seg := range prefixSegments(prefix) {
+		next := node.children[seg]
+		if next == nil {
+			next = new(dispatchNode)
+			if node.children == nil {
+				node.children = map[string]*dispatchNode{}
+			}
+			node.children[seg] = next
+		}
+		node = next
+	}
+	node.routes = append(node.routes, dispatchRoute{ind, val.Match, val.Pattern})
+}
+
+/*
+Returns the index of the earliest route matching the path, if it's earlier
+than the given index, which is negative when nothing matched yet.
+*/
+func (self *dispatchTable) find(path string, best int) int {
+	if self == nil {
+		return best
+	}
+
+	ind, ok := self.exact[path]
+	if ok && (best < 0 || ind < best) {
+		best = ind
+	}
+
+	node := &self.root
+	rem := strings.TrimPrefix(path, `/`)
+	for node != nil {
+		for _, val := range node.routes {
+			if best >= 0 && val.Index >= best {
+				break
+			}
+			if val.Match.Match(val.Pattern, path) {
+				best = val.Index
+				break
+			}
+		}
+
+		seg, next, found := strings.Cut(rem, `/`)
+		if !found {
+			break
+		}
+		node, rem = node.children[seg], next
+	}
+	return best
+}
+
+/*
+Returns the literal prefix of every path matched by the pattern, and true if
+the pattern matches only that exact path.
+*/
+func dispatchPrefix(match Match, pattern string) (string, bool) {
+	if pattern == `` {
+		return ``, false
+	}
+	if match == MatchExa {
+		return pattern, true
+	}
+
+	reg, ok := matchToReg(match, pattern)
+	if !ok {
+		return ``, false
+	}
+	prefix, complete := regexp.MustCompile(reg).LiteralPrefix()
+	return prefix, complete && regEndsText(reg)
+}
+
+/*
+True if the regexp is anchored at the end of the text. Without this anchor, a
+literal regexp such as "^/one" matches any input with this prefix.
+*/
+func regEndsText(src string) bool {
+	re, err := syntax.Parse(src, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	re = re.Simplify()
+	if re.Op == syntax.OpConcat && len(re.Sub) > 0 {
+		re = re.Sub[len(re.Sub)-1]
+	}
+	return re.Op == syntax.OpEndText
+}
+
+// Full segments of the prefix: those followed by a slash.
+func prefixSegments(prefix string) []string {
+	prefix = strings.TrimPrefix(prefix, `/`)
+	ind := strings.LastIndexByte(prefix, '/')
+	if ind < 0 {
+		return nil
+	}
+	return strings.Split(prefix[:ind], `/`)
+}
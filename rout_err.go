@@ -1,19 +1,245 @@
 package rout
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 )
 
 /*
-Returned by `rout.Route` when the router wasn't properly initialized. Using
-`rout.MakeRou` avoids this.
+Returned by `rout.Route` when the router wasn't properly initialized, for
+example when constructed directly as `Rou{}`, without a request or response
+writer. Using `rout.MakeRou` avoids this.
 */
 var ErrInit = fmt.Errorf(
 	`[rout] routing error: the router wasn't properly initialized; please use "rout.MakeRou"`,
 )
 
+/*
+Returned by `rout.Route` when routing or a handler panicked with a nil value.
+Depending on the Go version and GODEBUG settings, such panics may be otherwise
+indistinguishable from the absence of a panic.
+*/
+var ErrPanicNil = fmt.Errorf(`[rout] routing error: panic with nil value`)
+
+/*
+Error type generated by `Rou.Try` when a handler panics. Contains the panic
+value and the stack trace at the moment of the panic. Always has status 500.
+*/
+type ErrPanic struct {
+	Val   interface{}
+	Stack []byte
+}
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+// Always returns `http.StatusInternalServerError`.
+func (ErrPanic) HttpStatusCode() int { return http.StatusInternalServerError }
+
+// Implement `error`. Doesn't include the stack trace.
+func (self ErrPanic) Error() string {
+	return fmt.Sprintf(`[rout] panic in handler: %v`, self.Val)
+}
+
+// Returns the stack trace captured at the moment of the panic.
+func (self ErrPanic) StackTrace() string { return string(self.Stack) }
+
+// Returns the panic value if it's an error, otherwise nil.
+func (self ErrPanic) Unwrap() error {
+	err, _ := self.Val.(error)
+	return err
+}
+
+/*
+Matched via `errors.Is` by `ErrRespond` errors caused by the client
+disconnecting before the response was fully written. Such errors are usually
+safe to ignore. Example:
+
+	err := rout.Respond(rew, res)
+	if err != nil && !errors.Is(err, rout.ErrClientAbort) {
+		log.Println(err)
+	}
+*/
+var ErrClientAbort = fmt.Errorf(`[rout] client aborted the response`)
+
+/*
+Error type returned by `Respond` and `RespondN` when copying the response body
+fails. `.Written` is the amount of body bytes written before the failure.
+`.Read` is true if the error came from reading the body, which indicates a
+server-side or upstream failure. `.Abort` is true if the error came from
+writing to a client which has disconnected, which is detected for common
+network errors such as "broken pipe", "connection reset", and closed
+connections. Errors with `.Abort` match `ErrClientAbort` via `errors.Is`.
+*/
+type ErrRespond struct {
+	Err     error
+	Written int64
+	Read    bool
+	Abort   bool
+}
+
+// Implement `error`.
+func (self ErrRespond) Error() string {
+	var src string
+	if self.Read {
+		src = `reading response body`
+	} else if self.Abort {
+		src = `writing response body: client aborted`
+	} else {
+		src = `writing response body`
+	}
+	return fmt.Sprintf(`[rout] failed %v after %v bytes: %v`, src, self.Written, self.Err)
+}
+
+// Returns the underlying error.
+func (self ErrRespond) Unwrap() error { return self.Err }
+
+// Implement support for `errors.Is`. Matches `ErrClientAbort` if `.Abort`.
+func (self ErrRespond) Is(err error) bool {
+	return self.Abort && err == ErrClientAbort
+}
+
+/*
+Sentinel errors for use with `errors.Is`. Routing errors of type `ErrRoute`
+match the sentinel with the same status, regardless of method and path. The
+string-typed errors such as `ErrNotFound` match the corresponding sentinel.
+Example:
+
+	if errors.Is(err, rout.ErrNotFoundBase) {
+		renderNotFoundPage(rew, req)
+	}
+*/
+var (
+	ErrNotFoundBase             = ErrRoute{Status: http.StatusNotFound, Msg: `no such endpoint`}
+	ErrMethodNotAllowedBase     = ErrRoute{Status: http.StatusMethodNotAllowed, Msg: `method not allowed`}
+	ErrNotAcceptableBase        = ErrRoute{Status: http.StatusNotAcceptable, Msg: `not acceptable`}
+	ErrUnsupportedMediaTypeBase = ErrRoute{Status: http.StatusUnsupportedMediaType, Msg: `unsupported media type`}
+	ErrForbiddenBase            = ErrRoute{Status: http.StatusForbidden, Msg: `forbidden`}
+	ErrBadGatewayBase           = ErrRoute{Status: http.StatusBadGateway, Msg: `bad gateway`}
+)
+
+/*
+Structured routing error, generated internally by `Rou.Route` and other
+routing methods, for example for requests with an unknown path or method.
+Carries the HTTP status, the request method and path, and a short message,
+allowing error writers and loggers to access them without parsing the error
+message. `.Error` returns the same message as the corresponding string-typed
+errors such as `ErrNotFound`, and `errors.As` can convert it to those types.
+Encodes as JSON with the keys "status", "method", "path", "message", and
+"allow" and "suggest" when non-empty. For status 405, `.Allow` lists the
+methods allowed for the path, as far as routing could determine, in the format
+of the `Allow` header, which is set by `WriteErr` and other error writers.
+When `.Redact` is true, the message and JSON encoding omit the method and
+path; see `Rou.Redact`. `.Stack` is set only in "stack trace" mode; see
+`Rou.StackTraces`. `.Suggest` is set only in "suggest" mode; see
+`Rou.Suggest`.
+*/
+type ErrRoute struct {
+	Status  int
+	Method  string
+	Path    string
+	Msg     string
+	Allow   string
+	Redact  bool
+	Stack   string
+	Suggest string
+}
+
+/*
+Returns the stack trace captured when the error was generated, if any, one
+frame per two lines. Empty unless in "stack trace" mode via `Rou.StackTraces`.
+*/
+func (self ErrRoute) StackTrace() string { return self.Stack }
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+func (self ErrRoute) HttpStatusCode() int { return self.Status }
+
+/*
+Implement `error`. Same format as `Err`, unless redacted. Includes route
+suggestions, if any.
+*/
+func (self ErrRoute) Error() string {
+	var out string
+	if self.Redact {
+		out = `[rout] routing error (HTTP status ` + strconv.Itoa(self.Status) + `): ` + self.Msg
+	} else {
+		out = Err(self.Msg, self.Status, self.Method, self.Path)
+	}
+
+	if self.Suggest != `` {
+		out += `; did you mean: ` + self.Suggest
+	}
+	return out
+}
+
+// Implement `json.Marshaler`.
+func (self ErrRoute) MarshalJSON() ([]byte, error) {
+	if self.Redact {
+		return json.Marshal(errRouteJSON{self.Status, ``, ``, self.Msg, self.Allow, self.Suggest})
+	}
+	return json.Marshal(errRouteJSON{self.Status, self.Method, self.Path, self.Msg, self.Allow, self.Suggest})
+}
+
+/*
+Supports `errors.Is` with the sentinels such as `ErrNotFoundBase`, or any other
+`ErrRoute` without a method and path, by comparing statuses.
+*/
+func (self ErrRoute) Is(err error) bool {
+	val, ok := err.(ErrRoute)
+	return ok && val.Method == `` && val.Path == `` && val.Status == self.Status
+}
+
+/*
+Sets the `Allow` header from `ErrRoute.Allow`, if any, found in the error's
+chain. Used by error writers such as `WriteErr`.
+*/
+func errAllow(rew http.ResponseWriter, err error) {
+	var val ErrRoute
+	if errors.As(err, &val) && val.Allow != `` {
+		rew.Header().Set(`Allow`, val.Allow)
+	}
+}
+
+/*
+Supports `errors.As` with pointers to the string-typed errors corresponding to
+the status, such as `*ErrNotFound` for 404, for compatibility with code written
+for those types.
+*/
+func (self ErrRoute) As(out interface{}) bool {
+	switch out := out.(type) {
+	case *ErrNotFound:
+		return errRouteAs(self, out, http.StatusNotFound)
+	case *ErrMethodNotAllowed:
+		return errRouteAs(self, out, http.StatusMethodNotAllowed)
+	case *ErrNotAcceptable:
+		return errRouteAs(self, out, http.StatusNotAcceptable)
+	case *ErrUnsupportedMediaType:
+		return errRouteAs(self, out, http.StatusUnsupportedMediaType)
+	case *ErrForbidden:
+		return errRouteAs(self, out, http.StatusForbidden)
+	default:
+		return false
+	}
+}
+
+func errRouteAs[A ~string](err ErrRoute, out *A, status int) bool {
+	if err.Status != status {
+		return false
+	}
+	*out = A(err.Error())
+	return true
+}
+
+type errRouteJSON struct {
+	Status  int    `json:"status"`
+	Method  string `json:"method,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Msg     string `json:"message"`
+	Allow   string `json:"allow,omitempty"`
+	Suggest string `json:"suggest,omitempty"`
+}
+
 // Error type returned by `rout.Route` for requests with a known path and an
 // unknown method.
 type ErrMethodNotAllowed string
@@ -25,6 +251,9 @@ func (ErrMethodNotAllowed) HttpStatusCode() int { return http.StatusMethodNotAll
 // Implement `error` by returning self.
 func (self ErrMethodNotAllowed) Error() string { return string(self) }
 
+// Supports `errors.Is` with `ErrMethodNotAllowedBase`.
+func (ErrMethodNotAllowed) Is(err error) bool { return err == error(ErrMethodNotAllowedBase) }
+
 // Error type returned by `rout.Route` for requests with an unknown path.
 type ErrNotFound string
 
@@ -35,20 +264,167 @@ func (ErrNotFound) HttpStatusCode() int { return http.StatusNotFound }
 // Implement `error` by returning self.
 func (self ErrNotFound) Error() string { return string(self) }
 
-// Generates an appropriate `ErrMethodNotAllowed`. Used internally.
+// Supports `errors.Is` with `ErrNotFoundBase`.
+func (ErrNotFound) Is(err error) bool { return err == error(ErrNotFoundBase) }
+
+// Error type returned by `rout.Route` for requests with a known path and an
+// unacceptable `Accept` header. See `Rou.Negotiate`.
+type ErrNotAcceptable string
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+// Always returns `http.StatusNotAcceptable`.
+func (ErrNotAcceptable) HttpStatusCode() int { return http.StatusNotAcceptable }
+
+// Implement `error` by returning self.
+func (self ErrNotAcceptable) Error() string { return string(self) }
+
+// Supports `errors.Is` with `ErrNotAcceptableBase`.
+func (ErrNotAcceptable) Is(err error) bool { return err == error(ErrNotAcceptableBase) }
+
+// Error type returned by `rout.Route` for requests with a known path and an
+// unsupported `Content-Type` header. See `Rou.ContentTypes`.
+type ErrUnsupportedMediaType string
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+// Always returns `http.StatusUnsupportedMediaType`.
+func (ErrUnsupportedMediaType) HttpStatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// Implement `error` by returning self.
+func (self ErrUnsupportedMediaType) Error() string { return string(self) }
+
+// Supports `errors.Is` with `ErrUnsupportedMediaTypeBase`.
+func (ErrUnsupportedMediaType) Is(err error) bool { return err == error(ErrUnsupportedMediaTypeBase) }
+
+// Error type returned by `rout.Route` for requests with a known path which
+// are not allowed by filters. See `Rou.Restrict`.
+type ErrForbidden string
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+// Always returns `http.StatusForbidden`.
+func (ErrForbidden) HttpStatusCode() int { return http.StatusForbidden }
+
+// Implement `error` by returning self.
+func (self ErrForbidden) Error() string { return string(self) }
+
+// Supports `errors.Is` with `ErrForbiddenBase`.
+func (ErrForbidden) Is(err error) bool { return err == error(ErrForbiddenBase) }
+
+// Error type returned by `rout.Route` for requests with a known path and
+// invalid path parameters. See `Bind`.
+type ErrBadRequest string
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+// Always returns `http.StatusBadRequest`.
+func (ErrBadRequest) HttpStatusCode() int { return http.StatusBadRequest }
+
+// Implement `error` by returning self.
+func (self ErrBadRequest) Error() string { return string(self) }
+
+// Error type for requests rejected due to missing or invalid authentication.
+// Meant for guards; see `Rou.Guard`.
+type ErrUnauthorized string
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+// Always returns `http.StatusUnauthorized`.
+func (ErrUnauthorized) HttpStatusCode() int { return http.StatusUnauthorized }
+
+// Implement `error` by returning self.
+func (self ErrUnauthorized) Error() string { return string(self) }
+
+// Error type for requests to routes temporarily disabled for maintenance.
+// See `Rou.Maintenance`.
+type ErrServiceUnavailable string
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+// Always returns `http.StatusServiceUnavailable`.
+func (ErrServiceUnavailable) HttpStatusCode() int { return http.StatusServiceUnavailable }
+
+// Implement `error` by returning self.
+func (self ErrServiceUnavailable) Error() string { return string(self) }
+
+/*
+Error type generated by `Rou.Proxy` for requests which couldn't be forwarded to
+the upstream. `.Route` has status 502 and is subject to `Rou.Redact`. `.Error`
+and the JSON encoding use only `.Route`. The underlying error `.Err` may
+reveal upstream addresses, and is not included in the message; it's
+available via `errors.Is` and `errors.As`. `.Abort` is true if the request
+was canceled because the client disconnected; such errors match
+`ErrClientAbort` via `errors.Is`.
+*/
+type ErrProxy struct {
+	Route ErrRoute
+	Err   error
+	Abort bool
+}
+
+// Implement a hidden interface supported by `rout.ErrStatus`.
+func (self ErrProxy) HttpStatusCode() int { return self.Route.Status }
+
+// Implement `error`. Same as `ErrRoute.Error` for `.Route`.
+func (self ErrProxy) Error() string { return self.Route.Error() }
+
+// Implement `json.Marshaler`. Same as `ErrRoute.MarshalJSON` for `.Route`.
+func (self ErrProxy) MarshalJSON() ([]byte, error) { return self.Route.MarshalJSON() }
+
+// Supports `errors.Is` and `errors.As` with both `.Route` and `.Err`.
+func (self ErrProxy) Unwrap() []error { return []error{self.Route, self.Err} }
+
+// Implement support for `errors.Is`. Matches `ErrClientAbort` if `.Abort`.
+func (self ErrProxy) Is(err error) bool {
+	return self.Abort && err == ErrClientAbort
+}
+
+// Generates an appropriate `ErrMethodNotAllowed`.
 func MethodNotAllowed(meth, path string) ErrMethodNotAllowed {
 	return ErrMethodNotAllowed(Err(
 		`method not allowed`, ErrMethodNotAllowed(``).HttpStatusCode(), meth, path,
 	))
 }
 
-// Generates an appropriate `ErrNotFound`. Used internally.
+// Generates an appropriate `ErrNotFound`.
 func NotFound(meth, path string) ErrNotFound {
 	return ErrNotFound(Err(
 		`no such endpoint`, ErrNotFound(``).HttpStatusCode(), meth, path,
 	))
 }
 
+// Generates an appropriate `ErrNotAcceptable`.
+func NotAcceptable(meth, path string) ErrNotAcceptable {
+	return ErrNotAcceptable(Err(
+		`not acceptable`, ErrNotAcceptable(``).HttpStatusCode(), meth, path,
+	))
+}
+
+// Generates an appropriate `ErrUnsupportedMediaType`.
+func UnsupportedMediaType(meth, path string) ErrUnsupportedMediaType {
+	return ErrUnsupportedMediaType(Err(
+		`unsupported media type`, ErrUnsupportedMediaType(``).HttpStatusCode(), meth, path,
+	))
+}
+
+// Generates an appropriate `ErrForbidden`.
+func Forbidden(meth, path string) ErrForbidden {
+	return ErrForbidden(Err(
+		`forbidden`, ErrForbidden(``).HttpStatusCode(), meth, path,
+	))
+}
+
+// Generates an appropriate `ErrUnauthorized`. Meant for guards.
+func Unauthorized(meth, path string) ErrUnauthorized {
+	return ErrUnauthorized(Err(
+		`unauthorized`, ErrUnauthorized(``).HttpStatusCode(), meth, path,
+	))
+}
+
+// Generates an appropriate `ErrServiceUnavailable`. Used by `Rou.Maintenance`.
+func ServiceUnavailable(meth, path string) ErrServiceUnavailable {
+	return ErrServiceUnavailable(Err(
+		`service unavailable`, ErrServiceUnavailable(``).HttpStatusCode(), meth, path,
+	))
+}
+
 /*
 Generates a routing error message including the given status, method and path.
 More efficient than equivalent `fmt.Sprintf` or `fmt.Errorf`.
@@ -0,0 +1,15 @@
+//go:build !plan9
+
+package rout
+
+import (
+	"errors"
+	"syscall"
+)
+
+// True for system errors which indicate that the client has disconnected.
+func isAbortErrno(err error) bool {
+	return errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED)
+}
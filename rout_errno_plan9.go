@@ -0,0 +1,8 @@
+package rout
+
+/*
+Plan 9 reports network errors as strings rather than numbers, and they're not
+exposed by "syscall". Disconnects are still detected via `net.ErrClosed`; see
+`isAbortErr`.
+*/
+func isAbortErrno(error) bool { return false }
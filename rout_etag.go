@@ -0,0 +1,176 @@
+package rout
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+/*
+Returns a router that enables conditional responses via "ETag" and
+"If-None-Match" for GET and HEAD requests to routes declared downstream,
+including sub-routers, saving bandwidth for cacheable resources. Other methods
+are unaffected.
+
+If the given function is non-nil and returns a non-empty tag for the request,
+for example derived from the version of the requested resource, the tag is
+set as the "ETag" header, and if it matches "If-None-Match", the router
+responds with 304 without running the handler. Middleware added via `Rou.Use`
+still runs. The tag is quoted unless already quoted or weak.
+
+Otherwise, the response is buffered in memory, and for status 200, the tag is
+computed by hashing the body, unless the handler sets "ETag" itself. If it
+matches "If-None-Match", the router responds with 304 without the body.
+Flushing the response, for example when streaming, disables buffering and
+skips the tag. Buffering makes this mode unsuitable for large responses.
+
+Comparison is weak, as required for "If-None-Match", so tags remain valid when
+`Rou.Compress` makes them weak. Example:
+
+	rou.Sta(`/api/articles`).ETag(articleVersion).Sub(routesArticles)
+	rou.Sta(`/assets`).ETag(nil).Sub(routesAssets)
+*/
+func (self Rou) ETag(fun func(*http.Request) string) Rou {
+	conf := self.setConf()
+	conf.EtagOn = true
+	conf.EtagFunc = fun
+	return self
+}
+
+// Used by `Rou.ETag`. Must be the innermost handler.
+type etagHan struct {
+	Han http.Handler
+	Fun func(*http.Request) string
+}
+
+func (self etagHan) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		self.Han.ServeHTTP(rew, req)
+		return
+	}
+
+	if self.Fun != nil {
+		tag := etagQuote(self.Fun(req))
+		if tag != `` {
+			rew.Header().Set(`Etag`, tag)
+			if etagMatch(req.Header.Values(`If-None-Match`), tag) {
+				rew.WriteHeader(http.StatusNotModified)
+				return
+			}
+			self.Han.ServeHTTP(rew, req)
+			return
+		}
+	}
+
+	out := etagWriter{Rew: rew, Req: req}
+	self.Han.ServeHTTP(&out, req)
+	out.Finish()
+}
+
+/*
+Response writer used by `Rou.ETag` for hashing. Buffers the response until
+`.Finish`, unless flushed.
+*/
+type etagWriter struct {
+	Rew    http.ResponseWriter
+	Req    *http.Request
+	Status int
+	Buf    bytes.Buffer
+	Passed bool
+}
+
+func (self *etagWriter) Header() http.Header { return self.Rew.Header() }
+
+func (self *etagWriter) WriteHeader(status int) {
+	if self.Passed || isInfoStatus(status) {
+		self.Rew.WriteHeader(status)
+		return
+	}
+	if self.Status == 0 {
+		self.Status = status
+	}
+}
+
+func (self *etagWriter) Write(val []byte) (int, error) {
+	if self.Passed {
+		return self.Rew.Write(val)
+	}
+	if self.Status == 0 {
+		self.Status = http.StatusOK
+	}
+	return self.Buf.Write(val)
+}
+
+func (self *etagWriter) Flush() {
+	if !self.Passed {
+		self.Passed = true
+		self.write()
+	}
+	flush(self.Rew)
+}
+
+// Allows `http.ResponseController` to reach the underlying writer.
+func (self *etagWriter) Unwrap() http.ResponseWriter { return self.Rew }
+
+func (self *etagWriter) Finish() {
+	if self.Passed {
+		return
+	}
+	self.Passed = true
+
+	if self.Status == http.StatusOK {
+		head := self.Rew.Header()
+		tag := head.Get(`Etag`)
+		if tag == `` {
+			tag = etagHash(self.Buf.Bytes())
+			head.Set(`Etag`, tag)
+		}
+
+		if etagMatch(reqHeader(self.Req).Values(`If-None-Match`), tag) {
+			head.Del(`Content-Type`)
+			head.Del(`Content-Length`)
+			self.Rew.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	self.write()
+}
+
+func (self *etagWriter) write() {
+	if self.Status != 0 {
+		self.Rew.WriteHeader(self.Status)
+	}
+	if self.Buf.Len() > 0 {
+		_, _ = self.Buf.WriteTo(self.Rew)
+	}
+}
+
+func etagHash(val []byte) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write(val)
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+func etagQuote(val string) string {
+	if val == `` || strings.HasPrefix(val, `"`) || strings.HasPrefix(val, `W/"`) {
+		return val
+	}
+	return `"` + val + `"`
+}
+
+// Weak comparison, as required for "If-None-Match".
+func etagMatch(head []string, tag string) bool {
+	tag = strings.TrimPrefix(tag, `W/`)
+
+	for _, line := range head {
+		for _, val := range strings.Split(line, `,`) {
+			val = strings.TrimSpace(val)
+			if val == `*` || strings.TrimPrefix(val, `W/`) == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,383 @@
+package rout
+
+import (
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+/*
+Additional request predicate used by `Rou`, on top of URL pattern and HTTP
+method. Filters are tested only after the pattern matches, and only in "real"
+routing mode (they're ignored by `Visit`). A filter mismatch is simply a
+mismatch: the router falls through to the next route, without generating an
+error. See `Rou.Filter` and methods such as `Rou.Depth` which set filters.
+*/
+type Filter interface{ Match(*http.Request) bool }
+
+// Shortcut type. Implements `Filter` by calling itself.
+type FilterFunc func(*http.Request) bool
+
+// Implement `Filter` by calling itself. Nil func matches any request.
+func (self FilterFunc) Match(req *http.Request) bool {
+	return self == nil || self(req)
+}
+
+/*
+Combination of multiple filters. Implements `Filter` by requiring all inner
+filters to match, in order. Nil elements are ignored. Used by `Rou` when
+multiple filters are specified.
+*/
+type Filters []Filter
+
+// Implement `Filter`.
+func (self Filters) Match(req *http.Request) bool {
+	for _, val := range self {
+		if val != nil && !val.Match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Implements `Filter` by constraining the amount of non-empty segments in
+`req.URL.Path`, independently of their content. For example, `/one/two` has
+depth 2, and `/` has depth 0. `.Min` is inclusive. `.Max` is inclusive when
+non-negative; negative `.Max` means no upper limit. Used by `Rou.Depth`.
+*/
+type Depth struct{ Min, Max int }
+
+// Implement `Filter`.
+func (self Depth) Match(req *http.Request) bool {
+	val := pathDepth(reqPath(req))
+	return val >= self.Min && (self.Max < 0 || val <= self.Max)
+}
+
+/*
+Implements `Filter` by requiring the URL query to have the given key with the
+given value. If the key has multiple values, any of them may match. Used by
+`Rou.Query`.
+*/
+type Query struct{ Key, Val string }
+
+// Implement `Filter`.
+func (self Query) Match(req *http.Request) bool {
+	return queryAny(reqQuery(req), self.Key, func(val string) bool {
+		return val == self.Val
+	})
+}
+
+/*
+Implements `Filter` by requiring the URL query to have the given key, with any
+value, including empty. Used by `Rou.QueryExists`.
+*/
+type QueryKey string
+
+// Implement `Filter`.
+func (self QueryKey) Match(req *http.Request) bool {
+	return queryAny(reqQuery(req), string(self), nil)
+}
+
+/*
+Implements `Filter` by requiring the request to have the given header with the
+given value. If the header has multiple values, any of them may match. Used
+by `Rou.Hdr`.
+*/
+type Hdr struct{ Key, Val string }
+
+// Implement `Filter`.
+func (self Hdr) Match(req *http.Request) bool {
+	for _, val := range reqHeader(req).Values(self.Key) {
+		if val == self.Val {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Implements `Filter` by requiring the request to have the given header with a
+value that begins with the given prefix. If the header has multiple values,
+any of them may match. Used by `Rou.HdrPrefix`.
+*/
+type HdrPrefix struct{ Key, Val string }
+
+// Implement `Filter`.
+func (self HdrPrefix) Match(req *http.Request) bool {
+	for _, val := range reqHeader(req).Values(self.Key) {
+		if strings.HasPrefix(val, self.Val) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Implements `Filter` by requiring the request's `Accept` header to accept at
+least one of the given media types, such as "application/json". Performs
+proper parsing of media ranges and q-values: a type is acceptable if its
+q-value is above zero, where the q-value comes from the most specific matching
+media range, such as "application/json", "application/*", or the "any"
+wildcard. A missing or empty `Accept` header accepts any type. The filter
+doesn't compare preferences between different routes; declare routes in the
+order of server preference. Used by `Rou.Accepts`.
+*/
+type Accepts []string
+
+// Implement `Filter`.
+func (self Accepts) Match(req *http.Request) bool {
+	head := reqHeader(req).Values(`Accept`)
+	for _, val := range self {
+		if acceptQ(head, val) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Implements `Filter` by requiring the request's `Content-Type` header to match
+at least one of the given media types. Parameters such as "charset" are
+ignored. Supports wildcards such as "multipart/*". Matching is
+case-insensitive. Used by `Rou.ContentType`.
+*/
+type ContentType []string
+
+// Implement `Filter`.
+func (self ContentType) Match(req *http.Request) bool {
+	typ, _ := strCut(reqHeader(req).Get(`Content-Type`), ';')
+	typ = strings.TrimSpace(typ)
+
+	for _, val := range self {
+		if mediaSpecificity(val, typ) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Implements `Filter` by requiring the request's scheme, as reported by
+`ReqScheme`, to equal the given scheme, case-insensitively. Used by
+`Rou.Scheme` and `Rou.TLS`.
+*/
+type Scheme string
+
+// Implement `Filter`.
+func (self Scheme) Match(req *http.Request) bool {
+	return strings.EqualFold(string(self), ReqScheme(req))
+}
+
+/*
+Returns the URL scheme of the request: "https" when `req.TLS` is set,
+otherwise the scheme reported by a reverse proxy via `X-Forwarded-Proto` or
+`Forwarded`, otherwise `req.URL.Scheme`, falling back on "http". Note that
+forwarding headers can be set by any client; rely on them only when the server
+is reachable exclusively through a trusted proxy which overrides them.
+*/
+func ReqScheme(req *http.Request) string {
+	if req == nil {
+		return ``
+	}
+	if req.TLS != nil {
+		return `https`
+	}
+
+	head := req.Header
+
+	val, _ := strCut(head.Get(`X-Forwarded-Proto`), ',')
+	val = strings.TrimSpace(val)
+	if val != `` {
+		return strings.ToLower(val)
+	}
+
+	val = forwardedProto(head.Get(`Forwarded`))
+	if val != `` {
+		return strings.ToLower(val)
+	}
+
+	if req.URL != nil && req.URL.Scheme != `` {
+		return req.URL.Scheme
+	}
+	return `http`
+}
+
+/*
+Implements `Filter` by requiring the request to have a cookie with the given
+name, with any value. Used by `Rou.Cookie`.
+*/
+type Cookie string
+
+// Implement `Filter`.
+func (self Cookie) Match(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	_, err := req.Cookie(string(self))
+	return err == nil
+}
+
+/*
+Implements `Filter` by requiring the request to have a cookie with the given
+name and value. Used by `Rou.CookieVal`.
+*/
+type CookieVal struct{ Key, Val string }
+
+// Implement `Filter`.
+func (self CookieVal) Match(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	val, err := req.Cookie(self.Key)
+	return err == nil && val.Value == self.Val
+}
+
+/*
+Implements `Filter` by parsing the request's `Accept-Language` header with
+q-values, and requiring any language range with q>0 to match one of the given
+language tags. Ranges are tried in the order of descending q-value. The range
+"en" matches tags such as "en" and "en-US", and the range "en-US" matches the
+tags "en-US" and "en". The range "*" matches any tag. A range with q=0, such
+as "de;q=0", excludes the tags it matches, even via "*". Matching is
+case-insensitive. A request without `Accept-Language` doesn't match; declare
+a default route or sub-router afterwards. Since routes are tried in order, a
+client accepting several languages is routed to the first matching route,
+regardless of their relative preference. Used by `Rou.Lang`.
+*/
+type Lang []string
+
+// Implement `Filter`.
+func (self Lang) Match(req *http.Request) bool {
+	var buf [8]langRange
+	rngs := langRanges(reqHeader(req).Values(`Accept-Language`), buf[:0])
+
+	for _, rng := range rngs {
+		if rng.Q <= 0 {
+			break
+		}
+		for _, tag := range self {
+			if (rng.Rng == `*` || langMatch(rng.Rng, tag)) && !langExcluded(rngs, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+Implements `Filter` by requiring the client IP, taken from `req.RemoteAddr`, to
+belong to at least one of the given networks in CIDR notation, such as
+"10.0.0.0/8" or "::1/128". Plain IP addresses such as "127.0.0.1" are also
+allowed. Forwarding headers such as `X-Forwarded-For` are deliberately
+ignored, since they can be set by any client. Networks are parsed lazily,
+cached, and reused; invalid networks cause a panic. Used by `Rou.CIDR`.
+*/
+type CIDR []string
+
+// Implement `Filter`.
+func (self CIDR) Match(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+
+	ip := net.ParseIP(hostname(req.RemoteAddr))
+	if ip == nil {
+		return false
+	}
+
+	for _, val := range self {
+		if cachedNet(val).Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Implements `Filter` by matching a percentage of clients, for traffic splitting
+such as A/B tests and gradual rollouts. `.Percent` is between 0 and 100. The
+client is identified by the request header named by `.Header`, such as
+"X-Client-Id", or the cookie named by `.Cookie`, such as "session". If both
+are set, the header takes priority, and the cookie is used when the header is
+absent. The decision is derived from a hash of `.Salt` and the identifier, and
+is stable for a given client, including when the filter runs more than once
+for the same request. To keep multiple experiments on the same identifier
+independent, give each a different `.Salt`, such as the experiment name.
+
+A request without the identifier, or with an empty one, doesn't match, and
+falls through to the following routes, which should serve the default
+variant. `.Percent` of 100 or more matches every request, with or without the
+identifier, and 0 or less matches none. Used by `Rou.Weight` and
+`Rou.WeightCookie`.
+*/
+type Weight struct {
+	Percent int
+	Header  string
+	Cookie  string
+	Salt    string
+}
+
+// Implement `Filter`.
+func (self Weight) Match(req *http.Request) bool {
+	if self.Percent <= 0 {
+		return false
+	}
+	if self.Percent >= 100 {
+		return true
+	}
+
+	key := self.key(req)
+	if key == `` {
+		return false
+	}
+
+	hash := fnv.New32a()
+	_, _ = io.WriteString(hash, self.Salt)
+	_, _ = hash.Write([]byte{0})
+	_, _ = io.WriteString(hash, key)
+	return int(hash.Sum32()%100) < self.Percent
+}
+
+// Returns the client identifier used by `Weight.Match`, or "" if absent.
+func (self Weight) key(req *http.Request) string {
+	if req == nil {
+		return ``
+	}
+	if self.Header != `` {
+		val := req.Header.Get(self.Header)
+		if val != `` {
+			return val
+		}
+	}
+	if self.Cookie != `` {
+		val, err := req.Cookie(self.Cookie)
+		if err == nil {
+			return val.Value
+		}
+	}
+	return ``
+}
+
+/*
+Implements `Filter` by requiring the request to be a protocol upgrade request:
+the `Connection` header must include the "upgrade" token, and the `Upgrade`
+header must include the given protocol, such as "websocket". Empty protocol
+matches any upgrade. Matching is case-insensitive. Used by `Rou.Upgrade` and
+`Rou.Websocket`.
+*/
+type Upgrade string
+
+// Implement `Filter`.
+func (self Upgrade) Match(req *http.Request) bool {
+	head := reqHeader(req)
+	if !headToken(head.Values(`Connection`), `upgrade`) {
+		return false
+	}
+	if self == `` {
+		return len(head.Values(`Upgrade`)) > 0
+	}
+	return headToken(head.Values(`Upgrade`), string(self))
+}
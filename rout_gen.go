@@ -0,0 +1,173 @@
+package rout
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	r "reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Generator of Go source code for a `Dispatch` table, which provides the
+declaration style of this package with the dispatch cost of a flattened
+lookup table. Visits all routes of a routing function via `Visit`, and emits a
+source file which declares a variable holding the table, in the given package,
+referencing the handlers by name. Usable via `go:generate`, by running a small
+program which imports the routes:
+
+	// In "routes/routes.go":
+	//go:generate go run ./gen
+
+	// In "routes/gen/main.go":
+	func main() {
+		err := rout.Gen{Package: `routes`}.WriteFile(`dispatch_gen.go`, routes.Routes)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+Supports routes of any pattern style, with handlers of type `Func` or
+`ParamFunc`, such as those registered via `Rou.Func` and `Rou.ParamFunc`,
+which must be top-level functions declared in the given package. Closures,
+method values, and other handler types can't be referenced by name and cause
+an error. See `Dispatch` for the features which aren't represented in the
+table. `.Package` is the package clause of the generated file, and is
+required. `.Var` is the name of the generated variable, defaulting to
+"Dispatch".
+*/
+type Gen struct {
+	Package string
+	Var     string
+}
+
+/*
+Generates formatted Go source code declaring a `Dispatch` table for the routes
+of the given routing function. See `Gen`.
+*/
+func (self Gen) Source(fun func(Rou)) ([]byte, error) {
+	if !token.IsIdentifier(self.Package) {
+		return nil, fmt.Errorf(`[rout] invalid package name %q`, self.Package)
+	}
+
+	name := self.Var
+	if name == `` {
+		name = `Dispatch`
+	}
+	if !token.IsIdentifier(name) {
+		return nil, fmt.Errorf(`[rout] invalid variable name %q`, name)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by rout.Gen. DO NOT EDIT.\n\n")
+	buf.WriteString(`package ` + self.Package + "\n\n")
+	buf.WriteString("import \"github.com/mitranim/rout\"\n\n")
+	buf.WriteString(`var ` + name + " = rout.MakeDispatch(\n")
+
+	var err error
+	Visit(fun, InfoVisitorFunc(func(val EndpointInfo) {
+		if err != nil {
+			return
+		}
+		var line string
+		line, err = self.entry(val)
+		buf.WriteString(line)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString(")\n")
+	return format.Source(buf.Bytes())
+}
+
+/*
+Generates the source code via `Gen.Source` and writes it to the file at the
+given path, replacing the previous content.
+*/
+func (self Gen) WriteFile(path string, fun func(Rou)) error {
+	src, err := self.Source(fun)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, src, 0o644)
+}
+
+func (self Gen) entry(val EndpointInfo) (string, error) {
+	var field string
+	switch IdentType(val.Handler) {
+	case r.TypeOf(Func(nil)):
+		field = `Func`
+	case r.TypeOf(ParamFunc(nil)):
+		field = `ParamFunc`
+	default:
+		return ``, genErr(val, fmt.Sprintf(
+			`unsupported handler type %v; only Func and ParamFunc are supported`,
+			IdentType(val.Handler),
+		))
+	}
+
+	handler := val.HandlerName
+	pkg, name, _ := strings.Cut(handler, `.`)
+	if pkg != self.Package || !token.IsIdentifier(name) {
+		return ``, genErr(val, fmt.Sprintf(
+			`handler %q is not a top-level function in package %q`,
+			handler, self.Package,
+		))
+	}
+
+	match := matchIdent(val.Match)
+	if match == `` {
+		return ``, genErr(val, fmt.Sprintf(`unsupported match %v`, val.Match))
+	}
+
+	var buf strings.Builder
+	buf.WriteString(`rout.DispatchEntry{`)
+	if val.Method != `` {
+		buf.WriteString(`Method: ` + goString(val.Method) + `, `)
+	}
+	buf.WriteString(`Match: rout.` + match + `, `)
+	buf.WriteString(`Pattern: ` + goString(val.Pattern) + `, `)
+	buf.WriteString(field + `: ` + name + "},\n")
+	return buf.String(), nil
+}
+
+func genErr(val EndpointInfo, msg string) error {
+	return fmt.Errorf(
+		`[rout] unable to generate dispatch for route %q %q: %v`,
+		val.Method, val.Pattern, msg,
+	)
+}
+
+// Name of the exported constant for the given match.
+func matchIdent(val Match) string {
+	switch val {
+	case MatchExa:
+		return `MatchExa`
+	case MatchSta:
+		return `MatchSta`
+	case MatchReg:
+		return `MatchReg`
+	case MatchPat:
+		return `MatchPat`
+	case MatchMux:
+		return `MatchMux`
+	case MatchCol:
+		return `MatchCol`
+	case MatchParamSta:
+		return `MatchParamSta`
+	default:
+		return ``
+	}
+}
+
+// Go string literal, preferring raw strings like the rest of this package.
+func goString(val string) string {
+	if strconv.CanBackquote(val) {
+		return "`" + val + "`"
+	}
+	return strconv.Quote(val)
+}
@@ -0,0 +1,63 @@
+package rout
+
+import (
+	"io"
+	"net/http"
+)
+
+/*
+Handler type which writes the string as the response body, with
+"Content-Type: text/plain; charset=utf-8" unless the header is already set.
+Useful for trivial endpoints such as health checks and version strings.
+Example:
+
+	rou.Exa(`/health`).Get().Handler(rout.Str(`ok`))
+*/
+type Str string
+
+// Implement `http.Handler`.
+func (self Str) ServeHTTP(rew http.ResponseWriter, _ *http.Request) {
+	setContentType(rew, `text/plain; charset=utf-8`)
+	_, _ = io.WriteString(rew, string(self))
+}
+
+/*
+Handler type which writes the bytes as the response body. The content type is
+left to the response writer, which for `http.Server` detects it from the body
+unless set.
+*/
+type Bytes []byte
+
+// Implement `http.Handler`.
+func (self Bytes) ServeHTTP(rew http.ResponseWriter, _ *http.Request) {
+	_, _ = rew.Write(self)
+}
+
+/*
+Handler type which responds with the given status and an empty body. Zero is
+equivalent to 200. Example:
+
+	rou.Exa(`/ping`).Handler(rout.StatusOnly(http.StatusNoContent))
+*/
+type StatusOnly int
+
+// Implement `http.Handler`.
+func (self StatusOnly) ServeHTTP(rew http.ResponseWriter, _ *http.Request) {
+	if self != 0 {
+		rew.WriteHeader(int(self))
+	}
+}
+
+/*
+Shortcut for making a `JSONVal` handler with status 200. Example:
+
+	rou.Exa(`/version`).Get().Handler(rout.JSONOf(versionInfo))
+*/
+func JSONOf(val interface{}) JSONVal { return JSONVal{Val: val} }
+
+func setContentType(rew http.ResponseWriter, val string) {
+	head := rew.Header()
+	if head.Get(`Content-Type`) == `` {
+		head.Set(`Content-Type`, val)
+	}
+}
@@ -0,0 +1,161 @@
+package rout
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+/*
+Short for "host pattern": templated pattern for matching `req.Host`, such as:
+
+	example.com
+	{tenant}.example.com
+	{tenant}.{region}.example.com
+
+Used by `Rou.HostPat`. The pattern is split into dot-separated labels. Empty
+strings represent capture groups, which match and capture an entire non-empty
+label. Non-empty strings match the exact same label, case-insensitively. The
+pattern must match the entire host, with the same amount of labels. The port,
+if any, is ignored. Just like `Pat`, `HostPat` allows names in capture groups,
+such as "{tenant}", but discards them when parsing.
+*/
+type HostPat []string
+
+/*
+Like `(*regexp.Regexp).MatchString`: returns true if the input host matches
+the pattern, without capturing. The input may include a port, which is
+ignored.
+*/
+func (self HostPat) Match(inp string) bool {
+	return self.match(inp, nil)
+}
+
+/*
+Similar to `(*regexp.Regexp).FindStringSubmatch`: returns nil or positional
+captures. On success, slice length equals `pat.Num()`.
+*/
+func (self HostPat) Submatch(inp string) []string {
+	buf := []string{}
+	if self.match(inp, &buf) {
+		return buf
+	}
+	return nil
+}
+
+func (self HostPat) match(rem string, out *[]string) bool {
+	var subs subs
+	rem = hostname(rem)
+
+	for ind, seg := range self {
+		var label string
+
+		if ind < len(self)-1 {
+			cur := strings.IndexByte(rem, '.')
+			if cur < 0 {
+				return false
+			}
+			label, rem = rem[:cur], rem[cur+1:]
+		} else {
+			label, rem = rem, ``
+		}
+
+		if seg == `` {
+			if !subs.add(label) {
+				return false
+			}
+			continue
+		}
+
+		if !strings.EqualFold(seg, label) {
+			return false
+		}
+	}
+
+	if out != nil {
+		*out = append(*out, subs.slice()...)
+	}
+	return true
+}
+
+// Parses the pattern from a string, replacing the receiver.
+func (self *HostPat) Parse(src string) error {
+	if src == `` {
+		return fmt.Errorf(`[rout] invalid host pattern %q: empty pattern`, src)
+	}
+
+	labels := strings.Split(src, `.`)
+	out := make(HostPat, 0, len(labels))
+
+	for _, val := range labels {
+		if val == `` {
+			return fmt.Errorf(`[rout] invalid host pattern %q: empty label`, src)
+		}
+
+		if !strings.ContainsAny(val, `{}`) {
+			out = append(out, val)
+			continue
+		}
+
+		if !strings.HasPrefix(val, `{`) || !strings.HasSuffix(val, `}`) ||
+			strings.ContainsAny(val[1:len(val)-1], `{}`) {
+			return fmt.Errorf(
+				`[rout] invalid host pattern %q: a template expression must be an entire label`,
+				src,
+			)
+		}
+		out = append(out, ``)
+	}
+
+	if out.Num() > subsCap {
+		return fmt.Errorf(
+			`[rout] invalid host pattern %q: found %v template expressions which exceeds limit %v`,
+			src, out.Num(), subsCap,
+		)
+	}
+
+	*self = out
+	return nil
+}
+
+/*
+Implement `fmt.Stringer` for debug purposes. Capture groups are anonymous in the
+resulting representation.
+*/
+func (self HostPat) String() string {
+	var buf strings.Builder
+	for ind, val := range self {
+		if ind > 0 {
+			buf.WriteByte('.')
+		}
+		if val == `` {
+			buf.WriteString(segmentTemplate)
+		} else {
+			buf.WriteString(val)
+		}
+	}
+	return buf.String()
+}
+
+// Returns the amount of capture groups by counting empty labels.
+func (self HostPat) Num() int { return Pat(self).Num() }
+
+// Removes the port, if any.
+func hostname(val string) string {
+	if strings.IndexByte(val, ':') < 0 {
+		return val
+	}
+	host, _, err := net.SplitHostPort(val)
+	if err != nil {
+		return val
+	}
+	return host
+}
+
+func reqHost(req *http.Request) string {
+	if req != nil {
+		return req.Host
+	}
+	return ``
+}
@@ -0,0 +1,87 @@
+package rout
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+/*
+Adapter for JSON API endpoints. Returns a `Han` which decodes the request body
+into `In`, calls the given function, and encodes the resulting `Out` as JSON
+with status 200. An empty request body leaves `In` zeroed; use `struct{}` for
+endpoints without input. If decoding fails, responds with status 400. If the
+function returns an error, responds with the status obtained via
+`ErrStatusFallback`. Errors are encoded as `{"error": "<message>"}`. For 5xx
+statuses, the message is only the standard text for the status, via
+`http.StatusText`, to avoid exposing internal details to clients. Other
+messages are obtained via `.Error`, which for `ErrRoute` respects
+`Rou.Redact`. Example:
+
+	rou.Pat(`/api/articles`).Post().Han(rout.JSON(apiArticleCreate))
+
+	func apiArticleCreate(req *http.Request, inp ArticleInput) (Article, error) {
+		...
+	}
+*/
+func JSON[In, Out any](fun func(*http.Request, In) (Out, error)) Han {
+	return func(req *http.Request) http.Handler {
+		var inp In
+
+		err := jsonDecode(req, &inp)
+		if err != nil {
+			return jsonErr(http.StatusBadRequest, err)
+		}
+
+		if fun == nil {
+			return nil
+		}
+
+		out, err := fun(req, inp)
+		if err != nil {
+			return jsonErr(ErrStatusFallback(err), err)
+		}
+		return JSONVal{http.StatusOK, out}
+	}
+}
+
+func jsonDecode(req *http.Request, out interface{}) error {
+	if req == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	err := json.NewDecoder(req.Body).Decode(out)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// Server errors are replaced with the status text; see `JSON`.
+func jsonErr(status int, err error) JSONVal {
+	if status >= http.StatusInternalServerError {
+		return JSONVal{status, jsonErrBody{http.StatusText(status)}}
+	}
+	return JSONVal{status, jsonErrBody{err.Error()}}
+}
+
+type jsonErrBody struct {
+	Error string `json:"error"`
+}
+
+/*
+Handler type which encodes the value as JSON via `EncodeJSON`, with the given
+status. Zero status is equivalent to 200. Encoding errors are propagated via
+panic, just like routing errors, and are normally returned by `Rou.Route`. See
+`JSONOf` for a shortcut.
+*/
+type JSONVal struct {
+	Status int
+	Val    interface{}
+}
+
+// Implement `http.Handler`.
+func (self JSONVal) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	try(EncodeJSON(rew, req, self.Status, self.Val))
+}
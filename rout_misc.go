@@ -1,11 +1,18 @@
 package rout
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	r "reflect"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
 	u "unsafe"
 )
 
@@ -26,6 +33,46 @@ func (self RouFunc) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
 	}
 }
 
+/*
+Shortcut for `MakeRou(rew, req).Route(fun)`. Routes the given request-response,
+returning the resulting error, if any. See `Rou.Route`.
+*/
+func Route(rew http.ResponseWriter, req *http.Request, fun func(Rou)) error {
+	return MakeRou(rew, req).Route(fun)
+}
+
+/*
+Shortcut for `MakeRou(rew, req).Serve(fun)`. Routes the given request-response,
+writing the resulting error, if any, via `WriteErr`. Example:
+
+	func handleRequest(rew http.ResponseWriter, req *http.Request) {
+		rout.Serve(rew, req, myRoutes)
+	}
+*/
+func Serve(rew http.ResponseWriter, req *http.Request, fun func(Rou)) {
+	MakeRou(rew, req).Serve(fun)
+}
+
+/*
+Combines multiple routing funcs into one, which invokes them in order with the
+same router. Stops early once the request is handled. Nil funcs are ignored.
+Allows feature packages to export their routes separately:
+
+	rout.MakeRou(rew, req).Serve(rout.Join(articles.Routes, users.Routes))
+*/
+func Join(funs ...func(Rou)) func(Rou) {
+	return func(rou Rou) {
+		for _, fun := range funs {
+			if rou.isDone() {
+				return
+			}
+			if fun != nil {
+				fun(rou)
+			}
+		}
+	}
+}
+
 /*
 Type of functions passed to `Rou.Func`. Non-parametrized handler func. Same
 signature as `http.HandlerFunc`, but this is an anonymous type, not a typedef.
@@ -33,6 +80,20 @@ Doesn't implement `http.Handler`.
 */
 type Func = func(http.ResponseWriter, *http.Request)
 
+/*
+Type of functions passed to `Rou.ErrFunc`. Non-parametrized handler func that
+may fail. A non-nil error is propagated out of `Rou.Route` just like routing
+errors, which allows to centralize error handling. The func should return an
+error only if it hasn't written the response yet.
+*/
+type ErrFunc = func(http.ResponseWriter, *http.Request) error
+
+/*
+Type of functions passed to `Rou.ParamErrFunc`. Parametrized handler func that
+may fail. See `ErrFunc` and `ParamFunc`.
+*/
+type ParamErrFunc = func(http.ResponseWriter, *http.Request, []string) error
+
 /*
 Type of functions passed to `Rou.ParamFunc`. Parametrized handler func. Takes
 additional args produced by capture groups, which are supported by `Rou.Reg`
@@ -40,6 +101,15 @@ and `Rou.Pat`. Args start at index 0, not 1 like in a regexp match.
 */
 type ParamFunc = func(http.ResponseWriter, *http.Request, []string)
 
+/*
+Type of functions passed to `Rou.ParamMapFunc`. Parametrized handler func which
+takes named captures as a map, keyed by capture group names such as "{id}" in
+`Pat` or "(?P<id>...)" in regexps. Unnamed captures are omitted. Unlike
+positional args in `ParamFunc`, this doesn't silently break when a pattern
+gains a new capture group.
+*/
+type ParamMapFunc = func(http.ResponseWriter, *http.Request, map[string]string)
+
 /*
 Type of functions passed to `Rou.Han`. Short for "handler" or "handlerer". The
 returned `http.Handler` is used to write the response. To represent responses
@@ -47,33 +117,145 @@ with handlers, use "github.com/mitranim/goh".
 */
 type Han = func(*http.Request) http.Handler
 
+/*
+Type of functions passed to `Rou.HanErr`. Variant of `Han` that may fail, for
+example when decoding or validating the request. A non-nil error is propagated
+out of `Rou.Route` just like routing errors.
+*/
+type HanErr = func(*http.Request) (http.Handler, error)
+
 /*
 Type of functions passed to `Rou.ParamHan`. Short for "parametrized
 handler/handlerer".
 */
 type ParamHan = func(*http.Request, []string) http.Handler
 
+/*
+Type of functions passed to `Rou.ParamMapHan`. Variant of `ParamHan` which
+takes named captures as a map. See `ParamMapFunc`.
+*/
+type ParamMapHan = func(*http.Request, map[string]string) http.Handler
+
 /*
 Type of functions passed to `Rou.Res`. Short for "responder". The returned
 `*http.Response` is sent back via the function `Respond`.
 */
 type Res = func(*http.Request) *http.Response
 
+/*
+Type of functions passed to `Rou.ResErr`. Variant of `Res` that may fail, for
+example when encoding the response. A non-nil error is propagated out of
+`Rou.Route` just like routing errors.
+*/
+type ResErr = func(*http.Request) (*http.Response, error)
+
 /*
 Type of functions passed to `Rou.ParamRes`. Short for "parametrized responder".
 */
 type ParamRes = func(*http.Request, []string) *http.Response
 
 /*
-Writes the given response. Used internally by `Rou.Res` and `Rou.ParamRes`. If
+Type of functions passed to `Rou.Stream`. Returns the response header, the
+status, and a function which writes the response body, possibly in multiple
+chunks. The writer passed to the body function implements `http.Flusher`,
+which sends the chunks written so far to the client. A nil body function
+writes only the header and status. A zero status is treated as 200.
+*/
+type Stream = func(*http.Request) (http.Header, int, func(io.Writer) error)
+
+/*
+Type of functions passed to `Rou.Reply`. Returns the response status and a
+value to be encoded as the response body. See `Rou.Encoder`.
+*/
+type Reply = func(*http.Request) (int, interface{})
+
+/*
+Type of functions passed to `Rou.Encoder`. Writes the given status and value
+as the response. Must set the appropriate "Content-Type" header. A nil value
+is never passed; in that case, only the status is written. `EncodeJSON` is the
+default.
+*/
+type Encoder = func(http.ResponseWriter, *http.Request, int, interface{}) error
+
+/*
+Default `Encoder` used by `Rou.Reply`. Writes the given value as JSON with the
+given status, treating zero as 200.
+*/
+func EncodeJSON(rew http.ResponseWriter, _ *http.Request, status int, val interface{}) error {
+	rew.Header().Set(`Content-Type`, `application/json`)
+	if status != 0 {
+		rew.WriteHeader(status)
+	}
+	return json.NewEncoder(rew).Encode(val)
+}
+
+/*
+Writes the given response. Used internally by `Rou.Res` and `Rou.ParamRes`,
+unless overridden via `Rou.Responder`. If
 either the response writer or the response is nil, this is a nop. Uses
-`res.Header`, `res.StatusCode`, and `res.Body`, ignoring all other fields of
-the response. The returned error, if any, always comes from copying the body
-via `io.Copy`, and should occur mostly due to premature client disconnect.
+`res.Header`, `res.StatusCode`, `res.ContentLength`, and `res.Body`, ignoring
+all other fields of the response. When the length is known, sets the
+"Content-Length" header unless already present, avoiding chunked encoding.
+A zero length is considered known only when the body is nil or `http.NoBody`,
+because responses constructed by hand often have a body without specifying
+its length. When the length is unknown and the body is a regular file, such as
+`*os.File`, the length is obtained from the file. The body is copied via the
+writer's `io.ReaderFrom` when available, which for `http.Server` allows to
+send files via "sendfile" without copying them through user space. The
+returned error, if any, always comes from copying the body, is always
+`ErrRespond`, and should occur mostly due to premature client disconnect,
+which can be detected via `errors.Is(err, rout.ErrClientAbort)`.
 */
 func Respond(rew http.ResponseWriter, res *http.Response) error {
+	return Responder{}.Respond(rew, res)
+}
+
+/*
+Same as `Respond`, but also returns the amount of body bytes written, which
+is useful for logging and metrics.
+*/
+func RespondN(rew http.ResponseWriter, res *http.Response) (int64, error) {
+	return Responder{}.RespondN(rew, res)
+}
+
+/*
+Options for writing responses. The zero value is equivalent to `Respond`. See
+`Rou.Responder` for using this with `Rou.Res` and similar methods. Enabling
+either flushing option makes the response suitable for streamed bodies, such
+as proxied responses or long polls, which would otherwise be buffered by the
+response writer. When flushing is enabled, headers are flushed before copying
+the body. Flushing requires the response writer to implement `http.Flusher`,
+possibly via `Unwrap() http.ResponseWriter`; otherwise it's skipped.
+
+	* `.FlushSize`: when positive, flush after writing at least this many bytes
+	  since the previous flush.
+
+	* `.FlushInterval`: when positive, flush data written since the previous
+	  flush after this much time, even if the body is blocked waiting for more
+	  data. When negative, flush after every write.
+
+	* `.BufSize`: size of the buffer used for copying the body, which also
+	  limits the size of each write. Buffers are pooled per size, reducing
+	  allocations; sizes should be fixed for the lifetime of the program. Zero
+	  uses 32 KiB. Unused when the response writer implements `io.ReaderFrom`,
+	  like the writer of `http.Server`, which has its own buffers.
+*/
+type Responder struct {
+	FlushSize     int
+	FlushInterval time.Duration
+	BufSize       int
+}
+
+// Same as `Respond`, but uses the options of this responder.
+func (self Responder) Respond(rew http.ResponseWriter, res *http.Response) error {
+	_, err := self.RespondN(rew, res)
+	return err
+}
+
+// Same as `RespondN`, but uses the options of this responder.
+func (self Responder) RespondN(rew http.ResponseWriter, res *http.Response) (int64, error) {
 	if rew == nil || res == nil {
-		return nil
+		return 0, nil
 	}
 
 	head := rew.Header()
@@ -81,6 +263,13 @@ func Respond(rew http.ResponseWriter, res *http.Response) error {
 		head[key] = vals
 	}
 
+	if head.Get(`Content-Length`) == `` {
+		size, ok := resLength(res)
+		if ok {
+			head.Set(`Content-Length`, strconv.FormatInt(size, 10))
+		}
+	}
+
 	status := res.StatusCode
 	if status != 0 && status != http.StatusOK {
 		rew.WriteHeader(status)
@@ -88,12 +277,65 @@ func Respond(rew http.ResponseWriter, res *http.Response) error {
 
 	body := res.Body
 	if body == nil {
-		return nil
+		return 0, nil
 	}
 	defer body.Close()
 
-	_, err := io.Copy(rew, body)
-	return err
+	if !self.flushes() {
+		return copyRes(rew, body, self.BufSize)
+	}
+
+	flush(rew)
+	out := flushWriter{Rew: rew, Size: self.FlushSize, Interval: self.FlushInterval}
+	defer out.Stop()
+	return copyRes(&out, body, self.BufSize)
+}
+
+func (self Responder) flushes() bool {
+	return self.FlushSize > 0 || self.FlushInterval != 0
+}
+
+/*
+Sends an informational (1xx) response with the given status, adding the given
+headers to the response writer's headers, which are sent along with it. The
+status must be between 100 and 199, excluding "101 Switching Protocols";
+otherwise this is a nop, and so is a nil response writer. Must be called
+before writing the final response. Informational responses may be sent
+multiple times. Headers remain in the response writer and are also sent with
+the final response, unless deleted. Requires support from the response writer;
+`http.Server` supports this since Go 1.19. See `EarlyHints`.
+*/
+func WriteInfo(rew http.ResponseWriter, status int, head http.Header) {
+	if rew == nil || !isInfoStatus(status) {
+		return
+	}
+
+	out := rew.Header()
+	for key, vals := range head {
+		for _, val := range vals {
+			out.Add(key, val)
+		}
+	}
+	rew.WriteHeader(status)
+}
+
+/*
+Sends "103 Early Hints" with the given "Link" header values, allowing clients
+to preload resources before the final response. Each value should be a
+complete link, such as "</style.css>; rel=preload; as=style". If there are no
+links, this is a nop. See `WriteInfo` for the rules, and `Rou.Hints` for the
+router-level equivalent. Example:
+
+	func pageIndex(rew http.ResponseWriter, req *http.Request) {
+		rout.EarlyHints(rew, `</style.css>; rel=preload; as=style`)
+		renderSlowPage(rew, req)
+	}
+*/
+func EarlyHints(rew http.ResponseWriter, links ...string) {
+	if len(links) == 0 {
+		return
+	}
+	WriteInfo(rew, http.StatusEarlyHints, http.Header{`Link`: links})
 }
 
 /*
@@ -109,27 +351,334 @@ func WriteErr(rew http.ResponseWriter, err error) {
 	if err == nil {
 		return
 	}
+	errAllow(rew, err)
 	rew.WriteHeader(ErrStatusFallback(err))
 	_, _ = io.WriteString(rew, err.Error())
 }
 
+/*
+Type of functions which write routing errors, such as the method
+`NegotiateErr.WriteErr`. The error is always non-nil. See `Rou.ErrWriter` and
+`SetDefaultErrWriter`. For the simpler format, see `WriteErr`.
+*/
+type ErrWriter = func(http.ResponseWriter, *http.Request, error)
+
+/*
+Sets the error writer used by `Rou.Serve`, `RouFunc`, and `Server` when no
+other writer is specified. When nil (default), they use `WriteErr`. Meant to
+be called once during program initialization, for example with
+`NegotiateErr{}.WriteErr`. Safe for concurrent use, but requests already being
+served may still use the previous writer. For per-router or per-server
+settings, prefer `Rou.ErrWriter` and `Server.OnErr`.
+*/
+func SetDefaultErrWriter(fun ErrWriter) { defaultErrWriter.Store(errWriterBox{fun}) }
+
+// Returns the error writer set via `SetDefaultErrWriter`, or nil.
+func DefaultErrWriter() ErrWriter {
+	val, _ := defaultErrWriter.Load().(errWriterBox)
+	return val.Fun
+}
+
+/*
+Stores `ErrWriter` in `atomic.Value`, which requires a consistent concrete type
+and doesn't allow to store nil.
+*/
+type errWriterBox struct{ Fun ErrWriter }
+
+var defaultErrWriter atomic.Value
+
+/*
+JSON counterpart of `WriteErr`. If the error is nil, do nothing. If the error
+is non-nil, write it as JSON with "Content-Type: application/json", in the
+following format, where the status is obtained via `rout.ErrStatusFallback`:
+
+	{"error": {"status": 404, "message": "..."}}
+
+If the error implements `json.Marshaler`, like `ErrRoute`, its encoding is
+used as the value of "error" instead. Example:
+
+	rout.WriteErrJSON(rew, rout.MakeRou(rew, req).Route(myRoutes))
+*/
+func WriteErrJSON(rew http.ResponseWriter, err error) {
+	if err == nil {
+		return
+	}
+
+	errAllow(rew, err)
+
+	var body errJSON
+	val, ok := err.(json.Marshaler)
+	if ok {
+		body.Error = val
+	} else {
+		body.Error = errJSONBody{ErrStatusFallback(err), err.Error()}
+	}
+
+	_ = EncodeJSON(rew, nil, ErrStatusFallback(err), body)
+}
+
+type errJSON struct {
+	Error interface{} `json:"error"`
+}
+
+type errJSONBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+/*
+Writes the given error as "Problem Details" (RFC 7807 and RFC 9457), with
+"Content-Type: application/problem+json". If the error is nil, do nothing. The
+body has the following members:
+
+	* "type": "about:blank".
+	* "title": standard text for the status, via `http.StatusText`.
+	* "status": status obtained via `rout.ErrStatusFallback`.
+	* "detail": the error message.
+
+Additional members are obtained from the following hidden interface, which may
+be implemented by deeply-wrapped errors. Returned members override the defaults
+listed above, except "status", and may include "type", "instance", and
+arbitrary extension members.
+
+	interface { ProblemDetails() map[string]interface{} }
+
+Example:
+
+	rout.WriteErrProblem(rew, rout.MakeRou(rew, req).Route(myRoutes))
+*/
+func WriteErrProblem(rew http.ResponseWriter, err error) {
+	if err == nil {
+		return
+	}
+
+	status := ErrStatusFallback(err)
+	body := map[string]interface{}{
+		`type`:   `about:blank`,
+		`title`:  http.StatusText(status),
+		`detail`: err.Error(),
+	}
+
+	var src errProblem
+	if errors.As(err, &src) {
+		for key, val := range src.ProblemDetails() {
+			body[key] = val
+		}
+	}
+	body[`status`] = status
+
+	errAllow(rew, err)
+	rew.Header().Set(`Content-Type`, `application/problem+json`)
+	rew.WriteHeader(status)
+	_ = json.NewEncoder(rew).Encode(body)
+}
+
+type errProblem interface {
+	ProblemDetails() map[string]interface{}
+}
+
+/*
+Error writer which chooses the format based on the request's `Accept` header:
+plain text via `WriteErr`, JSON via `WriteErrJSON`, problem details via
+`WriteErrProblem`, or HTML via the optional `.HTML` function, which should
+render an error page with the status obtained via `ErrStatusFallback`. Picks
+the type with the highest q-value; ties, including a missing `Accept` header,
+are resolved in the order listed above, so plain text is the default. Meant
+for servers that mix HTML pages and APIs. Example:
+
+	rout.Server{Routes: myRoutes, OnErr: rout.NegotiateErr{HTML: errPage}.WriteErr}
+*/
+type NegotiateErr struct {
+	HTML func(http.ResponseWriter, *http.Request, error)
+}
+
+/*
+Writes the error in the negotiated format. If the error is nil, do nothing.
+Has the same signature as `Server.OnErr` and `Rou.OnErr`.
+*/
+func (self NegotiateErr) WriteErr(rew http.ResponseWriter, req *http.Request, err error) {
+	if err == nil {
+		return
+	}
+
+	switch self.format(req) {
+	case `application/json`:
+		WriteErrJSON(rew, err)
+	case `application/problem+json`:
+		WriteErrProblem(rew, err)
+	case `text/html`:
+		self.HTML(rew, req, err)
+	default:
+		WriteErr(rew, err)
+	}
+}
+
+func (self NegotiateErr) format(req *http.Request) string {
+	head := reqHeader(req).Values(`Accept`)
+	out := `text/plain`
+	best := acceptQ(head, out)
+
+	for _, typ := range [...]string{`application/json`, `application/problem+json`, `text/html`} {
+		if typ == `text/html` && self.HTML == nil {
+			continue
+		}
+		cur := acceptQ(head, typ)
+		if cur > best {
+			out, best = typ, cur
+		}
+	}
+	return out
+}
+
+/*
+Error writer which renders HTML error pages via user-supplied templates, for
+server-rendered sites that shouldn't show plain-text errors. The status is
+obtained via `ErrStatusFallback`. The template is chosen from `.Pages` by
+status, falling back on `.Default`. If neither is available, or if rendering
+fails, falls back on `WriteErr`. Templates are rendered into a buffer before
+writing the response, and receive `ErrPageData`. Compatible with both
+"html/template" and "text/template"; the former is recommended, because it
+escapes the error message. Example:
+
+	var errPage = rout.ErrPage{
+		Default: template.Must(template.ParseFiles(`error.html`)),
+		Pages: map[int]rout.ErrTemplate{
+			http.StatusNotFound: template.Must(template.ParseFiles(`404.html`)),
+		},
+	}
+
+	rout.Server{Routes: myRoutes, OnErr: errPage.WriteErr}
+
+Can also be combined with content negotiation:
+
+	rout.NegotiateErr{HTML: errPage.WriteErr}
+*/
+type ErrPage struct {
+	Default ErrTemplate
+	Pages   map[int]ErrTemplate
+}
+
+/*
+Renders the error page. If the error is nil, do nothing. Has the same
+signature as `Server.OnErr` and `Rou.OnErr`.
+*/
+func (self ErrPage) WriteErr(rew http.ResponseWriter, req *http.Request, err error) {
+	if err == nil {
+		return
+	}
+
+	status := ErrStatusFallback(err)
+	tpl := self.Template(status)
+	if tpl == nil {
+		WriteErr(rew, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if tpl.Execute(&buf, ErrPageData{
+		Status:  status,
+		Title:   http.StatusText(status),
+		Message: err.Error(),
+		Err:     err,
+		Req:     req,
+	}) != nil {
+		WriteErr(rew, err)
+		return
+	}
+
+	errAllow(rew, err)
+	rew.Header().Set(`Content-Type`, `text/html; charset=utf-8`)
+	rew.WriteHeader(status)
+	_, _ = buf.WriteTo(rew)
+}
+
+// Returns the template for the given status, falling back on `.Default`.
+func (self ErrPage) Template(status int) ErrTemplate {
+	val := self.Pages[status]
+	if val != nil {
+		return val
+	}
+	return self.Default
+}
+
+/*
+Interface of templates used by `ErrPage`. Implemented by `*template.Template`
+from both "html/template" and "text/template".
+*/
+type ErrTemplate interface {
+	Execute(io.Writer, interface{}) error
+}
+
+/*
+Data passed to templates by `ErrPage`. `.Title` is the standard text for the
+status, via `http.StatusText`. `.Message` is the error message, which respects
+`Rou.Redact`.
+*/
+type ErrPageData struct {
+	Status  int
+	Title   string
+	Message string
+	Err     error
+	Req     *http.Request
+}
+
 /*
 Returns the underlying HTTP status code of the given error, relying on the
-following hidden interface which is implemented by `rout.Err`. The interface
-may be implemented by deeply-wrapped errors; this performs deep unwrapping.
+following hidden interfaces. The first is implemented by errors in this
+package; the others are conventions used by other libraries. The interfaces
+may be implemented by deeply-wrapped errors; this performs deep unwrapping,
+including errors joined via `errors.Join` or `fmt.Errorf` with multiple "%w",
+returning the first status found in depth-first order.
 
 	interface { HttpStatusCode() int }
+	interface { StatusCode() int }
+	interface { HTTPStatus() int }
 
-If the error is nil or doesn't implement this interface, status is 0.
-If you always want a non-zero code, use `ErrStatusFallback` which falls
-back on 500.
+If no error in the chain implements these interfaces, the status is obtained
+from mappings registered via `RegisterErrStatus` and `RegisterErrStatusType`,
+if any. If the error is nil or no status is found, status is 0. If you always
+want a non-zero code, use `ErrStatusFallback` which falls back on 500.
 */
 func ErrStatus(err error) int {
 	code := errStatusDeep(err)
 	if code != 0 {
 		return code
 	}
-	return 0
+	return errStatusRegistered(err)
+}
+
+/*
+Registers a mapping from the given error value to the given HTTP status, used
+by `ErrStatus` for errors which don't specify a status. Matching uses
+`errors.Is`, and supports wrapped errors. Mappings are checked in the order of
+registration. Meant to be called during program initialization, but safe for
+concurrent use. Example:
+
+	func init() {
+		rout.RegisterErrStatus(sql.ErrNoRows, http.StatusNotFound)
+		rout.RegisterErrStatus(os.ErrNotExist, http.StatusNotFound)
+		rout.RegisterErrStatus(context.DeadlineExceeded, http.StatusGatewayTimeout)
+	}
+*/
+func RegisterErrStatus(target error, status int) {
+	if target == nil {
+		return
+	}
+	errStatusRegister(func(err error) bool { return errors.Is(err, target) }, status)
+}
+
+/*
+Registers a mapping from the error type `A` to the given HTTP status, used by
+`ErrStatus` for errors which don't specify a status. Matching uses
+`errors.As`, and supports wrapped errors. See `RegisterErrStatus`. Example:
+
+	rout.RegisterErrStatusType[*json.SyntaxError](http.StatusBadRequest)
+*/
+func RegisterErrStatusType[A error](status int) {
+	errStatusRegister(func(err error) bool {
+		var val A
+		return errors.As(err, &val)
+	}, status)
 }
 
 /*
@@ -171,10 +720,38 @@ func (self Coalesce) Han(req *http.Request) http.Handler {
 	return nil
 }
 
+/*
+HTTP handler type that stores multiple `Res` functions, and when serving HTTP,
+uses `Respond` to write the first non-nil `*http.Response` returned by one of
+those functions. Counterpart of `Coalesce`. The error from `Respond`, if any,
+is ignored; it usually indicates a client disconnect. Also see `Rou.ResAny`,
+which propagates that error.
+*/
+type CoalesceRes []Res
+
+// Implement `http.Handler`.
+func (self CoalesceRes) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	_ = Respond(rew, self.Res(req))
+}
+
+// Invokes the funcs in order, returning the first resulting non-nil response.
+func (self CoalesceRes) Res(req *http.Request) *http.Response {
+	for _, fun := range self {
+		if fun != nil {
+			val := fun(req)
+			if val != nil {
+				return val
+			}
+		}
+	}
+	return nil
+}
+
 /*
 Various types of pattern matching supported by this package: exact,
-start/prefix, regexp, OAS-style pattern. See the comments on the constants such
-as `MatchExa`.
+start/prefix, regexp, OAS-style pattern, mux-style pattern, colon-style pattern,
+start/prefix with capture. See the comments on the constants such as
+`MatchExa`.
 */
 type Match byte
 
@@ -213,6 +790,35 @@ const (
 	empty pattern `` matches any input.
 	*/
 	MatchPat
+
+	/**
+	Short for "mux", specifically path pattern compatible with `http.ServeMux`
+	since Go 1.22. Used by `Rou.Mux`. Performs matching or submatching by
+	converting its pattern to `MuxPat`, which is also exported by this package.
+	Compiles each pattern only once, with caching and reuse. Does support
+	capture groups. The empty pattern `` matches any input.
+	*/
+	MatchMux
+
+	/**
+	Short for "colon", specifically path pattern compatible with
+	"github.com/julienschmidt/httprouter" and similar libraries, such as
+	"/users/:id" or "/files/*path". Used by `Rou.Col`. Performs matching or
+	submatching by converting its pattern to `MuxPat` via
+	`(*MuxPat).ParseColon`. Compiles each pattern only once, with caching and
+	reuse. Does support capture groups. The empty pattern `` matches any input.
+	*/
+	MatchCol
+
+	/**
+	Short for "parametrized start". Used by `Rou.ParamSta`. Matches like
+	`MatchSta`, and captures the rest of the input after the prefix, without
+	modification. For example, the pattern "/files" matches "/files/one/two"
+	and captures "/one/two", while the pattern "/files/" captures "one/two".
+	Always has exactly one capture group. The empty pattern `` matches any
+	input and captures all of it.
+	*/
+	MatchParamSta
 )
 
 // Implement `fmt.Stringer` for debug purposes.
@@ -226,6 +832,12 @@ func (self Match) String() string {
 		return `reg`
 	case MatchPat:
 		return `pat`
+	case MatchMux:
+		return `mux`
+	case MatchCol:
+		return `col`
+	case MatchParamSta:
+		return `paramSta`
 	default:
 		return ``
 	}
@@ -249,6 +861,12 @@ func (self Match) Match(pat, inp string) bool {
 		return matchReg(pat, inp)
 	case MatchPat:
 		return matchPat(pat, inp)
+	case MatchMux:
+		return matchMux(pat, inp)
+	case MatchCol:
+		return matchCol(pat, inp)
+	case MatchParamSta:
+		return matchSta(pat, inp)
 	default:
 		return false
 	}
@@ -262,6 +880,9 @@ regexps.
 */
 func (self Match) Submatch(pat, inp string) []string {
 	if pat == `` {
+		if self == MatchParamSta {
+			return []string{inp}
+		}
 		return []string{}
 	}
 
@@ -274,18 +895,40 @@ func (self Match) Submatch(pat, inp string) []string {
 		return submatchReg(pat, inp)
 	case MatchPat:
 		return submatchPat(pat, inp)
+	case MatchMux:
+		return submatchMux(pat, inp)
+	case MatchCol:
+		return submatchCol(pat, inp)
+	case MatchParamSta:
+		return submatchParamSta(pat, inp)
 	default:
 		return nil
 	}
 }
 
+/*
+Returns the names of capture groups in the given pattern, positionally, in the
+same order as the captures returned by `Match.Submatch`. Unnamed capture
+groups, such as "{}" in `Pat`, have empty names. Styles without capture groups
+return nil. Results are cached and must not be mutated.
+*/
+func (self Match) Names(pat string) []string {
+	if self == MatchParamSta {
+		return paramStaNames
+	}
+	if pat == `` {
+		return nil
+	}
+	return cachedNames(self, pat)
+}
+
 /*
 Tool for introspection. Returns the "identity" of the input: the internal
 representation of the interface value that was passed in. When performing
 a "dry run" via `Visit`, this function generates the identity of route
-handlers. Advanced users of this package may build a registry that maps handler
+handlers. Advanced users of this package may use a registry that maps handler
 identities to arbitrary metadata, and retrieve that information from visited
-routes, using idents as keys.
+routes, using idents as keys. See `Registry`.
 */
 func Ident(val interface{}) [2]uintptr {
 	return *(*[2]uintptr)(u.Pointer(&val))
@@ -300,9 +943,40 @@ func IdentType(val [2]uintptr) r.Type {
 	return r.TypeOf(*(*interface{})(u.Pointer(&val)))
 }
 
+/*
+Tool for introspection. Returns a human-readable name of the function or type
+of the value identified by the given `Ident`. For functions, this is the name
+reported by `runtime.FuncForPC`, without the package path, such as
+"main.apiArticleGet"; closures have generated names such as
+"main.routes.func1". For other values, this is the name of the type, such as
+"rout.Str". For zero, returns "". Because `Ident` doesn't keep the value
+alive, this should be called while the value is still reachable. `Visit`
+uses this to set `EndpointInfo.HandlerName`.
+*/
+func IdentName(val [2]uintptr) string {
+	typ := IdentType(val)
+	if typ == nil {
+		return ``
+	}
+
+	if typ.Kind() == r.Func {
+		if val[1] == 0 {
+			return ``
+		}
+		src := *(*interface{})(u.Pointer(&val))
+		fun := runtime.FuncForPC(r.ValueOf(src).Pointer())
+		if fun != nil {
+			return trimPkgPath(fun.Name())
+		}
+	}
+	return typ.String()
+}
+
 /*
 Tool for introspection. Passed to `Visitor` when performing a "dry run" via the
-`Visit` function.
+`Visit` function, and stored in `Mut.Endpoint` after a successful match. For
+annotations and other introspection data, see `EndpointInfo` and
+`InfoVisitor`.
 */
 type Endpoint struct {
 	Pattern string
@@ -311,16 +985,111 @@ type Endpoint struct {
 	Handler [2]uintptr
 }
 
+/*
+Introspection data of an `Endpoint`, made by `Visit` and passed to visitors
+which implement `InfoVisitor`. Embeds the endpoint itself. `.Name` and `.Desc`
+are optional annotations set via `Rou.Name` and `Rou.Desc`. `.Deprecated` and
+`.Sunset` are set via `Rou.Deprecated`. `.HandlerName` is the name of the
+handler resolved via `IdentName`, such as "main.apiArticleGet". `.Parent` is
+the innermost enclosing block, such as `Rou.Sub`, which links to the outer
+blocks, allowing to reconstruct the routing tree; see `EndpointInfo.Parents`
+and `EndpointInfo.Depth`. Endpoints declared in the same block share the same
+`*Scope`, allowing to group them by comparing pointers.
+*/
+type EndpointInfo struct {
+	Endpoint
+	HandlerName string
+	Name        string
+	Desc        string
+	Deprecated  bool
+	Sunset      time.Time
+	Parent      *Scope
+}
+
+/*
+Returns the enclosing blocks of the endpoint, from outermost to innermost, by
+following `.Parent`. Returns nil for top-level endpoints.
+*/
+func (self EndpointInfo) Parents() (out []Scope) {
+	for val := self.Parent; val != nil; val = val.Parent {
+		out = append(out, *val)
+	}
+	for ind := 0; ind < len(out)/2; ind++ {
+		out[ind], out[len(out)-1-ind] = out[len(out)-1-ind], out[ind]
+	}
+	return
+}
+
+/*
+Nesting depth of the endpoint: the number of enclosing blocks. Zero for
+top-level endpoints.
+*/
+func (self EndpointInfo) Depth() (out int) {
+	for val := self.Parent; val != nil; val = val.Parent {
+		out++
+	}
+	return
+}
+
+/*
+Block enclosing an endpoint, such as `Rou.Sub`, `Rou.Methods`, or `Rou.Group`,
+with the pattern of the router which declared the block. The pattern may be
+empty, for example in a `Rou.Group` without a pattern. `.Parent` is the
+next enclosing block, if any. See `EndpointInfo.Parent`.
+*/
+type Scope struct {
+	Pattern string
+	Match   Match
+	Parent  *Scope
+}
+
 /*
 Tool for introspection. Performs a "dry run" of the given routing function,
 visiting all routes without executing any handlers. During the dry run, the
 `http.ResponseWriter` contained in the router is a special nop type that
 discards all writes.
 */
-func Visit(fun func(Rou), vis Visitor) {
-	rou := MakeRou(NopRew{}, &http.Request{URL: new(url.URL)})
+func Visit(fun func(Rou), vis Visitor) { visitReq(fun, vis, nil) }
+
+/*
+Variant of `Visit` which performs a separate dry run for each of the given
+requests, in order, instead of using an empty request. Allows to enumerate
+endpoints reachable under each representative request, when the routing
+function branches on the host, headers, or other request properties, such as
+feature flags stored in the request context. Endpoints reachable under several
+requests are visited once per request. The requests are used only for
+reading, and may lack a URL. Nil requests are replaced with an empty request.
+Without requests, this is equivalent to `Visit`. Example:
+
+	reqs := []*http.Request{
+		httptest.NewRequest(http.MethodGet, `https://example.com`, nil),
+		httptest.NewRequest(http.MethodGet, `https://admin.example.com`, nil),
+	}
+	rout.VisitReq(myRoutes, myVisitor, reqs...)
+*/
+func VisitReq(fun func(Rou), vis Visitor, reqs ...*http.Request) {
+	if len(reqs) == 0 {
+		Visit(fun, vis)
+		return
+	}
+	for _, req := range reqs {
+		visitReq(fun, vis, req)
+	}
+}
+
+func visitReq(fun func(Rou), vis Visitor, req *http.Request) {
+	if req == nil {
+		req = &http.Request{URL: new(url.URL)}
+	} else if req.URL == nil {
+		req = req.WithContext(req.Context())
+		req.URL = new(url.URL)
+	}
+
+	rou := MakeRou(NopRew{}, req)
 	rou.Vis = vis
-	rou.Sub(fun)
+	if fun != nil {
+		fun(rou)
+	}
 }
 
 /*
@@ -339,6 +1108,35 @@ func (self VisitorFunc) Endpoint(val Endpoint) {
 	}
 }
 
+/*
+Tool for introspection. Optional extension of `Visitor`. When the visitor
+passed to `Visit` implements this interface, the dry run calls
+`.EndpointInfo` instead of `.Endpoint`, passing the endpoint along with its
+annotations and enclosing blocks. See `EndpointInfo`.
+*/
+type InfoVisitor interface {
+	Visitor
+	EndpointInfo(EndpointInfo)
+}
+
+/*
+Shortcut type. Implements `InfoVisitor` by calling itself. When used as a plain
+`Visitor`, passes the endpoint without annotations.
+*/
+type InfoVisitorFunc func(EndpointInfo)
+
+// Implement `Visitor` by calling itself.
+func (self InfoVisitorFunc) Endpoint(val Endpoint) {
+	self.EndpointInfo(EndpointInfo{Endpoint: val})
+}
+
+// Implement `InfoVisitor` by calling itself.
+func (self InfoVisitorFunc) EndpointInfo(val EndpointInfo) {
+	if self != nil {
+		self(val)
+	}
+}
+
 /*
 Tool for introspection. Simplified version of `Visitor` that doesn't "know"
 about the multiple pattern types supported by this package. Must be wrapped by
@@ -370,25 +1168,14 @@ func (self RegexpVisitor) Endpoint(val Endpoint) {
 		return
 	}
 
-	switch val.Match {
-	case MatchExa:
-		self[0].Endpoint(exaToReg(val.Pattern), val.Method, val.Handler)
-
-	case MatchSta:
-		self[0].Endpoint(staToReg(val.Pattern), val.Method, val.Handler)
-
-	case MatchReg:
-		self[0].Endpoint(val.Pattern, val.Method, val.Handler)
-
-	case MatchPat:
-		self[0].Endpoint(patToReg(val.Pattern), val.Method, val.Handler)
-
-	default:
+	reg, ok := matchToReg(val.Match, val.Pattern)
+	if !ok {
 		panic(fmt.Errorf(
 			`[rout] unable to convert match %q for route %q %q to regex`,
 			val.Match, val.Pattern, val.Method,
 		))
 	}
+	self[0].Endpoint(reg, val.Method, val.Handler)
 }
 
 /*
@@ -411,6 +1198,12 @@ func (self PatternVisitor) Endpoint(val Endpoint) {
 	case MatchPat:
 		self[0].Endpoint(val.Pattern, val.Method, val.Handler)
 
+	case MatchMux:
+		self[0].Endpoint(muxToPat(val.Pattern), val.Method, val.Handler)
+
+	case MatchCol:
+		self[0].Endpoint(colToPat(val.Pattern), val.Method, val.Handler)
+
 	default:
 		panic(fmt.Errorf(
 			`[rout] unable to convert match %q for route %q %q to OAS pattern`,
@@ -421,12 +1214,105 @@ func (self PatternVisitor) Endpoint(val Endpoint) {
 
 /*
 Nop implementation of `http.ResponseWriter` used internally by `Visit`.
-Exported for implementing custom variants of `Visit`.
+Exported for implementing custom variants of `Visit`. Also implements
+`http.Flusher` as a nop, allowing streaming handlers to run against it.
 */
 type NopRew struct{}
 
-var _ = http.ResponseWriter(NopRew{})
+var (
+	_ = http.ResponseWriter(NopRew{})
+	_ = http.Flusher(NopRew{})
+)
 
 func (NopRew) Header() http.Header           { return http.Header{} }
 func (NopRew) WriteHeader(int)               {}
 func (NopRew) Write(val []byte) (int, error) { return len(val), nil }
+func (NopRew) Flush()                        {}
+
+/*
+Implementation of `http.ResponseWriter` which records the status, headers, and
+body of the response. Unlike `httptest.ResponseRecorder`, meant for use in
+production code, for example for shadowing traffic by routing a copy of the
+request into a recorder and comparing the result, or for capturing a response
+while also sending it to the client. When `.Rew` is non-nil, every operation is
+forwarded to it, the headers are shared with it, and `.Unwrap` returns it,
+allowing `http.ResponseController` to reach it. Otherwise, flushing is a nop.
+The status is recorded on the first call to `.WriteHeader` with a non-1xx
+status, or on the first write, which implies 200. Informational (1xx)
+statuses are forwarded but not recorded. Not synchronized. Example:
+
+	var rec rout.RecordRew
+	rout.MakeRou(&rec, req).Serve(myRoutes)
+	res := rec.Res()
+*/
+type RecordRew struct {
+	Rew    http.ResponseWriter
+	Head   http.Header
+	Status int
+	Body   bytes.Buffer
+}
+
+var (
+	_ = http.ResponseWriter((*RecordRew)(nil))
+	_ = http.Flusher((*RecordRew)(nil))
+)
+
+// Implement `http.ResponseWriter`.
+func (self *RecordRew) Header() http.Header {
+	if self.Head == nil {
+		if self.Rew != nil {
+			self.Head = self.Rew.Header()
+		} else {
+			self.Head = http.Header{}
+		}
+	}
+	return self.Head
+}
+
+// Implement `http.ResponseWriter`.
+func (self *RecordRew) WriteHeader(status int) {
+	if self.Status == 0 && !isInfoStatus(status) {
+		self.Status = status
+	}
+	if self.Rew != nil {
+		self.Rew.WriteHeader(status)
+	}
+}
+
+// Implement `http.ResponseWriter`.
+func (self *RecordRew) Write(val []byte) (int, error) {
+	if self.Status == 0 {
+		self.WriteHeader(http.StatusOK)
+	}
+	_, _ = self.Body.Write(val)
+	if self.Rew != nil {
+		return self.Rew.Write(val)
+	}
+	return len(val), nil
+}
+
+// Implement `http.Flusher`.
+func (self *RecordRew) Flush() {
+	if self.Status == 0 {
+		self.WriteHeader(http.StatusOK)
+	}
+	flush(self.Rew)
+}
+
+// Returns the underlying writer, if any.
+func (self *RecordRew) Unwrap() http.ResponseWriter { return self.Rew }
+
+/*
+Returns the recorded response, with a copy of the headers and the body. Zero
+status, which means nothing was written, is reported as 200. The result may be
+written via `Respond`.
+*/
+func (self *RecordRew) Res() *http.Response {
+	status := self.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	out := ResBytes(status, ``, bytes.Clone(self.Body.Bytes()))
+	out.Header = self.Header().Clone()
+	return out
+}
@@ -0,0 +1,286 @@
+package rout
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Short for "mux pattern": URL path pattern compatible with the syntax of
+`http.ServeMux` introduced in Go 1.22:
+
+	/items/{id}
+	/items/{id}/{$}
+	/files/{path...}
+	/static/
+
+Supports parsing, matching, and capturing. Once parsed, the pattern is safe for
+concurrent use by multiple goroutines. Used by `MatchMux` and `Rou.Mux`. This
+type describes only the path part of a mux pattern. The optional method prefix
+such as "GET " is handled by `Rou.Mux`. Host prefixes are not supported.
+
+Rules:
+
+	* A literal segment matches the exact same segment, without capturing.
+
+	* A wildcard such as "{id}" matches and captures a single non-empty segment,
+	  like an empty segment in `Pat`.
+
+	* A trailing wildcard such as "{path...}" matches and captures the rest of
+	  the path, which may be empty or contain slashes.
+
+	* A trailing slash without "{$}" matches any path with the given prefix,
+	  like a `http.ServeMux` subtree pattern.
+
+	* A trailing "{$}" matches only the path ending with the slash.
+
+	* Otherwise the pattern matches the entire input.
+
+Just like `Pat`, `MuxPat` discards wildcard names when parsing. Submatching is
+positional, by index.
+*/
+type MuxPat struct {
+	Pat    Pat
+	Rest   bool
+	Prefix bool
+}
+
+/*
+Like `(*regexp.Regexp).MatchString`: returns true if the input matches the
+pattern, without capturing.
+*/
+func (self MuxPat) Match(inp string) bool {
+	return self.match(inp, nil)
+}
+
+/*
+Similar to `(*regexp.Regexp).FindStringSubmatch`: returns nil or positional
+captures. Unlike regexps, the resulting slice has ONLY the captures, without
+the matched string. On success, slice length equals `pat.Num()`.
+*/
+func (self MuxPat) Submatch(inp string) []string {
+	buf := []string{}
+	if self.match(inp, &buf) {
+		return buf
+	}
+	return nil
+}
+
+func (self MuxPat) match(rem string, out *[]string) bool {
+	var subs subs
+
+	rem, ok := self.Pat.consume(rem, &subs)
+	if !ok || (len(rem) != 0 && !self.Rest && !self.Prefix) {
+		return false
+	}
+
+	if out != nil {
+		*out = append(*out, subs.slice()...)
+		if self.Rest {
+			*out = append(*out, rem)
+		}
+	}
+	return true
+}
+
+// Parses the path part of a mux pattern, replacing the receiver.
+func (self *MuxPat) Parse(src string) error {
+	if !hasSlashPrefix(src) {
+		return muxErr(src, `must begin with "/"; method and host prefixes are not supported here`)
+	}
+
+	var pat Pat
+	var lit strings.Builder
+	var rest, end bool
+
+	segs := strings.Split(src[1:], `/`)
+
+	for ind, seg := range segs {
+		last := ind == len(segs)-1
+		lit.WriteByte('/')
+
+		if !strings.ContainsAny(seg, `{}`) {
+			lit.WriteString(seg)
+			continue
+		}
+
+		if !strings.HasPrefix(seg, `{`) || !strings.HasSuffix(seg, `}`) {
+			return muxErr(src, `a wildcard must be an entire path segment`)
+		}
+
+		name := seg[1 : len(seg)-1]
+		if strings.ContainsAny(name, `{}`) {
+			return muxErr(src, `malformed wildcard`)
+		}
+
+		if name == `$` {
+			if !last {
+				return muxErr(src, `"{$}" is only allowed at the end`)
+			}
+			end = true
+			continue
+		}
+
+		if strings.HasSuffix(name, `...`) {
+			if !last {
+				return muxErr(src, `"{...}" wildcard is only allowed at the end`)
+			}
+			rest = true
+			continue
+		}
+
+		if lit.Len() > 0 {
+			pat = append(pat, lit.String())
+			lit.Reset()
+		}
+		pat = append(pat, ``)
+
+		if pat.Num() > subsCap {
+			return muxErr(src, fmt.Sprintf(`found more than %v wildcards`, subsCap))
+		}
+	}
+
+	if lit.Len() > 0 {
+		pat = append(pat, lit.String())
+	}
+
+	*self = MuxPat{
+		Pat:    pat,
+		Rest:   rest,
+		Prefix: !rest && !end && hasSlashSuffix(src),
+	}
+	return nil
+}
+
+/*
+Implement `fmt.Stringer` for debug purposes. The resulting representation is
+functionally equivalent to the original, but wildcard names are lost.
+*/
+func (self MuxPat) String() string {
+	var buf strings.Builder
+	for _, val := range self.Pat {
+		if val == `` {
+			buf.WriteString(segmentTemplate)
+		} else {
+			buf.WriteString(val)
+		}
+	}
+
+	if self.Rest {
+		buf.WriteString(`{...}`)
+	} else if !self.Prefix && strings.HasSuffix(buf.String(), `/`) {
+		buf.WriteString(`{$}`)
+	}
+	return buf.String()
+}
+
+/*
+Same as `(*regexp.Regexp).NumSubexp`. Returns the amount of "capture groups",
+including the trailing "{...}" wildcard, if any.
+*/
+func (self MuxPat) Num() int {
+	out := self.Pat.Num()
+	if self.Rest {
+		out++
+	}
+	return out
+}
+
+/*
+Returns a string representing a regexp pattern that should be equivalent to the
+given mux pattern. See `Pat.Reg` for the general rules.
+*/
+func (self MuxPat) Reg() string {
+	out := strings.TrimSuffix(self.Pat.Reg(), `$`)
+	if self.Rest {
+		return out + `(.*)$`
+	}
+	if self.Prefix {
+		return out
+	}
+	return out + `$`
+}
+
+/*
+Parses a pattern in the syntax of "github.com/julienschmidt/httprouter" and
+similar libraries, replacing the receiver. Used by `MatchCol` and `Rou.Col`.
+Named parameters such as ":id" are equivalent to "{id}" in `Pat`: they match
+and capture a non-empty part of a segment. A catch-all parameter such as
+"*path" is allowed only at the end, after a slash, and is equivalent to the
+"{path...}" wildcard in mux patterns. Unlike httprouter, the catch-all capture
+doesn't include the leading slash.
+*/
+func (self *MuxPat) ParseColon(src string) error {
+	if !hasSlashPrefix(src) {
+		return colErr(src, `must begin with "/"`)
+	}
+
+	var pat Pat
+	var cursor int
+	var rest bool
+
+	for cursor < len(src) {
+		ind := strings.IndexAny(src[cursor:], `:*`)
+		if ind < 0 {
+			pat = append(pat, src[cursor:])
+			break
+		}
+
+		ind += cursor
+		if ind > cursor {
+			pat = append(pat, src[cursor:ind])
+		}
+
+		end := strings.IndexByte(src[ind:], '/')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += ind
+		}
+
+		if end-ind < 2 {
+			return colErr(src, `parameters must be named`)
+		}
+
+		if src[ind] == '*' {
+			if end != len(src) || src[ind-1] != '/' {
+				return colErr(src, `catch-all parameters are only allowed at the end, after "/"`)
+			}
+			rest = true
+			break
+		}
+
+		if strings.ContainsAny(src[ind+1:end], `:*`) {
+			return colErr(src, `only one parameter is allowed per segment`)
+		}
+
+		pat = append(pat, ``)
+		if pat.Num() > subsCap {
+			return colErr(src, fmt.Sprintf(`found more than %v parameters`, subsCap))
+		}
+		cursor = end
+	}
+
+	*self = MuxPat{Pat: pat, Rest: rest}
+	return nil
+}
+
+func colErr(src, msg string) error {
+	return fmt.Errorf(`[rout] invalid colon-style pattern %q: %v`, src, msg)
+}
+
+func muxErr(src, msg string) error {
+	return fmt.Errorf(`[rout] invalid mux pattern %q: %v`, src, msg)
+}
+
+/*
+Splits a mux pattern such as "GET /items/{id}" into the method and the path.
+The method is optional.
+*/
+func muxSplit(src string) (string, string) {
+	ind := strings.IndexAny(src, " \t")
+	if ind < 0 {
+		return ``, src
+	}
+	return src[:ind], strings.TrimLeft(src[ind:], " \t")
+}
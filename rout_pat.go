@@ -69,11 +69,27 @@ func (self Pat) Submatch(inp string) []string {
 func (self Pat) match(rem string, out *[]string) bool {
 	var subs subs
 
+	rem, ok := self.consume(rem, &subs)
+	if !ok || len(rem) != 0 {
+		return false
+	}
+
+	if out != nil {
+		*out = append(*out, subs.slice()...)
+	}
+	return true
+}
+
+/*
+Matches the pattern against a prefix of the input, adding captures to the
+given buffer. On success, returns the remaining unmatched input.
+*/
+func (self Pat) consume(rem string, subs *subs) (string, bool) {
 outer:
 	for _, seg := range self {
 		if seg != `` {
 			if !strings.HasPrefix(rem, seg) {
-				return false
+				return rem, false
 			}
 			rem = rem[len(seg):]
 			continue
@@ -85,25 +101,17 @@ outer:
 		for ind, char = range rem {
 			if char == '/' || char == '?' || char == '#' {
 				if !subs.add(strPop(&rem, ind)) {
-					return false
+					return rem, false
 				}
 				continue outer
 			}
 		}
 
 		if !subs.add(strPop(&rem, ind+1)) {
-			return false
+			return rem, false
 		}
 	}
-
-	if len(rem) != 0 {
-		return false
-	}
-
-	if out != nil {
-		*out = append(*out, subs.slice()...)
-	}
-	return true
+	return rem, true
 }
 
 // Parses the pattern from a string, appending to the receiver.
@@ -0,0 +1,202 @@
+package rout
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Schema of collections made by `Postman`.
+const PostmanSchema = `https://schema.getpostman.com/json/collection/v2.1.0/collection.json`
+
+/*
+Exporter of routes as a Postman collection, which can also be imported by
+other API clients such as Insomnia. Visits all routes of a routing function
+via `Visit`, and makes a request for each endpoint, named after `Rou.Name`
+if set, otherwise after the method and pattern, with the description from
+`Rou.Desc`. Requests use the collection variable "baseUrl", initialized to
+`.BaseURL`. Capture groups become Postman path variables with empty values,
+such as ":id", named after the capture groups, falling back on "arg0", "arg1",
+and so on. Captures which occupy only a part of a path segment become
+variables such as "{{id}}", which must be defined in a Postman environment.
+Endpoints without a method use GET. Regexp patterns are not supported and
+cause an error. `.Name` is the name of the collection. Example:
+
+	err := rout.Postman{Name: `My API`, BaseURL: `http://localhost:8080`}.WriteFile(`api.postman.json`, myRoutes)
+*/
+type Postman struct {
+	Name    string
+	BaseURL string
+}
+
+/*
+Postman collection made by `Postman.Collection`. Encodes as JSON via
+"encoding/json", in the format described by `PostmanSchema`.
+*/
+type PostmanCollection struct {
+	Info     PostmanInfo   `json:"info"`
+	Item     []PostmanItem `json:"item"`
+	Variable []PostmanVar  `json:"variable,omitempty"`
+}
+
+// Part of `PostmanCollection`.
+type PostmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// Part of `PostmanCollection`.
+type PostmanItem struct {
+	Name    string         `json:"name"`
+	Request PostmanRequest `json:"request"`
+}
+
+// Part of `PostmanCollection`.
+type PostmanRequest struct {
+	Method      string     `json:"method"`
+	URL         PostmanURL `json:"url"`
+	Description string     `json:"description,omitempty"`
+}
+
+// Part of `PostmanCollection`.
+type PostmanURL struct {
+	Raw      string       `json:"raw"`
+	Host     []string     `json:"host"`
+	Path     []string     `json:"path"`
+	Variable []PostmanVar `json:"variable,omitempty"`
+}
+
+// Part of `PostmanCollection`.
+type PostmanVar struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Makes a collection from the routes of the given routing function.
+func (self Postman) Collection(fun func(Rou)) (PostmanCollection, error) {
+	out := PostmanCollection{
+		Info:     PostmanInfo{Name: self.Name, Schema: PostmanSchema},
+		Item:     []PostmanItem{},
+		Variable: []PostmanVar{{Key: `baseUrl`, Value: self.BaseURL}},
+	}
+
+	var err error
+	Visit(fun, InfoVisitorFunc(func(val EndpointInfo) {
+		if err != nil {
+			return
+		}
+		var item PostmanItem
+		item, err = postmanItem(val)
+		out.Item = append(out.Item, item)
+	}))
+	return out, err
+}
+
+/*
+Makes a collection via `Postman.Collection` and encodes it as indented JSON.
+*/
+func (self Postman) Source(fun func(Rou)) ([]byte, error) {
+	val, err := self.Collection(fun)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(val, ``, `  `)
+}
+
+/*
+Makes a collection via `Postman.Source` and writes it to the file at the given
+path, replacing the previous content.
+*/
+func (self Postman) WriteFile(path string, fun func(Rou)) error {
+	src, err := self.Source(fun)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, src, 0o644)
+}
+
+func postmanItem(val EndpointInfo) (PostmanItem, error) {
+	pat, rest, ok := endpointPat(val.Endpoint)
+	if !ok {
+		return PostmanItem{}, fmt.Errorf(
+			`[rout] unable to export route %q %q: unsupported match %q`,
+			val.Method, val.Pattern, val.Match,
+		)
+	}
+
+	meth := val.Method
+	if meth == `` {
+		meth = http.MethodGet
+	}
+
+	name := val.Name
+	if name == `` {
+		name = strings.TrimSpace(val.Method + ` ` + val.Pattern)
+	}
+
+	desc := val.Desc
+	if val.Deprecated {
+		desc = strings.TrimSpace(desc + "\n\nDeprecated.")
+	}
+
+	path, vars := postmanPath(pat, rest, jsParams(val.Match.Names(val.Pattern), pat.Num()+boolInt(rest)))
+
+	return PostmanItem{
+		Name: name,
+		Request: PostmanRequest{
+			Method: meth,
+			URL: PostmanURL{
+				Raw:      `{{baseUrl}}/` + strings.Join(path, `/`),
+				Host:     []string{`{{baseUrl}}`},
+				Path:     path,
+				Variable: vars,
+			},
+			Description: desc,
+		},
+	}, nil
+}
+
+/*
+Converts the pattern to path segments, with captures replaced by Postman path
+variables such as ":id" where they occupy an entire segment, and by variables
+such as "{{id}}" otherwise.
+*/
+func postmanPath(pat Pat, rest bool, params []string) ([]string, []PostmanVar) {
+	var buf strings.Builder
+	for _, seg := range pat {
+		if seg != `` {
+			buf.WriteString(seg)
+			continue
+		}
+		buf.WriteString(postmanOpen + params[0] + postmanClose)
+		params = params[1:]
+	}
+	if rest {
+		buf.WriteString(postmanOpen + params[0] + postmanClose)
+	}
+
+	var vars []PostmanVar
+	segs := strings.Split(strings.TrimPrefix(buf.String(), `/`), `/`)
+
+	for ind, seg := range segs {
+		if strings.HasPrefix(seg, postmanOpen) && strings.HasSuffix(seg, postmanClose) &&
+			strings.Count(seg, postmanOpen) == 1 {
+			name := seg[len(postmanOpen) : len(seg)-len(postmanClose)]
+			segs[ind] = `:` + name
+			vars = append(vars, PostmanVar{Key: name})
+			continue
+		}
+		segs[ind] = postmanReplacer.Replace(seg)
+	}
+	return segs, vars
+}
+
+// Delimit parameter names while building paths. Can't occur in patterns.
+const (
+	postmanOpen  = "\x00"
+	postmanClose = "\x01"
+)
+
+var postmanReplacer = strings.NewReplacer(postmanOpen, `{{`, postmanClose, `}}`)
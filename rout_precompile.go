@@ -0,0 +1,86 @@
+package rout
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+/*
+Validates and compiles all patterns of the given routing function ahead of
+time, so that invalid patterns fail at startup rather than at the first
+matching request, and the first requests don't pay for compilation. Visits all
+routes via `Visit`, and compiles the pattern of each endpoint and of each
+enclosing block, such as `Rou.Sub`, into the same caches used for routing:
+regexps, `Pat`, mux-style and colon-style patterns, and capture names. Returns
+all errors combined via `errors.Join`, or nil if all patterns are valid. A
+panic in the routing function during the dry run is also returned as an
+error. Example:
+
+	func main() {
+		err := rout.Precompile(myRoutes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Fatal(http.ListenAndServe(`:8080`, rout.RouFunc(myRoutes)))
+	}
+*/
+func Precompile(fun func(Rou)) (err error) {
+	var errs []error
+	seen := map[namesKey]bool{}
+
+	compile := func(match Match, pattern string) {
+		key := namesKey{match, pattern}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		err := precompile(match, pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				`[rout] invalid pattern %q of type %q: %w`, pattern, match, err,
+			))
+		}
+	}
+
+	var ok bool
+	defer func() {
+		err = errors.Join(append(errs, recErr(recover(), ok))...)
+	}()
+
+	Visit(fun, InfoVisitorFunc(func(val EndpointInfo) {
+		for _, scope := range val.Parents() {
+			compile(scope.Match, scope.Pattern)
+		}
+		compile(val.Match, val.Pattern)
+	}))
+	ok = true
+	return
+}
+
+func precompile(match Match, pattern string) (err error) {
+	if pattern == `` {
+		return nil
+	}
+
+	var ok bool
+	defer rec(&err, &ok)
+
+	switch match {
+	case MatchReg:
+		reg, err := regexp.Compile(pattern)
+		try(err)
+		regexpCache.LoadOrStore(pattern, reg)
+	case MatchPat:
+		cachedPat(pattern)
+	case MatchMux:
+		cachedMux(pattern)
+	case MatchCol:
+		cachedCol(pattern)
+	}
+
+	match.Names(pattern)
+	ok = true
+	return nil
+}
@@ -0,0 +1,116 @@
+package rout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+/*
+Creates a reverse proxy to the given upstream URL for use with `Rou.Proxy`, via
+`httputil.NewSingleHostReverseProxy`. Optional functions may modify the proxy
+before use; see `ProxyStrip`. Unless overridden by an option, failures to
+reach the upstream are propagated via panic as `ErrProxy`, just like routing
+errors, and are normally returned by `Rou.Route`. The proxy should be created
+once, for example in a package variable, rather than in a routing function,
+which runs on every request. Example:
+
+	var proxyBilling = rout.NewProxy(billingUrl, rout.ProxyStrip(`/billing`))
+*/
+func NewProxy(target *url.URL, opts ...func(*httputil.ReverseProxy)) *httputil.ReverseProxy {
+	out := httputil.NewSingleHostReverseProxy(target)
+	out.ErrorHandler = proxyErr
+	for _, fun := range opts {
+		if fun != nil {
+			fun(out)
+		}
+	}
+	return out
+}
+
+/*
+If the router matches the request, forwards it via the given reverse proxy,
+usually created via `NewProxy`. If the router doesn't match the request, do
+nothing. Matches any method unless the router has one. The proxy may be nil.
+`ErrProxy` generated by the proxy is subject to `Rou.Redact`. Example:
+
+	rou.Sta(`/billing`).Proxy(proxyBilling)
+
+In "dry run" mode via `Visit`, this invokes a visitor for the current
+endpoint, using the proxy as the handler.
+*/
+func (self Rou) Proxy(val *httputil.ReverseProxy) {
+	if self.isDone() || self.vis(val) || !self.Match() {
+		return
+	}
+	self.done(val)
+
+	if val != nil {
+		defer self.proxyRec()
+		serve(&self, val)
+	}
+}
+
+/*
+Used by `Rou.Proxy`. Applies the router's settings, such as `Rou.Redact`, to
+`ErrProxy` generated by `proxyErr`, which doesn't have access to the router.
+*/
+func (self *Rou) proxyRec() {
+	val := recover()
+	if val == nil {
+		return
+	}
+
+	wrap, ok := val.(tryErr)
+	if ok {
+		err, ok := wrap.Err.(ErrProxy)
+		if ok {
+			err.Route = self.routeErr(ErrBadGatewayBase)
+			val = tryErr{err}
+		}
+	}
+	panic(val)
+}
+
+/*
+Option for `NewProxy`. Strips the given prefix from the request path before
+forwarding, so that "/billing/invoices" under the prefix "/billing" becomes
+"/invoices", joined with the path of the target URL.
+*/
+func ProxyStrip(prefix string) func(*httputil.ReverseProxy) {
+	return func(val *httputil.ReverseProxy) {
+		director := val.Director
+		val.Director = func(req *http.Request) {
+			proxyStrip(req.URL, prefix)
+			if director != nil {
+				director(req)
+			}
+		}
+	}
+}
+
+func proxyStrip(val *url.URL, prefix string) {
+	if prefix == `` || !strings.HasPrefix(val.Path, prefix) {
+		return
+	}
+
+	val.Path = val.Path[len(prefix):]
+	if !hasSlashPrefix(val.Path) {
+		val.Path = `/` + val.Path
+	}
+	val.RawPath = ``
+}
+
+func proxyErr(_ http.ResponseWriter, req *http.Request, err error) {
+	out := ErrBadGatewayBase
+	out.Method, out.Path = req.Method, reqPath(req)
+
+	try(ErrProxy{
+		Route: out,
+		Err:   err,
+		Abort: errors.Is(err, context.Canceled) && req.Context().Err() != nil,
+	})
+}
@@ -0,0 +1,120 @@
+package rout
+
+import (
+	"net/http"
+	r "reflect"
+	"sync"
+)
+
+/*
+Concurrency-safe registry that maps handlers to arbitrary metadata of type `A`,
+keyed by `Ident`. Allows to attach metadata such as documentation, auth
+requirements, or rate limits to handlers where they're defined, and retrieve
+it from endpoints visited via `Visit`, or from the endpoint returned by
+`Rou.Matched`. The zero value is ready to use. Must not be copied after first
+use. Example:
+
+	var docs rout.Registry[string]
+
+	var apiArticleGet = docs.Func(`Returns an article by id.`, func(rew http.ResponseWriter, req *http.Request) {...})
+
+	rout.Visit(routes, rout.VisitorFunc(func(val rout.Endpoint) {
+		doc, _ := docs.Endpoint(val)
+		fmt.Println(val.Pattern, doc)
+	}))
+
+Keys are normalized to avoid the pitfalls of `Ident` and interface
+conversions. Functions are identified only by their data pointer, ignoring the
+type, which means that registering a plain function matches the same function
+converted to `Func`, `Han`, or another named type by the router. Other values
+are identified by both type and data pointer. Values larger than one word
+are copied on each conversion to an interface; such values should be converted
+to an interface once, and the same interface value should be passed both to
+the registry and to the router.
+*/
+type Registry[A any] struct {
+	lock sync.RWMutex
+	vals map[[2]uintptr]A
+}
+
+// Associates the given metadata with the given handler, replacing any previous.
+func (self *Registry[A]) Set(key interface{}, val A) {
+	self.SetIdent(Ident(key), val)
+}
+
+// Like `Registry.Set` but takes an existing `Ident`.
+func (self *Registry[A]) SetIdent(key [2]uintptr, val A) {
+	key = registryKey(key)
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.vals == nil {
+		self.vals = map[[2]uintptr]A{}
+	}
+	self.vals[key] = val
+}
+
+/*
+Returns the metadata associated with the given handler, and true if found.
+Otherwise returns zero and false.
+*/
+func (self *Registry[A]) Get(key interface{}) (A, bool) {
+	return self.GetIdent(Ident(key))
+}
+
+// Like `Registry.Get` but takes an existing `Ident`.
+func (self *Registry[A]) GetIdent(key [2]uintptr) (A, bool) {
+	key = registryKey(key)
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	val, ok := self.vals[key]
+	return val, ok
+}
+
+// Returns the metadata associated with the handler of the given endpoint.
+func (self *Registry[A]) Endpoint(val Endpoint) (A, bool) {
+	return self.GetIdent(val.Handler)
+}
+
+// Removes the metadata associated with the given handler, if any.
+func (self *Registry[A]) Delete(key interface{}) {
+	ident := registryKey(Ident(key))
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.vals, ident)
+}
+
+// Returns the number of registered handlers.
+func (self *Registry[A]) Len() int {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return len(self.vals)
+}
+
+/*
+Shortcut for registering a `Func` handler where it's defined. Associates the
+metadata with the function and returns the function.
+*/
+func (self *Registry[A]) Func(val A, fun Func) Func {
+	self.Set(fun, val)
+	return fun
+}
+
+/*
+Shortcut for registering an `http.Handler` where it's defined. Associates the
+metadata with the handler and returns the handler, converted to an interface
+only once, which makes it safe for handler types larger than one word.
+*/
+func (self *Registry[A]) Handler(val A, han http.Handler) http.Handler {
+	self.Set(han, val)
+	return han
+}
+
+func registryKey(val [2]uintptr) [2]uintptr {
+	typ := IdentType(val)
+	if typ != nil && typ.Kind() == r.Func {
+		val[0] = 0
+	}
+	return val
+}
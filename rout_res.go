@@ -0,0 +1,233 @@
+package rout
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*
+Shortcut for making a response with the given status, content type, and body,
+suitable for `Rou.Res`, `Rou.ParamRes`, and `Respond`. Zero status is
+equivalent to 200. Empty content type is omitted. Sets `.ContentLength`,
+allowing `Respond` to set "Content-Length". The body is seekable, allowing
+`ResRange` to serve byte ranges. The other "Res" functions are
+implemented on top of this.
+*/
+func ResBytes(status int, typ string, body []byte) *http.Response {
+	out := &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{},
+		ContentLength: int64(len(body)),
+	}
+	if typ != `` {
+		out.Header.Set(`Content-Type`, typ)
+	}
+	if len(body) > 0 {
+		out.Body = nopSeekCloser{bytes.NewReader(body)}
+	} else {
+		out.Body = http.NoBody
+	}
+	return out
+}
+
+// Makes a response with the given status and text, as "text/plain".
+func ResText(status int, body string) *http.Response {
+	return ResBytes(status, `text/plain; charset=utf-8`, []byte(body))
+}
+
+// Makes a response with the given status and markup, as "text/html".
+func ResHTML(status int, body string) *http.Response {
+	return ResBytes(status, `text/html; charset=utf-8`, []byte(body))
+}
+
+/*
+Makes a response with the given status and the value encoded as JSON, with
+"Content-Type: application/json". Encoding errors are propagated via panic,
+just like routing errors, and are normally returned by `Rou.Route`. Example:
+
+	rou.Pat(`/articles/{}`).Get().ParamRes(func(req *http.Request, args []string) *http.Response {
+		return rout.ResJSON(http.StatusOK, articleGet(args[0]))
+	})
+*/
+func ResJSON(status int, val interface{}) *http.Response {
+	body, err := json.Marshal(val)
+	try(err)
+	return ResBytes(status, `application/json`, body)
+}
+
+/*
+Makes a redirect response with the given status and target URL, which is used
+as-is for the "Location" header. Zero status is equivalent to
+`http.StatusFound`. For redirects declared as routes, see `Rou.Redirect`.
+*/
+func ResRedirect(status int, target string) *http.Response {
+	if status == 0 {
+		status = http.StatusFound
+	}
+	out := ResBytes(status, ``, nil)
+	out.Header.Set(`Location`, target)
+	return out
+}
+
+/*
+Adapts the given response to the "Range" header of the given request, allowing
+`Rou.Res` handlers to serve byte ranges for media and downloads without
+switching to `http.ServeContent`. Applies only to GET requests, responses with
+status 200 (or zero), and bodies which implement `io.Seeker`, such as files
+and the bodies made by `ResBytes`. For such responses, sets "Accept-Ranges".
+Supports a single range; requests with multiple ranges, invalid ranges, or
+"If-Range" not matching the "ETag" or "Last-Modified" header of the response
+get the full response, as allowed by RFC 9110. For a satisfiable range,
+returns a response with status 206, "Content-Range", and the body limited to
+the range. For an unsatisfiable range, closes the body and returns a response
+with status 416. Otherwise returns the response as-is. Errors from seeking are
+propagated via panic, just like routing errors. Example:
+
+	rou.Pat(`/media/{}`).Get().ParamRes(func(req *http.Request, args []string) *http.Response {
+		return rout.ResRange(req, rout.ResBytes(http.StatusOK, `audio/mpeg`, mediaGet(args[0])))
+	})
+*/
+func ResRange(req *http.Request, res *http.Response) *http.Response {
+	if req == nil || res == nil || req.Method != http.MethodGet ||
+		(res.StatusCode != 0 && res.StatusCode != http.StatusOK) {
+		return res
+	}
+
+	body, ok := res.Body.(io.ReadSeeker)
+	if !ok {
+		return res
+	}
+
+	if res.Header == nil {
+		res.Header = http.Header{}
+	}
+	res.Header.Set(`Accept-Ranges`, `bytes`)
+
+	head := req.Header.Get(`Range`)
+	if head == `` || !ifRange(req.Header.Get(`If-Range`), res.Header) {
+		return res
+	}
+
+	base, err := body.Seek(0, io.SeekCurrent)
+	try(err)
+	end, err := body.Seek(0, io.SeekEnd)
+	try(err)
+	size := end - base
+
+	start, last, ok, valid := parseRange(head, size)
+	if !valid {
+		_, err = body.Seek(base, io.SeekStart)
+		try(err)
+		return res
+	}
+	if !ok {
+		resClose(res)
+		out := ResBytes(http.StatusRequestedRangeNotSatisfiable, ``, nil)
+		out.Header.Set(`Content-Range`, `bytes */`+strconv.FormatInt(size, 10))
+		return out
+	}
+
+	_, err = body.Seek(base+start, io.SeekStart)
+	try(err)
+
+	length := last - start + 1
+	res.StatusCode = http.StatusPartialContent
+	res.ContentLength = length
+	res.Header.Set(`Content-Length`, strconv.FormatInt(length, 10))
+	res.Header.Set(`Content-Range`, `bytes `+
+		strconv.FormatInt(start, 10)+`-`+strconv.FormatInt(last, 10)+`/`+
+		strconv.FormatInt(size, 10))
+	res.Body = rangeBody{io.LimitReader(body, length), res.Body}
+	return res
+}
+
+type rangeBody struct {
+	io.Reader
+	io.Closer
+}
+
+/*
+Parses a single byte range. "valid" is false if the header should be ignored.
+"ok" is false if the range is unsatisfiable. The returned positions are
+inclusive.
+*/
+func parseRange(head string, size int64) (start, last int64, ok, valid bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(head), `bytes=`)
+	if !found || strings.Contains(spec, `,`) {
+		return
+	}
+
+	first, second, found := strings.Cut(strings.TrimSpace(spec), `-`)
+	if !found {
+		return
+	}
+
+	if first == `` {
+		suffix, err := strconv.ParseInt(second, 10, 64)
+		if err != nil || suffix < 0 {
+			return
+		}
+		valid = true
+		if suffix == 0 || size == 0 {
+			return
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return
+	}
+
+	last = size - 1
+	if second != `` {
+		last, err = strconv.ParseInt(second, 10, 64)
+		if err != nil || last < start {
+			return 0, 0, false, false
+		}
+		if last >= size {
+			last = size - 1
+		}
+	}
+
+	valid = true
+	if start >= size {
+		return
+	}
+	return start, last, true, true
+}
+
+/*
+True if the range request should be honored according to "If-Range". Entity
+tags must match strongly; dates must match "Last-Modified" exactly.
+*/
+func ifRange(val string, head http.Header) bool {
+	if val == `` {
+		return true
+	}
+	if strings.HasPrefix(val, `"`) {
+		return val == head.Get(`Etag`)
+	}
+	if strings.HasPrefix(val, `W/`) {
+		return false
+	}
+
+	date, err := http.ParseTime(val)
+	if err != nil {
+		return false
+	}
+	mod, err := http.ParseTime(head.Get(`Last-Modified`))
+	return err == nil && date.Equal(mod)
+}
+
+// Like `io.NopCloser`, but preserves `io.Seeker`. See `ResBytes`.
+type nopSeekCloser struct{ io.ReadSeeker }
+
+func (nopSeekCloser) Close() error { return nil }
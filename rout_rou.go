@@ -1,7 +1,14 @@
 package rout
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 /*
@@ -21,12 +28,16 @@ Router type. Matches patterns and executes handlers. Should be used via
 Immutable, with a builder-style API where every method returns a modified copy.
 A router is stack-allocated; its builder API incurs no allocator/GC work. The
 only tiny exception is `Rou.Mut`, which is the only allocation per request
-forced by this package.
+forced by this package. Handlers which are closures or method values, such as
+`rou.Func(self.Get)`, are allocated by the caller when created, because they
+may be passed to middleware added via `Rou.Use`; top-level functions are not
+affected. Rarely used settings, such as middleware and guards, are stored in
+`Rou.Conf`; methods which change them allocate a modified copy.
 
 Implementation note. All "modifying" methods are defined on the value type in
 order to return modified copies, but many non-modifying methods are defined on
 the pointer type for marginal efficiency gains, due to the size of this
-struct.
+struct. The size is kept small because every builder call copies it.
 */
 type Rou struct {
 	Rew        http.ResponseWriter
@@ -34,19 +45,55 @@ type Rou struct {
 	Mut        *Mut
 	Vis        Visitor
 	Method     string
+	MethodList []string
 	Pattern    string
+	Filter     Filter
+	Conf       *Conf
+	Captures   *Captures
 	Style      Match
 	OnlyMethod bool
+	MethodLax  bool
+	SlashLax   bool
+	SlashDedup bool
+	HeadStrict bool
+	Captured   bool
 }
 
 /*
 Shortcut for routing with default error handling. Same as `rout.Rou.Route`,
-but instead of returning an error, uses `rout.WriteErr` to write it. Example:
+but instead of returning an error, writes it via the error writer set by
+`Rou.ErrWriter`, falling back on the writer set via `SetDefaultErrWriter` and
+then `rout.WriteErr`. Example:
 
 	rout.MakeRou(rew, req).Serve(myRoutes)
 */
 func (self Rou) Serve(fun func(Rou)) {
-	WriteErr(self.Rew, self.Route(fun))
+	err := self.Route(fun)
+	if err == nil {
+		return
+	}
+
+	wri := self.conf().ErrWrite
+	if wri == nil {
+		wri = DefaultErrWriter()
+	}
+	if wri == nil {
+		WriteErr(self.Rew, err)
+		return
+	}
+	wri(self.Rew, self.Req, err)
+}
+
+/*
+Returns a router which uses the given function to write routing errors in
+`Rou.Serve`, instead of the writer set via `SetDefaultErrWriter` or
+`WriteErr`. Only the top-level router's setting is used. For errors in subtrees, see `Rou.OnErr`. Example:
+
+	rout.MakeRou(rew, req).ErrWriter(rout.NegotiateErr{}.WriteErr).Serve(myRoutes)
+*/
+func (self Rou) ErrWriter(fun ErrWriter) Rou {
+	self.setConf().ErrWrite = fun
+	return self
 }
 
 /*
@@ -59,11 +106,43 @@ the error is nil.
 Same as `Rou.Sub`, but catches panics, returning them as errors.
 */
 func (self Rou) Route(fun func(Rou)) (err error) {
-	defer rec(&err)
+	if self.conf().ErrSuggest {
+		defer self.suggest(&err, fun)
+	}
+	var ok bool
+	defer rec(&err, &ok)
 	self.Sub(fun)
+	ok = true
 	return
 }
 
+/*
+Returns a router in "suggest" development mode. When `Rou.Route` or
+`Rou.Serve` fails with a 404 `ErrRoute`, the declared routes are collected via
+`Visit`, and up to 3 routes closest to the request path, by edit distance, are
+added to `ErrRoute.Suggest` and to the error message. The routes are collected
+once per routing function and cached; if collection panics, the original error
+is kept as-is. Meant for development; the suggestions reveal the routing table
+to clients. Must be set on the top-level router. Example:
+
+	rout.MakeRou(rew, req).Suggest().Serve(myRoutes)
+*/
+func (self Rou) Suggest() Rou {
+	self.setConf().ErrSuggest = true
+	return self
+}
+
+func (self *Rou) suggest(ptr *error, fun func(Rou)) {
+	var val ErrRoute
+	if !errors.As(*ptr, &val) || val.Status != http.StatusNotFound {
+		return
+	}
+	val.Suggest = suggestRoutes(cachedSuggestRoutes(fun), self.path(), 3)
+	if val.Suggest != `` {
+		*ptr = val
+	}
+}
+
 /*
 Short for "regexp". Takes a regexp pattern and returns a router that will use
 this pattern to match `req.URL.Path`. Regexps are compiled lazily, cached, and
@@ -82,6 +161,34 @@ func (self Rou) Pat(val string) Rou {
 	return self.pat(val, MatchPat)
 }
 
+/*
+Takes a pattern in the syntax of `http.ServeMux` since Go 1.22, such as
+"GET /items/{id}" or "/files/{path...}", and returns a router that will use
+this pattern to match `req.URL.Path`, via `MuxPat`. The optional method prefix
+is equivalent to `Rou.Meth`; unlike `http.ServeMux`, "GET" doesn't also match
+"HEAD". Host prefixes are not supported. Patterns are compiled lazily, cached,
+and reused.
+*/
+func (self Rou) Mux(val string) Rou {
+	meth, path := muxSplit(val)
+	if meth != `` {
+		self = self.Meth(meth)
+	}
+	return self.pat(path, MatchMux)
+}
+
+/*
+Short for "colon". Takes a pattern in the syntax of
+"github.com/julienschmidt/httprouter" and similar libraries, such as
+"/users/:id" or "/files/*path", and returns a router that will use this
+pattern to match `req.URL.Path`. Meant for migrating route lists from such
+libraries without rewriting them. See `(*MuxPat).ParseColon` for the rules.
+Patterns are compiled lazily, cached, and reused.
+*/
+func (self Rou) Col(val string) Rou {
+	return self.pat(val, MatchCol)
+}
+
 /*
 Short for "exact". Takes a string and returns a router that tests `req.URL.Path`
 by matching this string exactly. Unlike `Rou.Reg`, this doesn't support capture
@@ -103,14 +210,73 @@ func (self Rou) Sta(val string) Rou {
 	return self.pat(val, MatchSta)
 }
 
+/*
+Short for "parametrized start". Takes a URL path prefix and returns a router
+that matches like `Rou.Sta`, but also captures the rest of the path after the
+prefix, which is passed to parametrized endpoints such as `Rou.ParamFunc` as
+the only argument. Meant for handlers mounted on a prefix, such as file
+servers and proxies. See `MatchParamSta` for the exact rules. Example:
+
+	rou.ParamSta(`/files`).Get().ParamHan(func(req *http.Request, args []string) http.Handler {
+		path := args[0] // "/one/two" for "/files/one/two"
+		...
+	})
+*/
+func (self Rou) ParamSta(val string) Rou {
+	return self.pat(val, MatchParamSta)
+}
+
+/*
+Short for "host pattern". Returns a router that additionally requires
+`req.Host` to match the given `HostPat`, such as "example.com" or
+"{tenant}.example.com". The port, if any, is ignored. Host captures are
+appended to the captures of the path pattern, and are passed to parametrized
+handlers such as `Rou.ParamFunc`. A host mismatch is treated like a pattern
+mismatch: the router falls through without generating an error. Unlike path
+patterns, this is not reset by pattern-modifying methods, and is inherited by
+sub-routers. Patterns are compiled lazily, cached, and reused. Example:
+
+	rou.HostPat(`{tenant}.example.com`).Sub(func(rou rout.Rou) {
+		rou.Pat(`/articles/{}`).Get().ParamHan(func(req *http.Request, args []string) http.Handler {
+			id, tenant := args[0], args[1]
+			...
+		})
+	})
+*/
+func (self Rou) HostPat(val string) Rou {
+	self.setConf().HostPattern = val
+	return self
+}
+
 /*
 Short for "method". Returns a router that matches only the given method. If the
 method is empty, the resulting router matches all methods, which is the
-default. Note: to match multiple methods for one route, use `Rou.Methods`.
-Otherwise, the first mismatch generates `ErrMethodNotAllowed`.
+default. Note: to match multiple methods for one route, use `Rou.Meths` or
+`Rou.Methods`. Otherwise, the first mismatch generates `ErrMethodNotAllowed`.
+Replaces any methods previously set via `Rou.Meths`.
 */
 func (self Rou) Meth(val string) Rou {
 	self.Method = val
+	self.MethodList = nil
+	return self
+}
+
+/*
+Short for "methods". Returns a router that matches any of the given methods.
+Unlike `Rou.Methods`, this doesn't require a block, and allows one handler to
+serve multiple methods. Replaces the method previously set via `Rou.Meth`. The
+slice is retained; avoid mutating it afterwards. Empty input is equivalent to
+`.Meth("")` and matches all methods. In "dry run" mode via `Visit`, terminal
+methods visit one endpoint per method. Example:
+
+	rou.Pat(`/articles`).Meths(http.MethodPut, http.MethodPatch).Han(apiArticleUpdate)
+*/
+func (self Rou) Meths(vals ...string) Rou {
+	self.Method = ``
+	self.MethodList = nil
+	if len(vals) > 0 {
+		self.MethodList = vals
+	}
 	return self
 }
 
@@ -135,275 +301,1578 @@ func (self Rou) MethodOnly() Rou {
 }
 
 /*
-Same as `.Meth(http.MethodGet)`.
-Returns a router that matches only this HTTP method.
+Returns a router set to "lax method" mode. In "normal" mode, whenever the
+router matches the URL pattern but doesn't match the HTTP method, it
+immediately generates a "method not allowed" error. In "lax method" mode, such
+a route is skipped, and routing falls through to later routes. If no route
+matches, the enclosing sub-router generates `ErrMethodNotAllowed` rather than
+`ErrNotFound`. Unlike "method only" mode, this is not reset by
+pattern-modifying methods, and is inherited by sub-routers. Useful when
+multiple packages register handlers for different methods of the same path:
+
+	rout.MakeRou(rew, req).Lax().Serve(rout.Join(articles.Routes, comments.Routes))
 */
-func (self Rou) Get() Rou { return self.Meth(http.MethodGet) }
+func (self Rou) Lax() Rou {
+	self.MethodLax = true
+	return self
+}
 
 /*
-Same as `.Meth(http.MethodHead)`.
-Returns a router that matches only this HTTP method.
+Returns a router set to "lax slash" mode, where exact and OAS-style patterns
+(`Rou.Exa`, `Rou.Pat`, `Rou.Exacts`) ignore a single trailing slash in both
+the pattern and the request path. For example, `/one` and `/one/` become
+equivalent. The root path `/` is unaffected. Unlike "method only" mode, this
+is not reset by pattern-modifying methods, and is inherited by sub-routers.
+Usually set once at the top level:
+
+	rout.MakeRou(rew, req).LaxSlash().Serve(myRoutes)
 */
-func (self Rou) Head() Rou { return self.Meth(http.MethodHead) }
+func (self Rou) LaxSlash() Rou {
+	self.SlashLax = true
+	return self
+}
 
 /*
-Same as `.Meth(http.MethodOptions)`.
-Returns a router that matches only this HTTP method.
+Returns a router with automatic HEAD fallback disabled. By default, when a
+HEAD request matches a route that declares GET but not HEAD, the GET handler
+is used, with a response writer that discards the body, like in
+`http.ServeMux`. In "strict head" mode, such requests are treated as a method
+mismatch. Like "lax slash" mode, this is inherited by sub-routers.
+
+Within a `Rou.Methods` block, an explicit HEAD handler takes priority over the
+fallback regardless of declaration order. Elsewhere, routes are tried in
+order, so an explicit HEAD route must precede the GET route for the same
+pattern.
 */
-func (self Rou) Options() Rou { return self.Meth(http.MethodOptions) }
+func (self Rou) StrictHead() Rou {
+	self.HeadStrict = true
+	return self
+}
 
 /*
-Same as `.Meth(http.MethodPost)`.
-Returns a router that matches only this HTTP method.
+Returns a router which redacts routing errors generated downstream, including
+sub-routers. Such errors of type `ErrRoute` have `.Redact` set, and omit the
+request method and path from their message and JSON encoding, which are
+normally seen by clients. The method and path remain available in the fields
+of `ErrRoute` for server-side logging. Useful when paths may contain sensitive
+tokens. Usually set once at the top level:
+
+	rout.MakeRou(rew, req).Redact().Serve(myRoutes)
 */
-func (self Rou) Post() Rou { return self.Meth(http.MethodPost) }
+func (self Rou) Redact() Rou {
+	self.setConf().ErrRedact = true
+	return self
+}
 
 /*
-Same as `.Meth(http.MethodPatch)`.
-Returns a router that matches only this HTTP method.
+Returns a router in "stack trace" debug mode, where routing errors of type
+`ErrRoute` generated downstream, including sub-routers, capture the stack
+trace at the moment of the error, available via `ErrRoute.StackTrace`. The
+trace shows which routing branch generated the error, which helps in large
+routing trees. Capturing traces is relatively expensive; meant for
+development. Handler panics caught via `Rou.Try` always include a trace; see
+`ErrPanic.StackTrace`. Example:
+
+	rout.MakeRou(rew, req).StackTraces().Serve(myRoutes)
 */
-func (self Rou) Patch() Rou { return self.Meth(http.MethodPatch) }
+func (self Rou) StackTraces() Rou {
+	self.setConf().ErrStack = true
+	return self
+}
 
 /*
-Same as `.Meth(http.MethodPut)`.
-Returns a router that matches only this HTTP method.
+Returns a router that wraps the handlers of all routes declared downstream,
+including sub-routers, in the given middleware, which is compatible with the
+common signature used in the Go ecosystem. Middleware is applied only after a
+route matches, immediately before invoking its handler, and only in "real"
+routing mode. The first middleware is the outermost. Multiple calls
+accumulate. The middleware may replace the request and response writer passed
+to the handler, for example to add context values. Example:
+
+	rou.Sta(`/api`).Use(logging, auth).Sub(routesApi)
+
+Note that this package propagates handler errors and routing errors via
+panics; middleware which recovers from panics may intercept them.
 */
-func (self Rou) Put() Rou { return self.Meth(http.MethodPut) }
+func (self Rou) Use(vals ...func(http.Handler) http.Handler) Rou {
+	if len(vals) > 0 {
+		conf := self.setConf()
+		conf.Wrap = append(conf.Wrap[:len(conf.Wrap):len(conf.Wrap)], vals...)
+	}
+	return self
+}
 
 /*
-Same as `.Meth(http.MethodDelete)`.
-Returns a router that matches only this HTTP method.
+Returns a router that runs the given guard for all routes declared downstream,
+including sub-routers. Meant for declarative authorization of entire
+subtrees. Guards run only after a route matches, immediately before invoking
+its handler and middleware, and only in "real" routing mode. Multiple guards
+run in the order of declaration. A non-nil error aborts routing: it's
+propagated via panic, just like routing errors, and is normally returned by
+`Rou.Route`. The error should implement the `HttpStatusCode` interface
+described in `ErrStatus`, for example `ErrUnauthorized` or `ErrForbidden`;
+other errors are treated as status 403. Example:
+
+	rou.Sta(`/admin`).Guard(requireAdmin).Sub(routesAdmin)
+
+	func requireAdmin(req *http.Request) error {
+		if !isAdmin(req) {
+			return rout.Unauthorized(req.Method, req.URL.Path)
+		}
+		return nil
+	}
 */
-func (self Rou) Delete() Rou { return self.Meth(http.MethodDelete) }
+func (self Rou) Guard(fun func(*http.Request) error) Rou {
+	if fun != nil {
+		conf := self.setConf()
+		conf.Guards = append(conf.Guards[:len(conf.Guards):len(conf.Guards)], fun)
+	}
+	return self
+}
 
 /*
-Same as `.Meth(http.MethodConnect)`.
-Returns a router that matches only this HTTP method.
+Returns a router that puts all routes declared downstream, including
+sub-routers, into maintenance mode whenever the given function returns true.
+Implemented as a guard; see `Rou.Guard`. When a route matches while
+maintenance is enabled, its handler is skipped, and routing is aborted with
+`ErrServiceUnavailable`, which normally results in status 503. If `retryAfter`
+is positive, also sets the `Retry-After` response header, in whole seconds,
+rounded up. The function is called once per matched request, and must be safe
+for concurrent use. Example:
+
+	rou.Sta(`/api/billing`).Maintenance(billingDown.Load, time.Minute).Sub(routesBilling)
 */
-func (self Rou) Connect() Rou { return self.Meth(http.MethodConnect) }
+func (self Rou) Maintenance(enabled func() bool, retryAfter time.Duration) Rou {
+	if enabled == nil {
+		return self
+	}
+
+	rew := self.Rew
+	return self.Guard(func(req *http.Request) error {
+		if !enabled() {
+			return nil
+		}
+		if retryAfter > 0 && rew != nil {
+			rew.Header().Set(`Retry-After`, strconv.FormatInt(int64((retryAfter+time.Second-1)/time.Second), 10))
+		}
+		return ServiceUnavailable(req.Method, reqPath(req))
+	})
+}
 
 /*
-Same as `.Meth(http.MethodTrace)`.
-Returns a router that matches only this HTTP method.
+Returns a router that handles errors locally, for sub-routers declared
+downstream via `Rou.Sub`, `Rou.Methods`, `Rou.Group` and similar. If
+sub-routing panics, including routing errors such as `ErrNotFound` and errors
+returned by handlers, the panic is recovered, the given func is invoked with
+the error, and routing stops, without propagating the error to `Rou.Route`.
+Meant for subtree-specific error responses, for example JSON errors for an
+API and HTML errors elsewhere. The innermost handler wins. Nil func disables
+local error handling. Example:
+
+	rou.Sta(`/api`).OnErr(writeErrJson).Sub(routesApi)
 */
-func (self Rou) Trace() Rou { return self.Meth(http.MethodTrace) }
+func (self Rou) OnErr(fun func(http.ResponseWriter, *http.Request, error)) Rou {
+	self.setConf().Catch = fun
+	return self
+}
 
 /*
-If the router matches the request, perform sub-routing. If sub-routing doesn't
-find a match, panic with `ErrNotFound`. If the router doesn't match the
-request, do nothing.
+Returns a router that recovers from panics in handlers of all routes declared
+downstream, including sub-routers, and converts them to `ErrPanic`, which has
+status 500 and includes the panic value and the stack trace. The error is
+propagated normally, and is returned by `Rou.Route` or handled by `Rou.OnErr`.
+Errors returned by handlers via the normal error flow, for example by
+`Rou.ErrFunc`, are propagated as-is. Any other panic becomes `ErrPanic`,
+including panics with error values, except `http.ErrAbortHandler`. Applies
+only in "real" routing mode. Example:
+
+	rout.MakeRou(rew, req).Try().Serve(myRoutes)
 */
-func (self Rou) Sub(fun func(Rou)) {
-	if self.isDone() || (self.isReal() && !self.Match()) {
-		return
-	}
-	if fun != nil {
-		fun(self)
-	}
-	if !self.isDone() && self.isReal() {
-		panic(NotFound(self.req()))
-	}
+func (self Rou) Try() Rou {
+	self.setConf().Recover = true
+	return self
 }
 
 /*
-If the router matches the request, perform sub-routing. The router provided to
-the function is set to "method only" mode: a mismatch in the HTTP method
-doesn't immediately generate an error. However, if sub-routing doesn't find a
-match, this panics with `ErrMethodNotAllowed`. If the router doesn't match the
-request, do nothing.
+Returns a router that sets the given response header for all routes declared
+downstream, including sub-routers. Headers are set via `http.Header.Set` when
+a route matches, before running guards, middleware, and the handler, which
+may override them. Multiple calls accumulate and are applied in order. Meant
+for concerns such as CORS, caching, and security headers. Applies only in
+"real" routing mode. Example:
+
+	rou.Sta(`/api`).
+		SetHeader(`Cache-Control`, `no-store`).
+		SetHeader(`X-Content-Type-Options`, `nosniff`).
+		Sub(routesApi)
 */
-func (self Rou) Methods(fun func(Rou)) {
-	if self.isDone() || (self.isReal() && !self.matchPattern()) {
-		return
-	}
-	if fun != nil {
-		fun(self.MethodOnly())
-	}
-	if !self.isDone() && self.isReal() {
-		panic(MethodNotAllowed(self.req()))
-	}
+func (self Rou) SetHeader(key, val string) Rou {
+	conf := self.setConf()
+	conf.Headers = append(conf.Headers[:len(conf.Headers):len(conf.Headers)], [2]string{key, val})
+	return self
 }
 
 /*
-If the router matches the request, use the given handler to respond. If the
-router doesn't match the request, do nothing. The handler may be nil. In
-"dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+Returns a router that sends "103 Early Hints" with the given "Link" header
+values when a route declared downstream, including sub-routers, matches. Hints
+are sent after applying headers from `Rou.SetHeader` and after guards pass,
+before running middleware and the handler, allowing clients to preload
+resources while the response is being prepared. Requests rejected by guards
+don't receive hints. Useful for `Rou.Res` handlers, which don't have access to
+the response writer. Multiple calls accumulate. Hints are skipped for HTTP/1.0
+requests, which don't support informational responses. Applies only in "real"
+routing mode. See `EarlyHints`. Example:
+
+	rou.Exa(`/`).Hints(
+		`</style.css>; rel=preload; as=style`,
+		`</app.js>; rel=preload; as=script`,
+	).Res(pageIndex)
 */
-func (self Rou) Handler(val http.Handler) {
-	if self.isDone() || self.vis(val) || !self.Match() {
-		return
-	}
-	self.done(val)
-	if val != nil {
-		val.ServeHTTP(self.Rew, self.Req)
-	}
+func (self Rou) Hints(links ...string) Rou {
+	conf := self.setConf()
+	conf.EarlyHints = append(conf.EarlyHints[:len(conf.EarlyHints):len(conf.EarlyHints)], links...)
+	return self
 }
 
 /*
-If the router matches the request, use the given handler func to respond.
-If the router doesn't match the request, do nothing. The func may be nil. In
-"dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+Returns a router that sets "Cache-Control" for all routes declared downstream,
+including sub-routers, declaring caching policy next to the routes. The header
+consists of "max-age" with the given duration in whole seconds, followed by
+the given directives. A negative duration omits "max-age", allowing to use
+only directives; with no directives, this is a nop. Headers are applied like
+`Rou.SetHeader`, and may be overridden by later calls and by handlers.
+Example:
+
+	rou.Sta(`/assets`).Cache(365*24*time.Hour, `public`, `immutable`).Sub(routesAssets)
+	rou.Sta(`/api`).Cache(-1, `no-store`).Sub(routesApi)
 */
-func (self Rou) Func(fun Func) {
-	if self.isDone() || self.vis(fun) || !self.Match() {
-		return
+func (self Rou) Cache(maxAge time.Duration, directives ...string) Rou {
+	var buf strings.Builder
+	if maxAge >= 0 {
+		buf.WriteString(`max-age=`)
+		buf.WriteString(strconv.FormatInt(int64(maxAge/time.Second), 10))
 	}
-	self.done(fun)
-	if fun != nil {
-		fun(self.Rew, self.Req)
+	for _, val := range directives {
+		if val == `` {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteString(`, `)
+		}
+		buf.WriteString(val)
+	}
+	if buf.Len() == 0 {
+		return self
 	}
+	return self.SetHeader(`Cache-Control`, buf.String())
 }
 
 /*
-If the router matches the request, use the given handler func to respond. If the
-router doesn't match the request, do nothing. The func may be nil. The
-additional `[]string` argument contains regexp captures from the pattern passed
-to `Rou.Reg`, if any. In "dry run" mode via `Visit`, this invokes a visitor for
-the current endpoint.
+Returns a router that uses the given function to encode the values returned by
+`Rou.Reply` handlers declared downstream, including sub-routers. When unset
+or nil, `EncodeJSON` is used. Example:
+
+	rou.Sta(`/api/xml`).Encoder(encodeXml).Sub(routesApiXml)
 */
-func (self Rou) ParamFunc(fun ParamFunc) {
-	if self.isDone() || self.vis(fun) {
-		return
-	}
+func (self Rou) Encoder(fun Encoder) Rou {
+	self.setConf().Encode = fun
+	return self
+}
 
-	args := self.Submatch()
-	if args == nil {
-		return
-	}
+/*
+Returns a router that uses the given responder to write the responses returned
+by `Rou.Res`, `Rou.ResAny`, `Rou.ResErr`, and `Rou.ParamRes` handlers declared
+downstream, including sub-routers. The zero value is equivalent to `Respond`.
+Example:
 
-	self.done(fun)
-	if fun != nil {
-		fun(self.Rew, self.Req, args)
-	}
+	rou.Sta(`/events`).Responder(rout.Responder{FlushInterval: -1}).Res(pollEvents)
+*/
+func (self Rou) Responder(val Responder) Rou {
+	self.setConf().Respond = val
+	return self
 }
 
 /*
-If the router matches the request, respond by using the handler returned by the
-given function. If the router doesn't match the request, do nothing. In "dry
-run" mode via `Visit`, this invokes a visitor for the current endpoint.
+Returns a router that annotates the next endpoint with the given name, which is
+carried into `EndpointInfo.Name` for introspection via `Visit`. Names allow
+tools such as documentation generators and reverse routing to refer to routes
+by stable identifiers rather than patterns. The name is not inherited by
+routes inside `Rou.Sub` and `Rou.Group`, but is shared by the endpoints of
+`Rou.Methods`. Example:
+
+	rou.Pat(`/articles/{id}`).Name(`articleGet`).Get().Han(apiArticleGet)
 */
-func (self Rou) Han(fun Han) {
-	if self.isDone() || self.vis(fun) || !self.Match() {
-		return
+func (self Rou) Name(val string) Rou {
+	if !self.isReal() {
+		self.setConf().EndpointName = val
 	}
+	return self
+}
 
-	self.done(fun)
-
-	if fun != nil {
-		val := fun(self.Req)
-		if val != nil {
-			val.ServeHTTP(self.Rew, self.Req)
-		}
+/*
+Returns a router that annotates the next endpoint with the given description,
+which is carried into `EndpointInfo.Desc`. See `Rou.Name` for the rules.
+*/
+func (self Rou) Desc(val string) Rou {
+	if !self.isReal() {
+		self.setConf().EndpointDesc = val
 	}
+	return self
 }
 
 /*
-If the router matches the request, respond by using the handler returned by the
-given function. If the router doesn't match the request, do nothing. The
-additional `[]string` argument contains regexp captures from the pattern passed
-to `Rou.Reg`, if any. In "dry run" mode via `Visit`, this invokes a visitor for
-the current endpoint.
+Returns a router that marks all routes declared downstream, including
+sub-routers, as deprecated. When a route matches, sets the `Deprecation`
+response header, the `Sunset` header with the given time unless it's zero,
+and the `Link` header with the given URL and relation type "deprecation"
+unless it's empty. Headers are applied like `Rou.SetHeader`. In "dry run"
+mode via `Visit`, this sets `EndpointInfo.Deprecated` and `EndpointInfo.Sunset`.
+Example:
+
+	rou.Sta(`/api/v1`).Deprecated(sunsetV1, `https://example.com/docs/v2`).Sub(routesApiV1)
 */
-func (self Rou) ParamHan(fun ParamHan) {
-	if self.isDone() || self.vis(fun) {
-		return
+func (self Rou) Deprecated(sunset time.Time, link string) Rou {
+	if !self.isReal() {
+		conf := self.setConf()
+		conf.Deprecation = true
+		conf.Sunset = sunset
 	}
-
-	args := self.Submatch()
-	if args == nil {
-		return
+	self = self.SetHeader(`Deprecation`, `true`)
+	if !sunset.IsZero() {
+		self = self.SetHeader(`Sunset`, sunset.UTC().Format(http.TimeFormat))
 	}
-
-	self.done(fun)
-
-	if fun != nil {
-		val := fun(self.Req, args)
-		if val != nil {
-			val.ServeHTTP(self.Rew, self.Req)
-		}
+	if link != `` {
+		self = self.SetHeader(`Link`, `<`+link+`>; rel="deprecation"`)
 	}
+	return self
 }
 
 /*
-If the router matches the request, use `Respond` to write the response returned
-by the given function. If the router doesn't match the request, do nothing.
-In "dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+Returns a router set to "dedup slash" mode, where runs of consecutive slashes
+in the request path are treated as a single slash when matching. For example,
+"/one//two" matches the pattern "/one/two". Only the path used for matching is
+affected; the request itself is not modified. Like "lax slash" mode, this is
+inherited by sub-routers and is usually set once at the top level. To redirect
+such requests to the canonical path instead, see `Rou.DedupSlashRedirect`.
+
+	rout.MakeRou(rew, req).DedupSlash().Serve(myRoutes)
 */
-func (self Rou) Res(fun Res) {
-	if self.isDone() || self.vis(fun) || !self.Match() {
-		return
-	}
-	self.done(fun)
-	if fun != nil {
-		try(Respond(self.Rew, fun(self.Req)))
-	}
+func (self Rou) DedupSlash() Rou {
+	self.SlashDedup = true
+	return self
 }
 
 /*
-If the router matches the request, use the given responder func to generate a
-response, and use `Respond` to write it. If the router doesn't match the
-request, do nothing. The func may be nil. The additional `[]string` argument
-contains regexp captures from the pattern passed to `Rou.Reg`, if any. In "dry
-run" mode via `Visit`, this invokes a visitor for the current endpoint.
+If the request path contains runs of consecutive slashes, responds with a
+redirect to the canonical path where each run is replaced with a single
+slash, preserving the query. Otherwise does nothing. Zero status is
+equivalent to `http.StatusMovedPermanently`. Meant to be called at the start
+of the top-level routing func. See `Rou.DedupSlash` for an alternative which
+doesn't redirect.
+
+	func myRoutes(rou rout.Rou) {
+		rou.DedupSlashRedirect(0)
+		rou.Exa(`/one/two`).Get().Han(pageOneTwo)
+	}
 */
-func (self Rou) ParamRes(fun ParamRes) {
-	if self.isDone() || self.vis(fun) {
+func (self Rou) DedupSlashRedirect(status int) {
+	if self.isDone() || !self.isReal() {
 		return
 	}
 
-	args := self.Submatch()
-	if args == nil {
+	path := reqPath(self.Req)
+	if !strings.Contains(path, `//`) {
 		return
 	}
 
-	self.done(fun)
-	if fun != nil {
-		try(Respond(self.Rew, fun(self.Req, args)))
+	loc := url.URL{Path: dedupSlash(path), RawQuery: self.Req.URL.RawQuery}
+	if status == 0 {
+		status = http.StatusMovedPermanently
 	}
+
+	self.mark(nil)
+	http.Redirect(self.Rew, self.Req, loc.String(), status)
 }
 
 /*
-Mostly for internal use. True if the router matches the request. If
-`.OnlyMethod` is true, matches only the request's method. Otherwise matches
-both the pattern and the method. If the pattern matches but the method doesn't,
-panics with `ErrMethodNotAllowed`; the panic is normally caught and returned
-via `Rou.Route`.
+Same as `.Meth(http.MethodGet)`.
+Returns a router that matches only this HTTP method.
 */
-func (self *Rou) Match() bool {
-	if self.OnlyMethod {
-		return self.matchMethod()
-	}
-	return self.matchStrict()
-}
+func (self Rou) Get() Rou { return self.Meth(http.MethodGet) }
 
 /*
-Mostly for internal use. Like `Rou.Match`, but instead of a boolean, returns a
-slice with captured args. If there's no match, the slice is nil. Otherwise, the
-slice is non-nil, and its length equals the amount of capture groups in the
-current pattern. If the pattern matches but the method doesn't, panics with
-`ErrMethodNotAllowed`; the panic is normally caught and returned via
-`Rou.Route`.
+Same as `.Meths(http.MethodGet, http.MethodHead)`.
+Returns a router that matches only these HTTP methods. Virtually every GET
+endpoint should also answer HEAD.
 */
-func (self *Rou) Submatch() []string {
-	if self.OnlyMethod {
-		return self.submatchOnlyMethod()
-	}
-	return self.submatchStrict()
-}
+func (self Rou) GetHead() Rou { return self.Meths(getHead...) }
 
-func (self *Rou) matchMethod() bool {
-	return self.Method == `` || self.Method == self.meth()
-}
+/*
+Same as `.Meth(http.MethodHead)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Head() Rou { return self.Meth(http.MethodHead) }
 
-func (self *Rou) matchPattern() bool {
-	return self.Style.Match(self.Pattern, self.path())
-}
+/*
+Same as `.Meth(http.MethodOptions)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Options() Rou { return self.Meth(http.MethodOptions) }
+
+/*
+Same as `.Meth(http.MethodPost)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Post() Rou { return self.Meth(http.MethodPost) }
+
+/*
+Same as `.Meth(http.MethodPatch)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Patch() Rou { return self.Meth(http.MethodPatch) }
+
+/*
+Same as `.Meth(http.MethodPut)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Put() Rou { return self.Meth(http.MethodPut) }
+
+/*
+Same as `.Meth(http.MethodDelete)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Delete() Rou { return self.Meth(http.MethodDelete) }
+
+/*
+Same as `.Meth(http.MethodConnect)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Connect() Rou { return self.Meth(http.MethodConnect) }
+
+/*
+Same as `.Meth(http.MethodTrace)`.
+Returns a router that matches only this HTTP method.
+*/
+func (self Rou) Trace() Rou { return self.Meth(http.MethodTrace) }
+
+/*
+Returns a router that additionally requires the given predicate to return true
+for the request. Meant for one-off conditions such as feature checks, which
+compose with other patterns, methods, and filters. The predicate is invoked
+only after the pattern matches. A mismatch causes the router to fall through
+to the next route, like other mismatches. Nil func matches any request.
+*/
+func (self Rou) If(fun func(*http.Request) bool) Rou {
+	return self.filter(FilterFunc(fun))
+}
+
+/*
+Returns a router that additionally requires the amount of non-empty segments
+in `req.URL.Path` to be within the given range, regardless of their content.
+See `Depth` for the exact rules. Negative `max` means no upper limit. Useful
+for guarding prefix-based sub-routers against overly deep paths. Example for
+"exactly 3 segments under /api":
+
+	rou.Sta(`/api`).Depth(4, 4).Sub(routesApi)
+*/
+func (self Rou) Depth(min, max int) Rou {
+	return self.filter(Depth{min, max})
+}
+
+/*
+Returns a router that additionally requires the URL query to have the given key
+with the given value, such as `?format=csv`. A mismatch causes the router to
+fall through to the next route, like other mismatches. See `Query`.
+*/
+func (self Rou) Query(key, val string) Rou {
+	return self.filter(Query{key, val})
+}
+
+/*
+Returns a router that additionally requires the URL query to have the given
+key, with any value. A mismatch causes the router to fall through to the next
+route, like other mismatches. See `QueryKey`.
+*/
+func (self Rou) QueryExists(key string) Rou {
+	return self.filter(QueryKey(key))
+}
+
+/*
+Short for "header". Returns a router that additionally requires the request to
+have the given header with the given value, such as
+`X-Requested-With: XMLHttpRequest`. A mismatch causes the router to fall
+through to the next route, like other mismatches. See `Hdr`.
+*/
+func (self Rou) Hdr(key, val string) Rou {
+	return self.filter(Hdr{key, val})
+}
+
+/*
+Short for "header prefix". Returns a router that additionally requires the
+request to have the given header with a value that begins with the given
+prefix, such as `Authorization: Bearer `. A mismatch causes the router to fall
+through to the next route, like other mismatches. See `HdrPrefix`.
+*/
+func (self Rou) HdrPrefix(key, val string) Rou {
+	return self.filter(HdrPrefix{key, val})
+}
+
+/*
+Returns a router that additionally requires the request's `Accept` header to
+accept at least one of the given media types, with proper parsing of q-values.
+A mismatch causes the router to fall through to the next route, like other
+mismatches. Within `Rou.Negotiate`, if no route matches, the result is
+`ErrNotAcceptable`. See `Accepts`.
+*/
+func (self Rou) Accepts(types ...string) Rou {
+	return self.filter(Accepts(types))
+}
+
+/*
+Returns a router that additionally requires the request's `Content-Type`
+header to match at least one of the given media types, such as
+"application/json" or "multipart/*". A mismatch causes the router to fall
+through to the next route, like other mismatches. Within `Rou.ContentTypes`,
+if no route matches, the result is `ErrUnsupportedMediaType`. See
+`ContentType`.
+*/
+func (self Rou) ContentType(types ...string) Rou {
+	return self.filter(ContentType(types))
+}
+
+/*
+Returns a router that additionally requires the request's scheme, as reported
+by `ReqScheme`, to equal the given scheme, such as "https". A mismatch causes
+the router to fall through to the next route, like other mismatches. Can be
+used for HTTPS-only subtrees, or for redirecting plain HTTP requests:
+
+	rou.Scheme(`http`).Handler(redirectToHttps)
+*/
+func (self Rou) Scheme(val string) Rou {
+	return self.filter(Scheme(val))
+}
+
+// Same as `.Scheme("https")`.
+func (self Rou) TLS() Rou { return self.Scheme(`https`) }
+
+/*
+Returns a router that additionally requires the request to have a cookie with
+the given name, with any value. Useful for routing logged-in vs anonymous
+traffic. A mismatch causes the router to fall through to the next route, like
+other mismatches. See `Cookie`.
+*/
+func (self Rou) Cookie(key string) Rou {
+	return self.filter(Cookie(key))
+}
+
+/*
+Returns a router that additionally requires the request to have a cookie with
+the given name and value. Useful for routing experiment cohorts. A mismatch
+causes the router to fall through to the next route, like other mismatches.
+See `CookieVal`.
+*/
+func (self Rou) CookieVal(key, val string) Rou {
+	return self.filter(CookieVal{key, val})
+}
+
+/*
+Short for "language". Returns a router that additionally requires any
+language accepted by the client according to `Accept-Language`, with q-values,
+to match one of the given language tags, such as "en" or "de". A mismatch
+causes the router to fall through to the next route, like other mismatches.
+See `Lang`. Example:
+
+	rou.Lang(`de`).Sub(routesDe)
+	rou.Sub(routesEn)
+*/
+func (self Rou) Lang(tags ...string) Rou {
+	return self.filter(Lang(tags))
+}
+
+/*
+Returns a router that additionally requires the client IP, taken from
+`req.RemoteAddr`, to belong to at least one of the given networks, such as
+"10.0.0.0/8". Useful for internal-only routes such as admin or debug
+endpoints. A mismatch causes the router to fall through to the next route,
+like other mismatches. Within `Rou.Restrict`, if no route matches, the result
+is `ErrForbidden`. See `CIDR`.
+*/
+func (self Rou) CIDR(nets ...string) Rou {
+	return self.filter(CIDR(nets))
+}
+
+/*
+Returns a router that additionally matches only the given percentage of
+clients, which allows A/B tests and gradual rollouts directly in the routing
+tree. The client is identified by the value of the given request header, and
+the decision is stable for that client. A request without the header doesn't
+match. A mismatch causes the router to fall through to the next route, like
+other mismatches. See `Weight`, which also describes how to keep multiple
+experiments independent. Example:
+
+	rou.Pat(`/checkout`).Weight(10, `X-Client-Id`).Han(pageCheckoutNew)
+	rou.Pat(`/checkout`).Han(pageCheckout)
+*/
+func (self Rou) Weight(percent int, header string) Rou {
+	return self.filter(Weight{Percent: percent, Header: header})
+}
+
+/*
+Same as `Rou.Weight`, but identifies the client by the value of the cookie with
+the given name. A request without the cookie doesn't match. See `Weight`.
+*/
+func (self Rou) WeightCookie(percent int, cookie string) Rou {
+	return self.filter(Weight{Percent: percent, Cookie: cookie})
+}
+
+/*
+Returns a router that additionally requires the request to be a protocol
+upgrade request with any protocol. A mismatch causes the router to fall through
+to the next route, like other mismatches. See `Upgrade`.
+*/
+func (self Rou) Upgrade() Rou { return self.filter(Upgrade(``)) }
+
+/*
+Returns a router that additionally requires the request to be a WebSocket
+upgrade request. This allows the same path to route upgrade requests to a
+socket handler and plain requests to a regular handler. A mismatch causes the
+router to fall through to the next route, like other mismatches. Example:
+
+	rou.Pat(`/events`).Get().Websocket().Handler(eventSocket)
+	rou.Pat(`/events`).Get().Han(pageEvents)
+*/
+func (self Rou) Websocket() Rou { return self.filter(Upgrade(`websocket`)) }
+
+/*
+If the router matches the request, perform sub-routing. If sub-routing doesn't
+find a match, panic with `ErrNotFound`. If the router doesn't match the
+request, do nothing. Filters such as `Rou.Depth` are considered satisfied and
+are not passed to the sub-router. Captures of the current pattern, if any, are
+passed to the sub-router, and nested parametrized endpoints such as
+`Rou.ParamFunc` receive them before their own captures. This allows nested
+patterns to capture only their own part of the path:
+
+	rou.Reg(`^/orgs/(?P<org>[^/]+)/`).Sub(func(rou rout.Rou) {
+		// args = [org, repo]
+		rou.Reg(`^/orgs/[^/]+/repos/(?P<repo>[^/]+)$`).Get().ParamHan(repoGet)
+	})
+
+Nested routes which inherit the current pattern, or repeat it verbatim,
+receive its captures only once.
+*/
+func (self Rou) Sub(fun func(Rou)) {
+	self.sub(fun, ErrNotFoundBase)
+}
+
+/*
+Same as `Rou.Sub`, but if sub-routing via `fun` doesn't find a match, performs
+sub-routing via `alt` with the same router, rather than immediately panicking
+with `ErrNotFound`. If neither finds a match, panics with `ErrNotFound`. Allows
+layered routing, such as API routes first, then an SPA shell for any other
+page. Example:
+
+	rou.Sta(`/`).Else(routesApi, func(rou rout.Rou) {
+		rou.GetHead().Han(pageSpaShell)
+	})
+
+In "dry run" mode via `Visit`, both functions are visited.
+*/
+func (self Rou) Else(fun, alt func(Rou)) {
+	self.sub(func(rou Rou) {
+		if fun != nil {
+			fun(rou)
+		}
+		if alt != nil && !rou.isDone() {
+			alt(rou)
+		}
+	}, ErrNotFoundBase)
+}
+
+/*
+Same as `Rou.Sub`, but if sub-routing doesn't find a match, panics with
+`ErrNotAcceptable` rather than `ErrNotFound`. Meant for content negotiation
+via `Rou.Accepts`. Example:
+
+	rou.Pat(`/articles`).Get().Negotiate(func(rou rout.Rou) {
+		rou.Accepts(`application/json`).Han(apiArticles)
+		rou.Accepts(`text/html`).Han(pageArticles)
+	})
+*/
+func (self Rou) Negotiate(fun func(Rou)) {
+	self.sub(fun, ErrNotAcceptableBase)
+}
+
+/*
+Same as `Rou.Sub`, but if sub-routing doesn't find a match, panics with
+`ErrUnsupportedMediaType` rather than `ErrNotFound`. Meant for branching on
+the request body encoding via `Rou.ContentType`. Example:
+
+	rou.Pat(`/articles`).Post().ContentTypes(func(rou rout.Rou) {
+		rou.ContentType(`application/json`).Han(apiArticleCreateJson)
+		rou.ContentType(`multipart/*`).Han(apiArticleCreateForm)
+	})
+*/
+func (self Rou) ContentTypes(fun func(Rou)) {
+	self.sub(fun, ErrUnsupportedMediaTypeBase)
+}
+
+/*
+Same as `Rou.Sub`, but if sub-routing doesn't find a match, panics with
+`ErrForbidden` rather than `ErrNotFound`. Meant for guarding subtrees with
+filters such as `Rou.CIDR`. Example:
+
+	rou.Sta(`/debug`).Restrict(func(rou rout.Rou) {
+		rou.CIDR(`10.0.0.0/8`, `127.0.0.1`).Sub(routesDebug)
+	})
+*/
+func (self Rou) Restrict(fun func(Rou)) {
+	self.sub(fun, ErrForbiddenBase)
+}
+
+/*
+Declares a group of routes sharing the settings of the current router, such as
+the method, host pattern, filters, and "lax slash" mode. Unlike `Rou.Sub`, the
+settings are passed to the routes inside the group, rather than being
+satisfied by the group itself, and a group is not terminal: if no route in the
+group matches, routing continues after the group, without generating an error.
+If the current router has a pattern which doesn't match the request, the group
+is skipped entirely. Routes inside the group may override the pattern and
+method; filters accumulate. Example:
+
+	rou.Get().Hdr(`X-Api-Version`, `2`).Group(func(rou rout.Rou) {
+		rou.Pat(`/articles`).Han(apiArticlesV2)
+		rou.Pat(`/articles/{}`).ParamHan(apiArticleV2)
+	})
+
+In "dry run" mode via `Visit`, the group is always visited.
+*/
+func (self Rou) Group(fun func(Rou)) {
+	if self.isDone() || (self.isReal() && !self.matchPattern()) {
+		return
+	}
+
+	var ok bool
+	if self.conf().Catch != nil && self.isReal() {
+		defer self.catch(&ok)
+	}
+	if fun != nil {
+		self.resetInfo()
+		self.enter()
+		fun(self)
+	}
+	ok = true
+}
+
+/*
+If the router matches the request, perform sub-routing. The router provided to
+the function is set to "method only" mode: a mismatch in the HTTP method
+doesn't immediately generate an error. However, if sub-routing doesn't find a
+match, this panics with `ErrMethodNotAllowed`, after setting the `Allow`
+response header to the list of methods declared in the block. If the request
+method is OPTIONS and the block doesn't declare it, this responds with status
+204 and the `Allow` header instead, like a regular handler, running middleware
+added via `Rou.Use`. If the router doesn't match the request, do nothing. The
+declared methods are collected while sub-routing, without re-running the
+block.
+*/
+func (self Rou) Methods(fun func(Rou)) {
+	if self.isDone() || (self.isReal() && !self.matchPatternFilter()) {
+		return
+	}
+	var ok bool
+	if self.conf().Catch != nil && self.isReal() {
+		defer self.catch(&ok)
+	}
+
+	var allow allowSet
+	if fun != nil {
+		self.Filter = nil
+		self.enter()
+		allow = self.methods(fun)
+	}
+	ok = true
+	if !self.isDone() && self.isReal() {
+		self.allow(allow)
+	}
+}
+
+/*
+Runs a `Rou.Methods` block, returning the methods of routes rejected by their
+method, which are collected in `Rou.Mut`. For HEAD requests with automatic
+fallback enabled, the block is first run in "strict head" mode to give
+priority to explicit HEAD handlers, and then again, if nothing matched, to
+fall back on GET handlers.
+*/
+func (self Rou) methods(fun func(Rou)) allowSet {
+	self = self.MethodOnly()
+	if !self.isReal() {
+		fun(self)
+		return allowSet{}
+	}
+
+	mut := self.mut()
+	prev := allowSet{mut.allowStd, mut.Allow}
+	mut.allowStd, mut.Allow = 0, nil
+
+	if self.HeadStrict || self.meth() != http.MethodHead {
+		fun(self)
+	} else {
+		fun(self.StrictHead())
+		if !self.isDone() {
+			fun(self)
+		}
+	}
+
+	out := allowSet{mut.allowStd, mut.Allow}
+	mut.allowStd, mut.Allow = prev.Std, prev.Custom
+	return out
+}
+
+/*
+Used by `Rou.Methods` when no method matched. Either responds to OPTIONS with
+status 204, like a regular handler, or panics with `ErrMethodNotAllowed`,
+setting the `Allow` header in both cases.
+*/
+func (self Rou) allow(methods allowSet) {
+	if !self.HeadStrict && methods.has(http.MethodGet) {
+		methods.add(http.MethodHead)
+	}
+	methods.add(http.MethodOptions)
+
+	allow := methods.String()
+	if self.Rew != nil {
+		self.Rew.Header().Set(`Allow`, allow)
+	}
+
+	if self.meth() == http.MethodOptions {
+		self.mark(nil)
+		serve(&self, StatusOnly(http.StatusNoContent))
+		return
+	}
+	try(self.notAllowed(allow))
+}
+
+/*
+If the router matches the request, use the given handler to respond. If the
+router doesn't match the request, do nothing. The handler may be nil. In
+"dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+*/
+func (self Rou) Handler(val http.Handler) {
+	if self.isDone() || self.vis(val) || !self.Match() {
+		return
+	}
+	self.done(val)
+	if val != nil {
+		serve(&self, val)
+	}
+}
+
+/*
+If the router matches the request, use the given handler func to respond.
+If the router doesn't match the request, do nothing. The func may be nil. In
+"dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+*/
+func (self Rou) Func(fun Func) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+	self.done(fun)
+	if fun != nil {
+		serve(&self, http.HandlerFunc(fun))
+	}
+}
+
+/*
+If the router matches the request, use the given handler func to respond. If the
+router doesn't match the request, do nothing. The func may be nil. The
+additional `[]string` argument contains regexp captures from the pattern passed
+to `Rou.Reg`, if any. In "dry run" mode via `Visit`, this invokes a visitor for
+the current endpoint.
+*/
+func (self Rou) ParamFunc(fun ParamFunc) {
+	if self.isDone() || self.vis(fun) {
+		return
+	}
+
+	args := self.Submatch()
+	if args == nil {
+		return
+	}
+
+	self.done(fun)
+	if fun != nil {
+		serve(&self, paramFunc{fun, args})
+	}
+}
+
+/*
+If the router matches the request, use the given handler func to respond. If
+the router doesn't match the request, do nothing. The func may be nil. The
+additional map argument contains named captures from the pattern, keyed by
+names such as "{id}" in `Rou.Pat` or "(?P<id>...)" in `Rou.Reg`; unnamed
+captures are omitted. In "dry run" mode via `Visit`, this invokes a visitor
+for the current endpoint.
+*/
+func (self Rou) ParamMapFunc(fun ParamMapFunc) {
+	if self.isDone() || self.vis(fun) {
+		return
+	}
+
+	args := self.Submatch()
+	if args == nil {
+		return
+	}
+
+	self.done(fun)
+	if fun != nil {
+		serve(&self, paramMapFunc{fun, paramMap(self.names(), args)})
+	}
+}
+
+/*
+If the router matches the request, use the given handler func to respond. If
+the router doesn't match the request, do nothing. The func may be nil. If the
+func returns a non-nil error, it's propagated via panic, just like routing
+errors, and is normally returned by `Rou.Route` or written by `Rou.Serve`.
+In "dry run" mode via `Visit`, this invokes a visitor for the current
+endpoint.
+*/
+func (self Rou) ErrFunc(fun ErrFunc) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+	self.done(fun)
+	if fun != nil {
+		serve(&self, errFunc(fun))
+	}
+}
+
+/*
+If the router matches the request, use the given handler func to respond. If
+the router doesn't match the request, do nothing. The func may be nil. The
+additional `[]string` argument contains regexp captures from the pattern passed
+to `Rou.Reg`, if any. If the func returns a non-nil error, it's propagated via
+panic, just like routing errors, and is normally returned by `Rou.Route`. In
+"dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+*/
+func (self Rou) ParamErrFunc(fun ParamErrFunc) {
+	if self.isDone() || self.vis(fun) {
+		return
+	}
+
+	args := self.Submatch()
+	if args == nil {
+		return
+	}
+
+	self.done(fun)
+	if fun != nil {
+		serve(&self, paramErrFunc{fun, args})
+	}
+}
+
+/*
+If the router matches the request, respond by using the handler returned by the
+given function. If the router doesn't match the request, do nothing. In "dry
+run" mode via `Visit`, this invokes a visitor for the current endpoint.
+*/
+func (self Rou) Han(fun Han) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+
+	self.done(fun)
+
+	if fun != nil {
+		serve(&self, han(fun))
+	}
+}
+
+/*
+Same as `Rou.Han`, but takes multiple functions, using the first non-nil
+handler returned by one of them, via `Coalesce`. Allows to declare fallback
+chains such as "cache, then database, then 404". If every function returns
+nil, nothing is written, and the response has status 200. In "dry run" mode
+via `Visit`, the visited endpoint uses the identity of the first function.
+Example:
+
+	rou.Pat(`/articles/{}`).Get().HanAny(articleCached, articleStored, articleMissing)
+*/
+func (self Rou) HanAny(funs ...Han) {
+	if self.isDone() || self.vis(hanFirst(funs)) || !self.Match() {
+		return
+	}
+	self.done(hanFirst(funs))
+	serve(&self, Coalesce(funs))
+}
+
+/*
+If the router matches the request, respond by using the handler returned by the
+given function. If the router doesn't match the request, do nothing. If the
+function returns a non-nil error, the handler is ignored, and the error is
+propagated via panic, just like routing errors, and is normally returned by
+`Rou.Route`. In "dry run" mode via `Visit`, this invokes a visitor for the
+current endpoint.
+*/
+func (self Rou) HanErr(fun HanErr) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+
+	self.done(fun)
+
+	if fun != nil {
+		serve(&self, hanErr(fun))
+	}
+}
+
+/*
+If the router matches the request, respond by using the handler returned by the
+given function. If the router doesn't match the request, do nothing. The
+additional `[]string` argument contains regexp captures from the pattern passed
+to `Rou.Reg`, if any. In "dry run" mode via `Visit`, this invokes a visitor for
+the current endpoint.
+*/
+func (self Rou) ParamHan(fun ParamHan) {
+	if self.isDone() || self.vis(fun) {
+		return
+	}
+
+	args := self.Submatch()
+	if args == nil {
+		return
+	}
+
+	self.done(fun)
+
+	if fun != nil {
+		serve(&self, paramHan{fun, args})
+	}
+}
+
+/*
+If the router matches the request, respond by using the handler returned by the
+given function. If the router doesn't match the request, do nothing. The
+additional map argument contains named captures from the pattern; see
+`Rou.ParamMapFunc`. In "dry run" mode via `Visit`, this invokes a visitor for
+the current endpoint.
+*/
+func (self Rou) ParamMapHan(fun ParamMapHan) {
+	if self.isDone() || self.vis(fun) {
+		return
+	}
+
+	args := self.Submatch()
+	if args == nil {
+		return
+	}
+
+	self.done(fun)
+
+	if fun != nil {
+		serve(&self, paramMapHan{fun, paramMap(self.names(), args)})
+	}
+}
+
+/*
+If the router matches the request, use `Respond` to write the response returned
+by the given function. If the router doesn't match the request, do nothing.
+In "dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+*/
+func (self Rou) Res(fun Res) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+	self.done(fun)
+	if fun != nil {
+		serve(&self, res{fun, self.conf().Respond})
+	}
+}
+
+/*
+Same as `Rou.Res`, but takes multiple functions, using `Respond` to write the
+first non-nil response returned by one of them, via `CoalesceRes`. If every
+function returns nil, nothing is written, and the response has status 200. In
+"dry run" mode via `Visit`, the visited endpoint uses the identity of the first
+function.
+*/
+func (self Rou) ResAny(funs ...Res) {
+	if self.isDone() || self.vis(resFirst(funs)) || !self.Match() {
+		return
+	}
+	self.done(resFirst(funs))
+	serve(&self, resAny{funs, self.conf().Respond})
+}
+
+/*
+If the router matches the request, write the status and value returned by the
+given function, encoding the value via the encoder set by `Rou.Encoder`, which
+defaults to `EncodeJSON`. A nil value writes only the status. If the router
+doesn't match the request, do nothing. An encoding error is propagated via
+panic, just like routing errors, and is normally returned by `Rou.Route`. In
+"dry run" mode via `Visit`, this invokes a visitor for the current endpoint.
+Example:
+
+	rou.Exa(`/api/status`).Get().Reply(func(*http.Request) (int, interface{}) {
+		return http.StatusOK, map[string]string{"status": "ok"}
+	})
+*/
+func (self Rou) Reply(fun Reply) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+	self.done(fun)
+	if fun != nil {
+		serve(&self, reply{fun, self.conf().Encode})
+	}
+}
+
+/*
+If the router matches the request, write the header and status returned by the
+given function, then invoke the returned body function, if any, to stream the
+response body. If the router doesn't match the request, do nothing. A non-nil
+error returned by the body function is propagated via panic, just like routing
+errors, and is normally returned by `Rou.Route`; since the status has already
+been sent, it's mostly useful for logging. In "dry run" mode via `Visit`, this
+invokes a visitor for the current endpoint. Example:
+
+	rou.Exa(`/export`).Get().Stream(func(req *http.Request) (http.Header, int, func(io.Writer) error) {
+		head := http.Header{`Content-Type`: {`text/csv`}}
+		return head, http.StatusOK, func(out io.Writer) error { return writeExport(req, out) }
+	})
+*/
+func (self Rou) Stream(fun Stream) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+	self.done(fun)
+	if fun != nil {
+		serve(&self, stream(fun))
+	}
+}
+
+/*
+If the router matches the request, use `Respond` to write the response returned
+by the given function. If the router doesn't match the request, do nothing. If
+the function returns a non-nil error, the response is ignored (its body is
+closed, if any), and the error is propagated via panic, just like routing
+errors, and is normally returned by `Rou.Route`. In "dry run" mode via
+`Visit`, this invokes a visitor for the current endpoint.
+*/
+func (self Rou) ResErr(fun ResErr) {
+	if self.isDone() || self.vis(fun) || !self.Match() {
+		return
+	}
+	self.done(fun)
+	if fun != nil {
+		serve(&self, resErr{fun, self.conf().Respond})
+	}
+}
+
+/*
+If the router matches the request, use the given responder func to generate a
+response, and use `Respond` to write it. If the router doesn't match the
+request, do nothing. The func may be nil. The additional `[]string` argument
+contains regexp captures from the pattern passed to `Rou.Reg`, if any. In "dry
+run" mode via `Visit`, this invokes a visitor for the current endpoint.
+*/
+func (self Rou) ParamRes(fun ParamRes) {
+	if self.isDone() || self.vis(fun) {
+		return
+	}
+
+	args := self.Submatch()
+	if args == nil {
+		return
+	}
+
+	self.done(fun)
+	if fun != nil {
+		serve(&self, paramRes{fun, args, self.conf().Respond})
+	}
+}
+
+/*
+If the router matches the request, respond with a redirect to the given target
+URL, using `http.Redirect`. If the router doesn't match the request, do
+nothing. Zero status is equivalent to `http.StatusFound`. The target may
+refer to captures from the pattern: "{0}", "{1}" and so on refer to captures
+by index, while "{id}" refers to a named capture such as "{id}" in `Rou.Pat`
+or "(?P<id>...)" in `Rou.Reg`. Captures are escaped as URL paths; unknown
+references are left as-is. Example:
+
+	rou.Pat(`/old/{id}`).Redirect(http.StatusMovedPermanently, `/new/{id}`)
+	rou.Reg(`^/blog/(\d+)$`).Redirect(http.StatusMovedPermanently, `/articles/{0}`)
+
+In "dry run" mode via `Visit`, this invokes a visitor for the current endpoint,
+using the target as the handler.
+*/
+func (self Rou) Redirect(status int, target string) {
+	if self.isDone() || self.vis(target) {
+		return
+	}
+
+	args := self.Submatch()
+	if args == nil {
+		return
+	}
+
+	self.done(target)
+
+	if status == 0 {
+		status = http.StatusFound
+	}
+	serve(&self, redirect{status, interpolate(target, self.names(), args)})
+}
+
+/*
+Shortcut for one-call route registration. Uses `Rou.Pat` for the pattern,
+`Rou.Meth` for the method, and dispatches by the type of the handler, which
+may be any of: `http.Handler`, `Func`, `ErrFunc`, `ParamFunc`, `ParamErrFunc`,
+`ParamMapFunc`, `Han`, `HanErr`, `ParamHan`, `ParamMapHan`, `Res`, `ResErr`,
+`ParamRes`, or a routing func `func(Rou)` or `RouFunc`, which is used for
+sub-routing via `Rou.Sub`. Nil is equivalent to a nil `http.Handler`. Other
+types cause a panic. Example:
+
+	rou.Handle(http.MethodGet, `/articles`, apiArticles)
+	rou.Handle(http.MethodGet, `/articles/{}`, apiArticle)
+	rou.Handle(``, `/static/{}`, fileServer)
+*/
+func (self Rou) Handle(meth, pattern string, val interface{}) {
+	self = self.Meth(meth).Pat(pattern)
+
+	switch val := val.(type) {
+	case nil:
+		self.Handler(nil)
+	case func(Rou):
+		self.Sub(val)
+	case RouFunc:
+		self.Sub(val)
+	case http.Handler:
+		self.Handler(val)
+	case Func:
+		self.Func(val)
+	case ErrFunc:
+		self.ErrFunc(val)
+	case ParamFunc:
+		self.ParamFunc(val)
+	case ParamErrFunc:
+		self.ParamErrFunc(val)
+	case ParamMapFunc:
+		self.ParamMapFunc(val)
+	case Han:
+		self.Han(val)
+	case HanErr:
+		self.HanErr(val)
+	case ParamHan:
+		self.ParamHan(val)
+	case ParamMapHan:
+		self.ParamMapHan(val)
+	case Res:
+		self.Res(val)
+	case ResErr:
+		self.ResErr(val)
+	case ParamRes:
+		self.ParamRes(val)
+	default:
+		panic(fmt.Errorf(
+			`[rout] unsupported handler type %T for route %q %q`, val, meth, pattern,
+		))
+	}
+}
+
+/*
+Terminal catch-all. Uses the given handler to respond to every remaining
+request that reaches this point, regardless of pattern and method. Filters, if
+any, still apply. Meant as an explicit fallback at the end of a sub-router,
+before the automatic `ErrNotFound`. Same as `.Meth("").Exa("").Handler(val)`.
+*/
+func (self Rou) Any(val http.Handler) {
+	self.Meth(``).Exa(``).Handler(val)
+}
+
+/*
+Terminal catch-all. Uses the given handler func to respond to every remaining
+request that reaches this point, regardless of pattern and method. Filters, if
+any, still apply. Same as `.Meth("").Exa("").Func(fun)`. Also see `Rou.Any`.
+*/
+func (self Rou) AnyFunc(fun Func) {
+	self.Meth(``).Exa(``).Func(fun)
+}
+
+/*
+Short for "exact matches". Uses the current path as a key to find a `Han` in
+the given map, and if found, behaves like `.Exa(path).Han(fun)`. Unlike a
+sequence of `Rou.Exa` calls, this performs one map lookup regardless of the
+amount of routes, which makes it more efficient for large flat route lists.
+The map should be defined once and reused, rather than created in a routing
+func on every request. Respects the router's method and filter, if any. In
+"dry run" mode via `Visit`, this visits every entry in the order of sorted
+keys. In "lax slash" mode, also tries the path with a toggled trailing slash.
+*/
+func (self Rou) Exacts(val map[string]Han) {
+	if self.isDone() {
+		return
+	}
+
+	if !self.isReal() {
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			self.Exa(key).Han(val[key])
+		}
+		return
+	}
+
+	path := self.path()
+	fun, ok := val[path]
+	if !ok && self.SlashLax {
+		path = toggleSlashSuffix(path)
+		fun, ok = val[path]
+	}
+	if ok {
+		self.Exa(path).Han(fun)
+	}
+}
+
+/*
+Mostly for internal use. True if the router matches the request. If
+`.OnlyMethod` is true, matches only the request's method and `.Filter`.
+Otherwise matches the pattern, `.Filter`, and the method. If the pattern and
+filter match but the method doesn't, panics with `ErrMethodNotAllowed`, unless in
+"lax method" mode via `Rou.Lax`; the panic is normally caught and returned via
+`Rou.Route`.
+*/
+func (self *Rou) Match() bool {
+	if self.OnlyMethod {
+		return self.matchOnlyMethod()
+	}
+	return self.matchStrict()
+}
+
+/*
+Mostly for internal use. Like `Rou.Match`, but instead of a boolean, returns a
+slice with captured args. If there's no match, the slice is nil. Otherwise, the
+slice is non-nil, and its length equals the amount of capture groups in the
+current pattern. If the pattern matches but the method doesn't, panics with
+`ErrMethodNotAllowed`, unless in "lax method" mode via `Rou.Lax`; the panic is
+normally caught and returned via `Rou.Route`.
+*/
+func (self *Rou) Submatch() []string {
+	if self.OnlyMethod {
+		return self.submatchOnlyMethod()
+	}
+	return self.submatchStrict()
+}
+
+/*
+Returns the endpoint matched by routing, and true if a route was matched.
+Because `Rou.Mut` is shared between the router and all its copies, this can be
+called after `Rou.Route` or `Rou.Serve`, for example to log or meter the
+matched pattern and handler. Unlike most methods, this doesn't panic when
+`.Mut` is nil. Example:
+
+	rou := rout.MakeRou(rew, req)
+	rou.Serve(myRoutes)
+	end, ok := rou.Matched()
+*/
+func (self *Rou) Matched() (Endpoint, bool) {
+	mut := self.Mut
+	if mut == nil || !mut.Done {
+		return Endpoint{}, false
+	}
+	return mut.Endpoint, true
+}
+
+func (self *Rou) matchMethod() bool {
+	return self.matchMethodOwn() || self.headFallback()
+}
+
+func (self *Rou) matchMethodOwn() bool {
+	if self.MethodList == nil && self.Method == `` {
+		return true
+	}
+	return self.hasMethod(self.meth())
+}
+
+func (self *Rou) hasMethod(meth string) bool {
+	if self.MethodList != nil {
+		for _, val := range self.MethodList {
+			if val == meth {
+				return true
+			}
+		}
+		return false
+	}
+	return self.Method == meth
+}
+
+/*
+True if the request is HEAD and matches the router only because of the
+automatic fallback to GET. See `Rou.StrictHead`.
+*/
+func (self *Rou) headFallback() bool {
+	return !self.HeadStrict &&
+		self.meth() == http.MethodHead &&
+		!self.matchMethodOwn() &&
+		self.hasMethod(http.MethodGet)
+}
+
+func (self *Rou) matchPattern() bool {
+	return self.Style.Match(self.patternPath()) && self.matchHost()
+}
+
+func (self *Rou) matchFilter() bool {
+	val := self.Filter
+	return val == nil || val.Match(self.Req)
+}
 
 func (self *Rou) submatchPattern() []string {
-	return self.Style.Submatch(self.Pattern, self.path())
+	args := self.submatchOwn()
+	if args == nil || self.Captures == nil {
+		return args
+	}
+	return concat(self.Captures.Args, args)
+}
+
+func (self *Rou) submatchOwn() []string {
+	args := self.Style.Submatch(self.patternPath())
+	if args != nil && self.Captured {
+		args = args[:0:0]
+	}
+	pattern := self.conf().HostPattern
+	if args == nil || pattern == `` {
+		return args
+	}
+
+	host := cachedHost(pattern).Submatch(reqHost(self.Req))
+	if host == nil {
+		return nil
+	}
+	return append(args, host...)
+}
+
+// Names of capture groups, positionally matching `Rou.Submatch`.
+func (self *Rou) names() []string {
+	if self.Captures == nil {
+		return self.namesOwn()
+	}
+	return concat(self.Captures.Names, self.namesOwn())
+}
+
+// Names of capture groups in the router's own pattern and host pattern.
+func (self *Rou) namesOwn() []string {
+	var out []string
+	if !self.Captured {
+		out = self.Style.Names(self.Pattern)
+	}
+	pattern := self.conf().HostPattern
+	if pattern == `` {
+		return out
+	}
+	return concat(out, braceNames(pattern))
+}
+
+/*
+Used by sub-routing methods. Stores captures of the current path pattern, if
+any, so that nested routes receive them before their own captures. Host
+captures are not stored, because the host pattern is inherited by nested
+routes, which capture it again. `.Captured` indicates that the captures of
+the current pattern are already stored, which is the case for nested routes
+which inherit or repeat the pattern; their captures are not duplicated.
+*/
+func (self *Rou) capture() {
+	if self.Captured {
+		return
+	}
+	names := self.Style.Names(self.Pattern)
+	if len(names) == 0 {
+		return
+	}
+	args := self.Style.Submatch(self.patternPath())
+	prev := self.Captures
+	if prev != nil {
+		args, names = concat(prev.Args, args), concat(prev.Names, names)
+	}
+	self.Captures = &Captures{args, names}
+	self.Captured = true
+}
+
+func (self *Rou) matchHost() bool {
+	pattern := self.conf().HostPattern
+	return pattern == `` || cachedHost(pattern).Match(reqHost(self.Req))
+}
+
+func (self *Rou) patternPath() (string, string) {
+	if self.SlashLax && (self.Style == MatchExa || self.Style == MatchPat) {
+		return trimSlashSuffix(self.Pattern), trimSlashSuffix(self.path())
+	}
+	return self.Pattern, self.path()
+}
+
+func (self *Rou) matchPatternFilter() bool {
+	return self.matchPattern() && self.matchFilter()
+}
+
+func (self Rou) sub(fun func(Rou), err ErrRoute) {
+	if self.isDone() || (self.isReal() && !self.Match()) {
+		return
+	}
+	var ok bool
+	if self.conf().Catch != nil && self.isReal() {
+		defer self.catch(&ok)
+	}
+	if fun != nil {
+		self.Filter = nil
+		self.resetInfo()
+		if self.isReal() {
+			self.capture()
+		}
+		self.enter()
+		fun(self)
+	}
+	ok = true
+	if !self.isDone() && self.isReal() {
+		if self.Mut.Mismatch {
+			try(self.notAllowed(strings.Join(self.Mut.Allow, `, `)))
+		}
+		try(self.routeErr(err))
+	}
+}
+
+/*
+Used by sub-routing methods when `.Catch` is set. See `Rou.OnErr`. The flag
+must be set after sub-routing returns normally; see `rec`.
+*/
+func (self *Rou) catch(ok *bool) {
+	err := recErr(recover(), *ok)
+	if err == nil {
+		return
+	}
+	self.mut().Done = true
+	self.conf().Catch(self.Rew, self.Req, err)
+}
+
+func (self Rou) filter(val Filter) Rou {
+	switch prev := self.Filter.(type) {
+	case nil:
+		self.Filter = val
+	case Filters:
+		self.Filter = append(prev[:len(prev):len(prev)], val)
+	default:
+		self.Filter = Filters{prev, val}
+	}
+	return self
 }
 
 func (self Rou) pat(pattern string, style Match) Rou {
+	if pattern != self.Pattern || style != self.Style {
+		self.Captured = false
+	}
 	self.Pattern = pattern
 	self.Style = style
 	self.OnlyMethod = false
@@ -423,25 +1892,82 @@ func (self *Rou) meth() string {
 }
 
 func (self *Rou) path() string {
-	req := self.Req
-	if req != nil && req.URL != nil {
-		return req.URL.Path
+	out := reqPath(self.Req)
+	if self.SlashDedup {
+		return dedupSlash(out)
 	}
-	return ``
+	return out
 }
 
 func (self *Rou) mut() *Mut {
-	out := self.Mut
-	if out == nil {
+	if !self.isInit() {
 		panic(ErrInit)
 	}
-	return out
+	return self.Mut
+}
+
+/*
+True if the router was created via `MakeRou` or `Visit`. Outside of "dry run"
+mode, a router without a request or response writer is considered
+uninitialized, which catches routers constructed directly as `Rou{}`.
+*/
+func (self *Rou) isInit() bool {
+	return self.Mut != nil && (!self.isReal() || (self.Req != nil && self.Rew != nil))
 }
 
 func (self *Rou) done(val interface{}) {
+	self.mark(val)
+	try(self.admit())
+}
+
+func (self *Rou) mark(val interface{}) {
 	mut := self.mut()
 	mut.Done = true
-	mut.Endpoint = self.endpoint(val)
+	if self.MethodList == nil {
+		mut.Endpoint = self.endpoint(val)
+	} else if self.headFallback() {
+		mut.Endpoint = self.endpointMethod(val, http.MethodGet)
+	} else {
+		mut.Endpoint = self.endpointMethod(val, self.meth())
+	}
+
+	headers := self.conf().Headers
+	if len(headers) > 0 && self.Rew != nil {
+		head := self.Rew.Header()
+		for _, val := range headers {
+			head.Set(val[0], val[1])
+		}
+	}
+}
+
+/*
+Runs guards after a match, and sends early hints only if the guards pass,
+to avoid revealing resources to rejected requests. See `Rou.Hints`.
+*/
+func (self *Rou) admit() error {
+	err := self.guard()
+	if err != nil {
+		return err
+	}
+	hints := self.conf().EarlyHints
+	if len(hints) > 0 && self.Rew != nil && !isHttp10(self.Req) {
+		EarlyHints(self.Rew, hints...)
+	}
+	return nil
+}
+
+func (self *Rou) guard() error {
+	for _, fun := range self.conf().Guards {
+		err := fun(self.Req)
+		if err == nil {
+			continue
+		}
+		if ErrStatus(err) == 0 {
+			return guardErr{err}
+		}
+		return err
+	}
+	return nil
 }
 
 func (self *Rou) isDone() bool { return self.mut().Done }
@@ -450,52 +1976,266 @@ func (self *Rou) isReal() bool { return self.Vis == nil }
 
 func (self *Rou) vis(val interface{}) bool {
 	vis := self.Vis
-	if vis != nil {
-		vis.Endpoint(self.endpoint(val))
+	if vis == nil {
+		return false
+	}
+
+	if self.MethodList == nil {
+		self.visit(vis, val, self.endpoint(val))
 		return true
 	}
-	return false
+
+	for _, meth := range self.MethodList {
+		self.visit(vis, val, self.endpointMethod(val, meth))
+	}
+	return true
+}
+
+// Passes the endpoint to the visitor, with introspection data if supported.
+func (self *Rou) visit(vis Visitor, val interface{}, out Endpoint) {
+	impl, ok := vis.(InfoVisitor)
+	if ok {
+		impl.EndpointInfo(self.info(out, IdentName(Ident(val))))
+	} else {
+		vis.Endpoint(out)
+	}
+}
+
+/*
+In "dry run" mode via `Visit`, records the current pattern as the parent of
+the routes declared in a nested block.
+*/
+func (self *Rou) enter() {
+	if !self.isReal() {
+		conf := self.setConf()
+		conf.Parent = &Scope{self.Pattern, self.Style, conf.Parent}
+	}
 }
 
 func (self *Rou) endpoint(val interface{}) Endpoint {
-	return Endpoint{self.Pattern, self.Style, self.Method, Ident(val)}
+	return self.endpointMethod(val, self.Method)
+}
+
+func (self *Rou) endpointMethod(val interface{}, meth string) Endpoint {
+	return Endpoint{
+		Pattern: self.Pattern,
+		Match:   self.Style,
+		Method:  meth,
+		Handler: Ident(val),
+	}
+}
+
+// Used only by `Visit`.
+func (self *Rou) info(val Endpoint, handlerName string) EndpointInfo {
+	conf := self.conf()
+	return EndpointInfo{
+		Endpoint:    val,
+		HandlerName: handlerName,
+		Name:        conf.EndpointName,
+		Desc:        conf.EndpointDesc,
+		Deprecated:  conf.Deprecation,
+		Sunset:      conf.Sunset,
+		Parent:      conf.Parent,
+	}
+}
+
+// Used by `Rou.Sub` and `Rou.Group`, which don't pass annotations down.
+func (self *Rou) resetInfo() {
+	conf := self.Conf
+	if conf != nil && (conf.EndpointName != `` || conf.EndpointDesc != ``) {
+		conf = self.setConf()
+		conf.EndpointName, conf.EndpointDesc = ``, ``
+	}
 }
 
 func (self *Rou) matchStrict() bool {
-	if !self.matchPattern() {
+	if !self.matchPatternFilter() {
 		return false
 	}
 	if self.matchMethod() {
 		return true
 	}
-	panic(MethodNotAllowed(self.req()))
+	return self.mismatch()
 }
 
 func (self Rou) submatchOnlyMethod() []string {
-	if self.matchMethod() {
+	if self.matchOnlyMethod() {
 		return self.submatchPattern()
 	}
 	return nil
 }
 
+/*
+Used in "method only" mode. On a method mismatch, records the methods of the
+router in `Rou.Mut`, which is how `Rou.Methods` finds the declared methods.
+Standard methods are recorded without allocating.
+*/
+func (self *Rou) matchOnlyMethod() bool {
+	if self.matchMethod() {
+		return self.matchFilter()
+	}
+
+	mut := self.Mut
+	if mut == nil {
+		return false
+	}
+
+	set := allowSet{mut.allowStd, mut.Allow}
+	if self.MethodList != nil {
+		for _, val := range self.MethodList {
+			set.add(val)
+		}
+	} else if self.Method != `` {
+		set.add(self.Method)
+	}
+	mut.allowStd, mut.Allow = set.Std, set.Custom
+	return false
+}
+
 func (self *Rou) submatchStrict() []string {
 	args := self.submatchPattern()
-	if args == nil {
+	if args == nil || !self.matchFilter() {
 		return nil
 	}
 	if self.matchMethod() {
 		return args
 	}
-	panic(MethodNotAllowed(self.req()))
+	self.mismatch()
+	return nil
+}
+
+// Called when the pattern matches but the method doesn't. See `Rou.Lax`.
+func (self *Rou) mismatch() bool {
+	if self.MethodLax {
+		mut := self.mut()
+		mut.Mismatch = true
+		mut.Allow = self.allowOwn(mut.Allow)
+		return false
+	}
+	panic(tryErr{self.notAllowedOwn()})
+}
+
+/*
+Appends the methods matched by the router to the given list, skipping
+duplicates, in the format used by the `Allow` header. Includes HEAD when
+automatic HEAD fallback applies; see `Rou.StrictHead`.
+*/
+func (self *Rou) allowOwn(out []string) []string {
+	out = self.methodsOwn(out)
+	if !self.HeadStrict && self.hasMethod(http.MethodGet) {
+		out = appendNew(out, http.MethodHead)
+	}
+	return out
+}
+
+// Appends the methods declared by the router, skipping duplicates.
+func (self *Rou) methodsOwn(out []string) []string {
+	if self.MethodList != nil {
+		for _, val := range self.MethodList {
+			out = appendNew(out, val)
+		}
+	} else if self.Method != `` {
+		out = appendNew(out, self.Method)
+	}
+	return out
+}
+
+// Adds the request method and path to the given sentinel. See `Rou.Redact`.
+func (self *Rou) routeErr(val ErrRoute) ErrRoute {
+	val.Method, val.Path = self.req()
+	val.Redact = self.conf().ErrRedact
+	if self.conf().ErrStack {
+		val.Stack = stackTrace(3)
+	}
+	return val
+}
+
+// Same as `Rou.notAllowed`, listing only the methods of the current router.
+func (self *Rou) notAllowedOwn() ErrRoute {
+	return self.notAllowed(strings.Join(self.allowOwn(nil), `, `))
+}
+
+// Generates a 405 error listing the allowed methods. See `ErrRoute.Allow`.
+func (self *Rou) notAllowed(allow string) ErrRoute {
+	out := self.routeErr(ErrMethodNotAllowedBase)
+	out.Allow = allow
+	return out
+}
+
+/*
+Captures of the patterns of enclosing sub-routers, with the names of the
+capture groups. Never modified in place.
+*/
+type Captures struct {
+	Args  []string
+	Names []string
+}
+
+/*
+Rarely used settings of `Rou`, kept behind a pointer because `Rou` is copied
+on every builder call. Shared between copies of `Rou` and never modified in
+place: builder methods which change these settings make a modified copy, which
+allocates. See the corresponding builder methods for the meaning of each
+field; the zero value is equivalent to a nil `Rou.Conf`.
+*/
+type Conf struct {
+	HostPattern  string
+	Wrap         []func(http.Handler) http.Handler
+	Guards       []func(*http.Request) error
+	Catch        func(http.ResponseWriter, *http.Request, error)
+	ErrWrite     ErrWriter
+	Headers      [][2]string
+	EarlyHints   []string
+	Encode       Encoder
+	Respond      Responder
+	Compressors  []Compressor
+	EtagFunc     func(*http.Request) string
+	EndpointName string
+	EndpointDesc string
+	Sunset       time.Time
+	Parent       *Scope
+	EtagOn       bool
+	Recover      bool
+	ErrRedact    bool
+	ErrStack     bool
+	ErrSuggest   bool
+	Deprecation  bool
+}
+
+var confZero Conf
+
+// Returns the settings for reading. Must not be mutated.
+func (self *Rou) conf() *Conf {
+	if self.Conf != nil {
+		return self.Conf
+	}
+	return &confZero
+}
+
+// Replaces the settings with a copy, returning it for modification.
+func (self *Rou) setConf() *Conf {
+	out := new(Conf)
+	if self.Conf != nil {
+		*out = *self.Conf
+	}
+	self.Conf = out
+	return out
 }
 
 /*
 Mutable part of `Rou`, shared between all instances of `Rou` for a given
 request-response. Other fields of `Rou` are considered immutable. See `Rou`
 and its "builder" methods. After a successful route match, `.Done` is true
-and `.Endpoint` describes the matched route.
+and `.Endpoint` describes the matched route. In "lax method" mode,
+`.Mismatch` indicates that a route matched the pattern but not the method,
+and `.Allow` lists the methods of such routes; see `Rou.Lax`. Within
+`Rou.Methods`, `.Allow` temporarily lists the non-standard methods of routes
+rejected by their method.
 */
 type Mut struct {
 	Endpoint Endpoint
 	Done     bool
+	Mismatch bool
+	allowStd uint16
+	Allow    []string
 }
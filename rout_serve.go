@@ -0,0 +1,358 @@
+package rout
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+/*
+Serves the given handler, wrapping it in middleware added via `Rou.Use`, if
+any. Generic in order to avoid boxing the handler into an interface when
+there's no middleware. Note that escape analysis is static: a handler which is
+a closure or method value is moved to the heap by its creator regardless of
+the branch taken here, because it may reach middleware.
+*/
+func serve[A http.Handler](rou *Rou, val A) {
+	conf := rou.conf()
+	if conf.Recover {
+		defer recPanic()
+	}
+
+	rew := rou.Rew
+	if len(conf.Compressors) > 0 {
+		addVary(rew.Header(), `Accept-Encoding`)
+		comp := rou.compressor()
+		if comp != nil && rou.meth() != http.MethodHead {
+			out := &compressWriter{Rew: rew, Comp: comp}
+			defer out.Close()
+			rew = out
+		}
+	}
+	if rou.headFallback() {
+		rew = headWriter{rew}
+	}
+
+	if conf.EtagOn {
+		serveEtag(rou, rew, val)
+		return
+	}
+	serveWrapped(rou, rew, val)
+}
+
+/*
+Serves the handler via `etagHan`, which stores it as an interface. Must not be
+inlined: converting the handler moves it to the heap, and escape analysis
+doesn't distinguish branches, so the caller would allocate even when this
+branch isn't taken.
+*/
+//go:noinline
+func serveEtag[A http.Handler](rou *Rou, rew http.ResponseWriter, val A) {
+	serveWrapped(rou, rew, etagHan{val, rou.conf().EtagFunc})
+}
+
+func serveWrapped[A http.Handler](rou *Rou, rew http.ResponseWriter, val A) {
+	if len(rou.conf().Wrap) > 0 {
+		serveMiddleware(rou, rew, val)
+		return
+	}
+	val.ServeHTTP(rew, rou.Req)
+}
+
+/*
+Passes the handler through middleware, which requires converting it to
+`http.Handler`. Must not be inlined, for the same reason as `serveEtag`.
+*/
+//go:noinline
+func serveMiddleware[A http.Handler](rou *Rou, rew http.ResponseWriter, val A) {
+	rou.wrap(val).ServeHTTP(rew, rou.Req)
+}
+
+/*
+Response writer used for HEAD requests served by GET handlers. Discards the
+body, reporting it as written. See `Rou.StrictHead`.
+*/
+type headWriter struct{ http.ResponseWriter }
+
+func (self headWriter) Write(val []byte) (int, error) { return len(val), nil }
+
+// Allows `http.ResponseController` to reach the underlying writer.
+func (self headWriter) Unwrap() http.ResponseWriter { return self.ResponseWriter }
+
+/*
+Used by `Rou.Try`. Errors raised by this package via `try` are propagated
+as-is, and so is `http.ErrAbortHandler`, which is meant for the HTTP server.
+*/
+func recPanic() {
+	val := recover()
+	if val == nil {
+		return
+	}
+
+	_, ok := val.(tryErr)
+	if ok || val == http.ErrAbortHandler {
+		panic(val)
+	}
+	try(ErrPanic{val, debug.Stack()})
+}
+
+// The first middleware is the outermost.
+func (self *Rou) wrap(val http.Handler) http.Handler {
+	wrap := self.conf().Wrap
+	for ind := len(wrap) - 1; ind >= 0; ind-- {
+		if wrap[ind] != nil {
+			val = wrap[ind](val)
+		}
+	}
+	return val
+}
+
+/*
+The following types adapt the various handler funcs to `http.Handler`, using
+the request-response given to `ServeHTTP`, which may have been replaced by
+middleware. Used internally by terminal methods such as `Rou.Han`.
+*/
+
+type errFunc ErrFunc
+
+func (self errFunc) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	try(self(rew, req))
+}
+
+type paramFunc struct {
+	Fun  ParamFunc
+	Args []string
+}
+
+func (self paramFunc) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	self.Fun(rew, req, self.Args)
+}
+
+type paramErrFunc struct {
+	Fun  ParamErrFunc
+	Args []string
+}
+
+func (self paramErrFunc) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	try(self.Fun(rew, req, self.Args))
+}
+
+type paramMapFunc struct {
+	Fun  ParamMapFunc
+	Args map[string]string
+}
+
+func (self paramMapFunc) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	self.Fun(rew, req, self.Args)
+}
+
+type bindFunc[T any] struct {
+	Fun func(http.ResponseWriter, *http.Request, T)
+	Val T
+}
+
+func (self bindFunc[T]) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	self.Fun(rew, req, self.Val)
+}
+
+type han Han
+
+func (self han) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	serveHandler(rew, req, self(req))
+}
+
+type hanErr HanErr
+
+func (self hanErr) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	val, err := self(req)
+	try(err)
+	serveHandler(rew, req, val)
+}
+
+type paramHan struct {
+	Fun  ParamHan
+	Args []string
+}
+
+func (self paramHan) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	serveHandler(rew, req, self.Fun(req, self.Args))
+}
+
+type paramMapHan struct {
+	Fun  ParamMapHan
+	Args map[string]string
+}
+
+func (self paramMapHan) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	serveHandler(rew, req, self.Fun(req, self.Args))
+}
+
+type res struct {
+	Fun  Res
+	Resp Responder
+}
+
+func (self res) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	try(self.Resp.Respond(rew, self.Fun(req)))
+}
+
+type resAny struct {
+	Funs CoalesceRes
+	Resp Responder
+}
+
+func (self resAny) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	try(self.Resp.Respond(rew, self.Funs.Res(req)))
+}
+
+type reply struct {
+	Fun    Reply
+	Encode Encoder
+}
+
+func (self reply) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	status, val := self.Fun(req)
+
+	if val == nil {
+		if status != 0 {
+			rew.WriteHeader(status)
+		}
+		return
+	}
+
+	encode := self.Encode
+	if encode == nil {
+		encode = EncodeJSON
+	}
+	try(encode(rew, req, status, val))
+}
+
+type stream Stream
+
+func (self stream) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	head, status, body := self(req)
+
+	tar := rew.Header()
+	for key, vals := range head {
+		tar[key] = vals
+	}
+	if status != 0 {
+		rew.WriteHeader(status)
+	}
+
+	if body != nil {
+		try(body(streamWriter{rew}))
+	}
+}
+
+// Writer passed to the body function of `Stream`. See `Rou.Stream`.
+type streamWriter struct{ Rew http.ResponseWriter }
+
+func (self streamWriter) Write(val []byte) (int, error) { return self.Rew.Write(val) }
+
+func (self streamWriter) Flush() { flush(self.Rew) }
+
+type resErr struct {
+	Fun  ResErr
+	Resp Responder
+}
+
+func (self resErr) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	val, err := self.Fun(req)
+	if err != nil {
+		resClose(val)
+		try(err)
+	}
+	try(self.Resp.Respond(rew, val))
+}
+
+type paramRes struct {
+	Fun  ParamRes
+	Args []string
+	Resp Responder
+}
+
+func (self paramRes) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	try(self.Resp.Respond(rew, self.Fun(req, self.Args)))
+}
+
+type redirect struct {
+	Status int
+	Target string
+}
+
+func (self redirect) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	http.Redirect(rew, req, self.Target, self.Status)
+}
+
+func serveHandler(rew http.ResponseWriter, req *http.Request, val http.Handler) {
+	if val != nil {
+		val.ServeHTTP(rew, req)
+	}
+}
+
+/*
+Writer used by `Responder` for periodic flushing. Flushes by interval via a
+timer, which may fire concurrently with writes, hence the lock. Must be
+stopped before the handler returns, after which the response writer must not
+be used.
+*/
+type flushWriter struct {
+	Rew      http.ResponseWriter
+	Size     int
+	Interval time.Duration
+	Lock     sync.Mutex
+	Timer    *time.Timer
+	Pending  int
+	Stopped  bool
+}
+
+func (self *flushWriter) Write(val []byte) (int, error) {
+	self.Lock.Lock()
+	defer self.Lock.Unlock()
+
+	out, err := self.Rew.Write(val)
+	self.Pending += out
+	if err != nil {
+		return out, err
+	}
+
+	if self.Interval < 0 || (self.Size > 0 && self.Pending >= self.Size) {
+		self.flush()
+	} else if self.Interval > 0 && self.Timer == nil {
+		self.Timer = time.AfterFunc(self.Interval, self.delayedFlush)
+	}
+	return out, nil
+}
+
+func (self *flushWriter) delayedFlush() {
+	self.Lock.Lock()
+	defer self.Lock.Unlock()
+
+	self.Timer = nil
+	if !self.Stopped && self.Pending > 0 {
+		self.flush()
+	}
+}
+
+// Must be called under lock.
+func (self *flushWriter) flush() {
+	flush(self.Rew)
+	self.Pending = 0
+	if self.Timer != nil {
+		self.Timer.Stop()
+		self.Timer = nil
+	}
+}
+
+func (self *flushWriter) Stop() {
+	self.Lock.Lock()
+	defer self.Lock.Unlock()
+
+	self.Stopped = true
+	if self.Timer != nil {
+		self.Timer.Stop()
+		self.Timer = nil
+	}
+}
@@ -0,0 +1,66 @@
+package rout
+
+import (
+	"errors"
+	"net/http"
+)
+
+/*
+Implements `http.Handler` by routing each request via `Rou.Route`, using the
+given routing function, and writing the resulting errors, if any. Allows to
+customize error handling without repeating the glue code in every project.
+Unlike `RouFunc`, which always uses the default error writer, this has the
+following optional hooks:
+
+	* `.OnErr` writes routing and handler errors. When nil, the writer set via
+	  `SetDefaultErrWriter` is used, falling back on `WriteErr`.
+
+	* `.OnPanic`, when non-nil, enables recovery from handler panics via
+	  `Rou.Try`, and writes the resulting `ErrPanic`. When nil, handler panics
+	  behave as usual.
+
+Example:
+
+	http.ListenAndServe(`:8080`, rout.Server{
+		Routes:  myRoutes,
+		OnErr:   writeErr,
+		OnPanic: writePanic,
+	})
+*/
+type Server struct {
+	Routes  func(Rou)
+	OnErr   func(http.ResponseWriter, *http.Request, error)
+	OnPanic func(http.ResponseWriter, *http.Request, ErrPanic)
+}
+
+// Implement `http.Handler`.
+func (self Server) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	rou := MakeRou(rew, req)
+	if self.OnPanic != nil {
+		rou = rou.Try()
+	}
+
+	err := rou.Route(self.Routes)
+	if err == nil {
+		return
+	}
+
+	if self.OnPanic != nil {
+		var val ErrPanic
+		if errors.As(err, &val) {
+			self.OnPanic(rew, req, val)
+			return
+		}
+	}
+
+	if self.OnErr != nil {
+		self.OnErr(rew, req, err)
+		return
+	}
+	wri := DefaultErrWriter()
+	if wri != nil {
+		wri(rew, req, err)
+		return
+	}
+	WriteErr(rew, err)
+}
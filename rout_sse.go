@@ -0,0 +1,127 @@
+package rout
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Single message of a server-sent event stream. See `EventStream`. Empty fields
+are omitted. Multiline data is split into multiple "data" lines, as required
+by the format.
+*/
+type Event struct {
+	Id    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// Appends the event in the "text/event-stream" format.
+func (self Event) Append(buf []byte) []byte {
+	if self.Id != `` {
+		buf = eventField(buf, `id`, self.Id)
+	}
+	if self.Event != `` {
+		buf = eventField(buf, `event`, self.Event)
+	}
+	if self.Retry > 0 {
+		buf = append(buf, `retry: `...)
+		buf = strconv.AppendInt(buf, self.Retry.Milliseconds(), 10)
+		buf = append(buf, '\n')
+	}
+	for _, line := range strings.Split(self.Data, "\n") {
+		buf = eventField(buf, `data`, strings.TrimSuffix(line, "\r"))
+	}
+	return append(buf, '\n')
+}
+
+/*
+HTTP handler type for server-sent events. When serving HTTP, calls the
+function with a callback that writes and flushes one event. The response
+headers, including "Content-Type: text/event-stream", are written on the
+first event, or when the function returns without sending any. When the client
+disconnects, or any write fails, the context of the request passed to the
+function is canceled, and further events are discarded; long-running streams
+should stop when `req.Context().Done()` is closed. A non-nil error returned
+by the function is propagated via panic, just like routing errors, and is
+normally returned by `Rou.Route`; it should be returned only before sending
+any events. Usable with `Rou.Handler`, or with `Rou.Han` by returning it from
+the handler function. Example:
+
+	rou.Exa(`/events`).Get().Handler(rout.EventStream(streamEvents))
+
+	func streamEvents(req *http.Request, send func(rout.Event)) error {
+		for {
+			select {
+			case <-req.Context().Done():
+				return nil
+			case val := <-updates:
+				send(rout.Event{Event: `update`, Data: val})
+			}
+		}
+	}
+*/
+type EventStream func(*http.Request, func(Event)) error
+
+// Implement `http.Handler`.
+func (self EventStream) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	if self == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	out := eventWriter{Rew: rew, Cancel: cancel}
+	try(self(req.WithContext(ctx), out.Send))
+	out.Start()
+}
+
+type eventWriter struct {
+	Rew     http.ResponseWriter
+	Cancel  func()
+	Buf     []byte
+	Started bool
+	Failed  bool
+}
+
+func (self *eventWriter) Start() {
+	if self.Started {
+		return
+	}
+	self.Started = true
+
+	head := self.Rew.Header()
+	head.Set(`Content-Type`, `text/event-stream`)
+	head.Set(`Cache-Control`, `no-cache`)
+	head.Set(`X-Accel-Buffering`, `no`)
+	self.Rew.WriteHeader(http.StatusOK)
+	flush(self.Rew)
+}
+
+func (self *eventWriter) Send(val Event) {
+	if self.Failed {
+		return
+	}
+	self.Start()
+
+	self.Buf = val.Append(self.Buf[:0])
+	_, err := self.Rew.Write(self.Buf)
+	if err != nil {
+		self.Failed = true
+		self.Cancel()
+		return
+	}
+	flush(self.Rew)
+}
+
+func eventField(buf []byte, key, val string) []byte {
+	buf = append(buf, key...)
+	buf = append(buf, `: `...)
+	buf = append(buf, val...)
+	return append(buf, '\n')
+}
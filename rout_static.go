@@ -0,0 +1,253 @@
+package rout
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Serves static files from the given filesystem under the given URL prefix. If
+the path matches the prefix at a segment boundary, strips the prefix and
+serves the corresponding file, using `http.ServeContent` when possible, which
+supports conditional and range requests. The prefix `/static` matches
+`/static` and `/static/one.css`, but not `/staticone.css`, which falls through
+to the following routes. For directories, serves "index.html" from that
+directory; directory listings are never generated. If the file doesn't exist,
+panics with `ErrRoute` with status 404, which matches `ErrNotFoundBase` via
+`errors.Is` and is normally returned by `Rou.Route`, unlike
+`http.FileServer` which writes its own response. Unless the router already
+has a method, it matches only GET and HEAD, responding to other methods with
+`ErrMethodNotAllowed`. Paths are cleaned before lookup and can't escape the
+filesystem root. Example:
+
+	rou.Static(`/static`, os.DirFS(`public`))
+	rou.Static(`/assets`, embeddedAssets)
+
+In "dry run" mode via `Visit`, this invokes a visitor for the endpoint
+represented by the prefix, using the filesystem as the handler.
+*/
+func (self Rou) Static(prefix string, fsys fs.FS) {
+	self = self.Sta(prefix)
+	if self.Method == `` && self.MethodList == nil {
+		self = self.GetHead()
+	}
+
+	if self.isDone() || self.vis(fsys) {
+		return
+	}
+
+	rest := strings.TrimPrefix(self.path(), prefix)
+	if !staticBoundary(prefix, rest) || !self.Match() {
+		return
+	}
+
+	file, info := staticOpen(fsys, rest)
+	if file == nil {
+		try(self.routeErr(ErrNotFoundBase))
+	}
+	defer file.Close()
+
+	self.done(fsys)
+	serve(&self, staticFile{file, info})
+}
+
+/*
+Shortcut for serving static files from a directory on disk. Same as
+`.Static(prefix, os.DirFS(dir))`. See `Rou.Static`.
+*/
+func (self Rou) Dir(prefix, dir string) {
+	self.Static(prefix, os.DirFS(dir))
+}
+
+/*
+True if the remainder of the path after the prefix starts at a segment
+boundary. A prefix with a trailing slash ends at a boundary by itself.
+*/
+func staticBoundary(prefix, rest string) bool {
+	return rest == `` || rest[0] == '/' || strings.HasSuffix(prefix, `/`)
+}
+
+func staticOpen(fsys fs.FS, name string) (fs.File, fs.FileInfo) {
+	if fsys == nil {
+		return nil, nil
+	}
+
+	name = strings.TrimPrefix(path.Clean(`/`+name), `/`)
+	if name == `` {
+		name = `.`
+	}
+
+	file, info := staticOpenFile(fsys, name)
+	if file == nil || !info.IsDir() {
+		return file, info
+	}
+
+	_ = file.Close()
+	file, info = staticOpenFile(fsys, path.Join(name, `index.html`))
+	if file != nil && info.IsDir() {
+		_ = file.Close()
+		return nil, nil
+	}
+	return file, info
+}
+
+func staticOpenFile(fsys fs.FS, name string) (fs.File, fs.FileInfo) {
+	if !fs.ValidPath(name) {
+		return nil, nil
+	}
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil
+	}
+	return file, info
+}
+
+type staticFile struct {
+	File fs.File
+	Info fs.FileInfo
+}
+
+func (self staticFile) ServeHTTP(rew http.ResponseWriter, req *http.Request) {
+	name := self.Info.Name()
+
+	val, ok := self.File.(io.ReadSeeker)
+	if ok {
+		http.ServeContent(rew, req, name, self.Info.ModTime(), val)
+		return
+	}
+
+	typ := mime.TypeByExtension(path.Ext(name))
+	if typ != `` {
+		rew.Header().Set(`Content-Type`, typ)
+	}
+	if req.Method != http.MethodHead {
+		_, _ = io.Copy(rew, self.File)
+	}
+}
+
+/*
+Makes a response which serves the file at the given path on disk, for use with
+`Rou.Res` and similar methods. See `ResFS` for the rules. The body is
+`*os.File`, which allows `Respond` to use "sendfile". Example:
+
+	rou.Exa(`/robots.txt`).Get().Res(func(*http.Request) *http.Response {
+		return rout.ResFile(`public/robots.txt`)
+	})
+*/
+func ResFile(path string) *http.Response {
+	file, err := os.Open(path)
+	if err != nil {
+		return resFile(nil, err)
+	}
+	return resFile(file, nil)
+}
+
+/*
+Makes a response which serves the file with the given name from the given
+filesystem, for use with `Rou.Res` and similar methods. The response has
+"Content-Type" detected from the file extension, or from the content if the
+file is seekable, "Content-Length", and validators based on the modification
+time: "Last-Modified" and a weak "ETag". The body is the open file, which is
+closed by `Respond`. Combine with `ResRange` to serve byte ranges, and with
+`Rou.ETag` to respond with 304 when the client's copy is fresh. If the file
+doesn't exist or is a directory, panics with `ErrRoute` with status 404, which
+matches `ErrNotFoundBase`; other errors are propagated via panic as-is. In
+both cases, the error is normally returned by `Rou.Route`. For serving entire
+directories, see `Rou.Static`.
+*/
+func ResFS(fsys fs.FS, name string) *http.Response {
+	if fsys == nil || !fs.ValidPath(name) {
+		return resFile(nil, fs.ErrNotExist)
+	}
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return resFile(nil, err)
+	}
+	return resFile(file, nil)
+}
+
+var errNoFile = ErrRoute{Status: http.StatusNotFound, Msg: `no such file`, Redact: true}
+
+func resFile(file fs.File, err error) *http.Response {
+	if errors.Is(err, fs.ErrNotExist) {
+		try(errNoFile)
+	}
+	try(err)
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		try(err)
+	}
+	if info.IsDir() {
+		_ = file.Close()
+		try(errNoFile)
+	}
+
+	head := http.Header{}
+	typ, err := fileType(file, info.Name())
+	if err != nil {
+		_ = file.Close()
+		try(err)
+	}
+	if typ != `` {
+		head.Set(`Content-Type`, typ)
+	}
+
+	mod := info.ModTime()
+	if !isZeroTime(mod) {
+		head.Set(`Last-Modified`, mod.UTC().Format(http.TimeFormat))
+		head.Set(`Etag`, `W/"`+
+			strconv.FormatInt(mod.UnixNano(), 16)+`-`+
+			strconv.FormatInt(info.Size(), 16)+`"`)
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        head,
+		ContentLength: info.Size(),
+		Body:          file,
+	}
+}
+
+/*
+Detects the content type from the extension, falling back on sniffing the
+content when the file is seekable, like `http.ServeContent`.
+*/
+func fileType(file fs.File, name string) (string, error) {
+	typ := mime.TypeByExtension(path.Ext(name))
+	if typ != `` {
+		return typ, nil
+	}
+
+	val, ok := file.(io.ReadSeeker)
+	if !ok {
+		return ``, nil
+	}
+
+	var buf [512]byte
+	size, _ := io.ReadFull(val, buf[:])
+	_, err := val.Seek(0, io.SeekStart)
+	return http.DetectContentType(buf[:size]), err
+}
+
+// Same condition as in `http.ServeContent`.
+func isZeroTime(val time.Time) bool {
+	return val.IsZero() || val.Equal(time.Unix(0, 0))
+}
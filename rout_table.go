@@ -0,0 +1,84 @@
+package rout
+
+import "time"
+
+/*
+Tool for introspection. Returns all endpoints visited by the given routing
+function, in order, via `Visit`, along with their introspection data. See
+`EndpointInfo`.
+*/
+func Endpoints(fun func(Rou)) []EndpointInfo {
+	var out []EndpointInfo
+	Visit(fun, InfoVisitorFunc(func(val EndpointInfo) { out = append(out, val) }))
+	return out
+}
+
+/*
+Machine-readable route table, for consumption by deploy tooling, gateways, and
+documentation. Implements `InfoVisitor` by appending entries; should be
+populated via `Visit`, or via the shortcut `CollectRouteTable`. Encodes as a
+JSON array via "encoding/json", and as a YAML sequence via common YAML
+libraries, which support the "yaml" struct tags. Example:
+
+	out, err := json.MarshalIndent(rout.CollectRouteTable(myRoutes), ``, `  `)
+*/
+type RouteTable []RouteEntry
+
+/*
+Single entry in a `RouteTable`, converted from `Endpoint`. `.Style` is the
+string form of the pattern type, such as "pat" or "reg"; see `Match.String`.
+`.Handler` is the name of the handler function or type, such as
+"main.apiArticleGet". Empty fields are omitted when encoding.
+*/
+type RouteEntry struct {
+	Pattern    string     `json:"pattern"              yaml:"pattern"`
+	Style      string     `json:"style"                yaml:"style"`
+	Method     string     `json:"method,omitempty"     yaml:"method,omitempty"`
+	Handler    string     `json:"handler,omitempty"    yaml:"handler,omitempty"`
+	Name       string     `json:"name,omitempty"       yaml:"name,omitempty"`
+	Desc       string     `json:"desc,omitempty"       yaml:"desc,omitempty"`
+	Deprecated bool       `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Sunset     *time.Time `json:"sunset,omitempty"     yaml:"sunset,omitempty"`
+}
+
+// Shortcut for making `RouteTable` and populating it via `Visit`.
+func CollectRouteTable(fun func(Rou)) RouteTable {
+	var out RouteTable
+	Visit(fun, &out)
+	return out
+}
+
+// Implement `Visitor` by appending an entry without introspection data.
+func (self *RouteTable) Endpoint(val Endpoint) {
+	self.EndpointInfo(EndpointInfo{Endpoint: val})
+}
+
+// Implement `InfoVisitor` by appending an entry.
+func (self *RouteTable) EndpointInfo(val EndpointInfo) {
+	*self = append(*self, MakeRouteEntry(val))
+}
+
+/*
+Converts the endpoint to a route table entry. The handler name is taken from
+`EndpointInfo.HandlerName`, which is set by `Visit`. If empty, the name is
+resolved via `IdentName`, which should be done while the handler is still
+reachable.
+*/
+func MakeRouteEntry(val EndpointInfo) RouteEntry {
+	out := RouteEntry{
+		Pattern:    val.Pattern,
+		Style:      val.Match.String(),
+		Method:     val.Method,
+		Handler:    val.HandlerName,
+		Name:       val.Name,
+		Desc:       val.Desc,
+		Deprecated: val.Deprecated,
+	}
+	if out.Handler == `` {
+		out.Handler = IdentName(val.Handler)
+	}
+	if !val.Sunset.IsZero() {
+		out.Sunset = &val.Sunset
+	}
+	return out
+}
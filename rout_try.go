@@ -0,0 +1,162 @@
+package rout
+
+import "net/http"
+
+/*
+The "Try" methods are an alternative routing API which doesn't use panics for
+control flow. Instead of `Rou.Route` and `Rou.Sub`, routing funcs invoke "Try"
+terminal methods and return their results explicitly:
+
+	func routes(rou rout.Rou) (bool, error) {
+		if ok, err := rou.Exa(`/`).Get().TryFunc(pageIndex); ok || err != nil {
+			return ok, err
+		}
+		if ok, err := rou.Pat(`/articles/{}`).Get().TryParamFunc(pageArticle); ok || err != nil {
+			return ok, err
+		}
+		return false, nil
+	}
+
+	ok, err := routes(rout.MakeRou(rew, req))
+	if err == nil && !ok {
+		err = rout.NotFound(req.Method, req.URL.Path)
+	}
+	rout.WriteErr(rew, err)
+
+These methods never panic on their own, but don't recover from panics in
+handlers and middleware, other than those converted to `ErrPanic` by
+`Rou.Try`. Nested routing via `Rou.Sub` and similar methods still uses panics
+and should be avoided in this mode.
+*/
+
+/*
+Non-panicking version of `Rou.Match`. Returns true if the router matches the
+request. If the pattern and filter match but the method doesn't, returns
+`ErrRoute` with status 405 instead of panicking. If the router wasn't
+initialized, returns `ErrInit`.
+*/
+func (self *Rou) TryMatch() (bool, error) {
+	if !self.isInit() {
+		return false, ErrInit
+	}
+	if self.OnlyMethod {
+		return self.matchOnlyMethod(), nil
+	}
+	if !self.matchPatternFilter() {
+		return false, nil
+	}
+	if self.matchMethod() {
+		return true, nil
+	}
+	return false, self.notAllowedOwn()
+}
+
+/*
+Non-panicking version of `Rou.Submatch`. Returns captures if the router
+matches the request, or nil otherwise. If the pattern and filter match but the
+method doesn't, returns `ErrRoute` with status 405 instead of panicking.
+*/
+func (self *Rou) TrySubmatch() ([]string, error) {
+	if !self.isInit() {
+		return nil, ErrInit
+	}
+	if self.OnlyMethod {
+		return self.submatchOnlyMethod(), nil
+	}
+
+	args := self.submatchPattern()
+	if args == nil || !self.matchFilter() {
+		return nil, nil
+	}
+	if self.matchMethod() {
+		return args, nil
+	}
+	return nil, self.notAllowedOwn()
+}
+
+/*
+Non-panicking version of `Rou.Handler`. Returns true if the router matched the
+request and the request was handled, and a non-nil error if matching failed
+with `ErrMethodNotAllowed` or if a guard added via `Rou.Guard` rejected the
+request. If the request was already handled, returns false.
+*/
+func (self Rou) TryHandler(val http.Handler) (bool, error) {
+	ok, err := self.tryStart(val)
+	if !ok || err != nil {
+		return ok, err
+	}
+	if val != nil {
+		err = tryServe(&self, val)
+	}
+	return true, err
+}
+
+/*
+Non-panicking version of `Rou.ErrFunc`. Like `Rou.TryHandler`, but also
+returns the error returned by the given func, if any.
+*/
+func (self Rou) TryFunc(fun ErrFunc) (bool, error) {
+	ok, err := self.tryStart(fun)
+	if !ok || err != nil || fun == nil {
+		return ok, err
+	}
+	return true, tryServe(&self, errFunc(fun))
+}
+
+/*
+Non-panicking version of `Rou.ParamErrFunc`. Like `Rou.TryFunc`, but the func
+also receives captures from the pattern.
+*/
+func (self Rou) TryParamFunc(fun ParamErrFunc) (bool, error) {
+	if !self.isInit() {
+		return false, ErrInit
+	}
+	if self.isDone() || self.vis(fun) {
+		return false, nil
+	}
+
+	args, err := self.TrySubmatch()
+	if args == nil || err != nil {
+		return false, err
+	}
+
+	self.mark(fun)
+	err = self.admit()
+	if err != nil || fun == nil {
+		return true, err
+	}
+
+	return true, tryServe(&self, paramErrFunc{fun, args})
+}
+
+/*
+Shared by "Try" terminal methods for error-returning funcs. Serves the handler
+like other terminal methods, returning errors raised via `try`, such as errors
+returned by the handler, instead of panicking.
+*/
+func tryServe[A http.Handler](rou *Rou, val A) (err error) {
+	defer recTry(&err)
+	serve(rou, val)
+	return
+}
+
+/*
+Shared by "Try" terminal methods. Returns true if the router matched the
+request and the request should be handled.
+*/
+func (self *Rou) tryStart(val interface{}) (bool, error) {
+	if !self.isInit() {
+		return false, ErrInit
+	}
+	if self.isDone() || self.vis(val) {
+		return false, nil
+	}
+
+	ok, err := self.TryMatch()
+	if !ok || err != nil {
+		return false, err
+	}
+
+	self.mark(val)
+	return true, self.admit()
+}
@@ -0,0 +1,209 @@
+package rout
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+Generator of a TypeScript or JavaScript module with URL builders for routes
+named via `Rou.Name`, keeping frontend URLs in sync with the routing tree.
+Visits all routes of a routing function via `Visit`, and emits a function per
+named route, which takes the route's parameters in order and returns the URL
+path, escaping the parameters like `URLs.For`, as well as a `routes` object
+describing the method, pattern, and URL builder of each route. Parameters are
+named after the capture groups of the pattern, such as "{id}", falling back on
+"arg0", "arg1", and so on. Unnamed routes are skipped. When multiple routes
+have the same name, the first one wins. Route names must be valid JS
+identifiers. Regexp patterns are not supported and cause an error. Example
+output for `rou.Pat("/articles/{id}").Name("articleGet").Get()`:
+
+	export function articleGet(id: Param): string {
+		return `/articles/${enc(id)}`
+	}
+
+	export const routes = {
+		articleGet: {method: `GET`, pattern: `/articles/{id}`, path: articleGet},
+	} as const
+
+When `.JS` is true, the module is plain JavaScript without type annotations.
+*/
+type TS struct{ JS bool }
+
+/*
+Generates the source code of the module for the routes of the given routing
+function. See `TS`.
+*/
+func (self TS) Source(fun func(Rou)) ([]byte, error) {
+	var vals []EndpointInfo
+	seen := map[string]bool{}
+
+	Visit(fun, InfoVisitorFunc(func(val EndpointInfo) {
+		name := val.Name
+		if name != `` && !seen[name] {
+			seen[name] = true
+			vals = append(vals, val)
+		}
+	}))
+
+	var buf strings.Builder
+	buf.WriteString("// Code generated by rout.TS. DO NOT EDIT.\n\n")
+	if !self.JS {
+		buf.WriteString("export type Param = string | number\n\n")
+	}
+
+	for _, val := range vals {
+		err := self.route(&buf, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteString("export const routes = {\n")
+	for _, val := range vals {
+		name := val.Name
+		buf.WriteString("\t" + name + `: {method: ` + jsString(val.Method) +
+			`, pattern: ` + jsString(val.Pattern) + `, path: ` + name + "},\n")
+	}
+	if self.JS {
+		buf.WriteString("}\n\n")
+	} else {
+		buf.WriteString("} as const\n\n")
+	}
+
+	buf.WriteString(`function enc(val` + self.typ(`: Param`) + `)` + self.typ(`: string`) +
+		" {return encodeURIComponent(String(val))}\n\n")
+	buf.WriteString(`function encRest(val` + self.typ(`: Param`) + `)` + self.typ(`: string`) +
+		" {return String(val).split(`/`).map(encodeURIComponent).join(`/`)}\n")
+
+	return []byte(buf.String()), nil
+}
+
+/*
+Generates the source code via `TS.Source` and writes it to the file at the
+given path, replacing the previous content.
+*/
+func (self TS) WriteFile(path string, fun func(Rou)) error {
+	src, err := self.Source(fun)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, src, 0o644)
+}
+
+func (self TS) route(buf *strings.Builder, val EndpointInfo) error {
+	if !isJsIdent(val.Name) {
+		return fmt.Errorf(`[rout] route name %q is not a valid JS identifier`, val.Name)
+	}
+
+	pat, rest, ok := endpointPat(val.Endpoint)
+	if !ok {
+		return fmt.Errorf(
+			`[rout] unable to generate URL builder for route %q: unsupported match %q`,
+			val.Name, val.Match,
+		)
+	}
+
+	params := jsParams(val.Match.Names(val.Pattern), pat.Num()+boolInt(rest))
+
+	buf.WriteString(`/** ` + strings.TrimSpace(val.Method+` `+val.Pattern))
+	if val.Desc != `` {
+		buf.WriteString(`. ` + strings.ReplaceAll(val.Desc, `*/`, `* /`))
+	}
+	buf.WriteString(" */\n")
+
+	buf.WriteString(`export function ` + val.Name + `(`)
+	for ind, param := range params {
+		if ind > 0 {
+			buf.WriteString(`, `)
+		}
+		buf.WriteString(param + self.typ(`: Param`))
+	}
+	buf.WriteString(`)` + self.typ(`: string`) + " {\n\treturn `")
+
+	for _, seg := range pat {
+		if seg != `` {
+			buf.WriteString(jsTemplateEscape(seg))
+			continue
+		}
+		buf.WriteString(`${enc(` + params[0] + `)}`)
+		params = params[1:]
+	}
+	if rest {
+		buf.WriteString(`${encRest(` + params[0] + `)}`)
+	}
+
+	buf.WriteString("`\n}\n\n")
+	return nil
+}
+
+func (self TS) typ(val string) string {
+	if self.JS {
+		return ``
+	}
+	return val
+}
+
+/*
+Names the parameters after the capture groups, falling back on positional
+names for unnamed, invalid, or duplicate names.
+*/
+func jsParams(names []string, num int) []string {
+	out := make([]string, num)
+	seen := map[string]bool{}
+
+	for ind := range out {
+		var name string
+		if ind < len(names) {
+			name = names[ind]
+		}
+		if !isJsIdent(name) || seen[name] || strings.HasPrefix(name, `arg`) {
+			name = `arg` + strconv.Itoa(ind)
+		}
+		seen[name] = true
+		out[ind] = name
+	}
+	return out
+}
+
+var jsIdentReg = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func isJsIdent(val string) bool {
+	return jsIdentReg.MatchString(val) && !jsReserved[val]
+}
+
+var jsReserved = map[string]bool{
+	`await`: true, `break`: true, `case`: true, `catch`: true, `class`: true,
+	`const`: true, `continue`: true, `debugger`: true, `default`: true,
+	`delete`: true, `do`: true, `else`: true, `enum`: true, `export`: true,
+	`extends`: true, `false`: true, `finally`: true, `for`: true,
+	`function`: true, `if`: true, `implements`: true, `import`: true,
+	`in`: true, `instanceof`: true, `interface`: true, `let`: true, `new`: true,
+	`null`: true, `package`: true, `private`: true, `protected`: true,
+	`public`: true, `return`: true, `static`: true, `super`: true,
+	`switch`: true, `this`: true, `throw`: true, `true`: true, `try`: true,
+	`typeof`: true, `var`: true, `void`: true, `while`: true, `with`: true,
+	`yield`: true,
+
+	// Declared by the generated module.
+	`enc`: true, `encRest`: true, `routes`: true, `Param`: true,
+}
+
+// JS template literal, which is also valid as a plain string.
+func jsString(val string) string { return "`" + jsTemplateEscape(val) + "`" }
+
+func jsTemplateEscape(val string) string {
+	return jsTemplateReplacer.Replace(val)
+}
+
+var jsTemplateReplacer = strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${")
+
+func boolInt(val bool) int {
+	if val {
+		return 1
+	}
+	return 0
+}
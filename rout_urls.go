@@ -0,0 +1,134 @@
+package rout
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+/*
+Tool for reverse routing: building URL paths from route names set via
+`Rou.Name`. Implements `InfoVisitor` by collecting named endpoints; should be
+populated once via `Visit`, and can be reused concurrently afterwards.
+Example:
+
+	var urls = rout.CollectURLs(myRoutes)
+
+	func myRoutes(rou rout.Rou) {
+		rou.Pat(`/articles/{id}`).Name(`articleGet`).Get().Han(apiArticleGet)
+	}
+
+	path, err := urls.For(`articleGet`, 123) // "/articles/123"
+
+Supports exact, prefix, OAS-style, mux-style, colon-style, and parametrized
+prefix patterns. Regexp patterns are not supported. When multiple endpoints
+have the same name, the first one wins.
+*/
+type URLs map[string]Endpoint
+
+// Shortcut for making `URLs` and populating it via `Visit`.
+func CollectURLs(fun func(Rou)) URLs {
+	out := URLs{}
+	Visit(fun, out)
+	return out
+}
+
+// Implement `Visitor`. Does nothing, since plain endpoints have no names.
+func (self URLs) Endpoint(Endpoint) {}
+
+// Implement `InfoVisitor` by collecting named endpoints.
+func (self URLs) EndpointInfo(val EndpointInfo) {
+	if val.Name == `` {
+		return
+	}
+	_, ok := self[val.Name]
+	if !ok {
+		self[val.Name] = val.Endpoint
+	}
+}
+
+/*
+Builds the URL path for the endpoint with the given name, substituting capture
+groups with the given args in order. Args are formatted via `fmt.Sprint` and
+escaped. Returns an error if the name is unknown, if the amount of args doesn't
+match the amount of capture groups, or if the pattern type is not supported.
+*/
+func (self URLs) For(name string, args ...interface{}) (string, error) {
+	val, ok := self[name]
+	if !ok {
+		return ``, fmt.Errorf(`[rout] unknown route name %q`, name)
+	}
+
+	pat, rest, ok := endpointPat(val)
+	if !ok {
+		return ``, fmt.Errorf(
+			`[rout] unable to build URL for route %q: unsupported match %q`, name, val.Match,
+		)
+	}
+	return urlsFill(name, val, pat, rest, args)
+}
+
+/*
+Converts the endpoint's pattern to `Pat` for building URLs. "rest" is true if
+the pattern has a trailing capture which matches the rest of the path. Returns
+false for unsupported pattern types.
+*/
+func endpointPat(val Endpoint) (pat Pat, rest bool, ok bool) {
+	switch val.Match {
+	case MatchExa, MatchSta:
+		if val.Pattern == `` {
+			return nil, false, true
+		}
+		return Pat{val.Pattern}, false, true
+	case MatchParamSta:
+		if val.Pattern == `` {
+			return nil, true, true
+		}
+		return Pat{val.Pattern}, true, true
+	case MatchPat:
+		return cachedPat(val.Pattern), false, true
+	case MatchMux:
+		pat := cachedMux(val.Pattern)
+		return pat.Pat, pat.Rest, true
+	case MatchCol:
+		pat := cachedCol(val.Pattern)
+		return pat.Pat, pat.Rest, true
+	default:
+		return nil, false, false
+	}
+}
+
+// Same as `URLs.For`, but panics on error. Convenient for templates.
+func (self URLs) MustFor(name string, args ...interface{}) string {
+	out, err := self.For(name, args...)
+	try(err)
+	return out
+}
+
+func urlsFill(name string, val Endpoint, pat Pat, rest bool, args []interface{}) (string, error) {
+	num := pat.Num()
+	if rest {
+		num++
+	}
+	if num != len(args) {
+		return ``, fmt.Errorf(
+			`[rout] unable to build URL for route %q with pattern %q: expected %v args, got %v`,
+			name, val.Pattern, num, len(args),
+		)
+	}
+
+	var buf strings.Builder
+	for _, seg := range pat {
+		if seg != `` {
+			buf.WriteString(seg)
+			continue
+		}
+		buf.WriteString(url.PathEscape(fmt.Sprint(args[0])))
+		args = args[1:]
+	}
+
+	if rest {
+		buf.WriteString((&url.URL{Path: fmt.Sprint(args[0])}).EscapedPath())
+	}
+	return buf.String(), nil
+}
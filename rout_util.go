@@ -1,10 +1,20 @@
 package rout
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
 	r "reflect"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	u "unsafe"
@@ -16,6 +26,12 @@ const (
 	subsCap         = 8
 )
 
+// Capture names of `MatchParamSta`. Must not be mutated.
+var paramStaNames = []string{``}
+
+// Shared by all routers using `Rou.GetHead`. Must not be mutated.
+var getHead = []string{http.MethodGet, http.MethodHead}
+
 var regexpCache sync.Map
 
 // Susceptible to "thundering herd" but probably good enough.
@@ -45,23 +61,514 @@ func cachedPat(pattern string) Pat {
 	return pat
 }
 
+var muxCache sync.Map
+
+// Susceptible to "thundering herd" but probably good enough.
+func cachedMux(pattern string) MuxPat {
+	val, ok := muxCache.Load(pattern)
+	if ok {
+		return val.(MuxPat)
+	}
+
+	var pat MuxPat
+	try(pat.Parse(pattern))
+	muxCache.Store(pattern, pat)
+	return pat
+}
+
+var colCache sync.Map
+
+// Susceptible to "thundering herd" but probably good enough.
+func cachedCol(pattern string) MuxPat {
+	val, ok := colCache.Load(pattern)
+	if ok {
+		return val.(MuxPat)
+	}
+
+	var pat MuxPat
+	try(pat.ParseColon(pattern))
+	colCache.Store(pattern, pat)
+	return pat
+}
+
+var hostCache sync.Map
+
+// Susceptible to "thundering herd" but probably good enough.
+func cachedHost(pattern string) HostPat {
+	val, ok := hostCache.Load(pattern)
+	if ok {
+		return val.(HostPat)
+	}
+
+	var pat HostPat
+	try(pat.Parse(pattern))
+	hostCache.Store(pattern, pat)
+	return pat
+}
+
+var netCache sync.Map
+
+// Susceptible to "thundering herd" but probably good enough.
+func cachedNet(src string) *net.IPNet {
+	val, ok := netCache.Load(src)
+	if ok {
+		return val.(*net.IPNet)
+	}
+
+	out := parseNet(src)
+	netCache.Store(src, out)
+	return out
+}
+
+func parseNet(src string) *net.IPNet {
+	if strings.IndexByte(src, '/') >= 0 {
+		_, out, err := net.ParseCIDR(src)
+		if err != nil {
+			panic(fmt.Errorf(`[rout] invalid network %q: %w`, src, err))
+		}
+		return out
+	}
+
+	ip := net.ParseIP(src)
+	if ip == nil {
+		panic(fmt.Errorf(`[rout] invalid network %q: not an IP address`, src))
+	}
+
+	bits := 8 * len(ip)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+type namesKey struct {
+	Match   Match
+	Pattern string
+}
+
+var namesCache sync.Map
+
+// Susceptible to "thundering herd" but probably good enough.
+func cachedNames(match Match, pattern string) []string {
+	key := namesKey{match, pattern}
+	val, ok := namesCache.Load(key)
+	if ok {
+		return val.([]string)
+	}
+
+	out := patternNames(match, pattern)
+	namesCache.Store(key, out)
+	return out
+}
+
+func patternNames(match Match, pattern string) []string {
+	switch match {
+	case MatchReg:
+		return cachedRegexp(pattern).SubexpNames()[1:]
+	case MatchPat:
+		cachedPat(pattern)
+		return braceNames(pattern)
+	case MatchMux:
+		cachedMux(pattern)
+		return braceNames(pattern)
+	case MatchCol:
+		cachedCol(pattern)
+		return colonNames(pattern)
+	default:
+		return nil
+	}
+}
+
+/*
+Collects names of template expressions such as "{id}" in `Pat`, `MuxPat`, and
+`HostPat`. Assumes the pattern is valid. Skips the special "{$}", and drops the
+"..." suffix.
+*/
+func braceNames(src string) (out []string) {
+	for {
+		start := strings.IndexByte(src, '{')
+		if start < 0 {
+			return
+		}
+		end := strings.IndexByte(src[start:], '}')
+		if end < 0 {
+			return
+		}
+
+		name := src[start+1 : start+end]
+		src = src[start+end+1:]
+
+		if name != `$` {
+			out = append(out, strings.TrimSuffix(name, `...`))
+		}
+	}
+}
+
+// Collects names of parameters such as ":id" and "*path". Assumes the pattern
+// is valid.
+func colonNames(src string) (out []string) {
+	for {
+		start := strings.IndexAny(src, `:*`)
+		if start < 0 {
+			return
+		}
+		src = src[start+1:]
+
+		end := strings.IndexByte(src, '/')
+		if end < 0 {
+			end = len(src)
+		}
+		out = append(out, src[:end])
+		src = src[end:]
+	}
+}
+
+func paramMap(names, args []string) map[string]string {
+	out := make(map[string]string, len(names))
+	for ind, name := range names {
+		if name != `` && ind < len(args) {
+			out[name] = args[ind]
+		}
+	}
+	return out
+}
+
+// Used by `Rou.Guard` for errors without an HTTP status.
+type guardErr struct{ error }
+
+func (guardErr) HttpStatusCode() int { return http.StatusForbidden }
+
+func (self guardErr) Unwrap() error { return self.error }
+
+/*
+Replaces references such as "{0}" or "{id}" with the corresponding captures,
+escaped as URL paths. Used by `Rou.Redirect`.
+*/
+func interpolate(src string, names, args []string) string {
+	if strings.IndexByte(src, '{') < 0 {
+		return src
+	}
+
+	var buf strings.Builder
+	for {
+		start := strings.IndexByte(src, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(src[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		buf.WriteString(src[:start])
+		key := src[start+1 : end]
+
+		ind, err := strconv.Atoi(key)
+		if err != nil || key == `` || key[0] == '-' || key[0] == '+' {
+			ind = bindIndex(names, key, false)
+		}
+
+		if key != `` && ind >= 0 && ind < len(args) {
+			buf.WriteString((&url.URL{Path: args[ind]}).EscapedPath())
+		} else {
+			buf.WriteString(src[start : end+1])
+		}
+		src = src[end+1:]
+	}
+
+	buf.WriteString(src)
+	return buf.String()
+}
+
+/*
+Returns the stack trace of the caller, skipping the given amount of frames, in
+the format "function\n\tfile:line\n" per frame. Frames from the "runtime"
+package are omitted, and the depth is limited.
+*/
+func stackTrace(skip int) string {
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(skip, pcs[:])])
+
+	var buf []byte
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, `runtime.`) {
+			buf = append(buf, frame.Function...)
+			buf = append(buf, "\n\t"...)
+			buf = append(buf, frame.File...)
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(frame.Line), 10)
+			buf = append(buf, '\n')
+		}
+		if !more {
+			break
+		}
+	}
+	return bytesString(buf)
+}
+
+// Route declared by a routing function, collected for `Rou.Suggest`.
+type suggestRoute struct {
+	Route   string
+	Pattern string
+}
+
+var suggestCache sync.Map
+
+/*
+Caches the routes declared by the given routing function, keyed by its
+`Ident`, which assumes that the function is long-lived, as usual for routing
+functions. Susceptible to "thundering herd" but probably good enough.
+*/
+func cachedSuggestRoutes(fun func(Rou)) []suggestRoute {
+	key := Ident(fun)
+	val, ok := suggestCache.Load(key)
+	if ok {
+		return val.([]suggestRoute)
+	}
+
+	out := collectSuggestRoutes(fun)
+	suggestCache.Store(key, out)
+	return out
+}
+
+/*
+Collects the routes declared by the given routing function via `Visit`,
+formatted as "METHOD pattern", skipping duplicates. The dry run uses a
+synthetic request, which may cause routing functions to panic. In this case,
+returns nil.
+*/
+func collectSuggestRoutes(fun func(Rou)) (out []suggestRoute) {
+	var ok bool
+	defer func() {
+		if recover() != nil || !ok {
+			out = nil
+		}
+	}()
+
+	Visit(fun, VisitorFunc(func(val Endpoint) {
+		route := val.Pattern
+		if val.Method != `` {
+			route = val.Method + ` ` + route
+		}
+		for _, prev := range out {
+			if prev.Route == route {
+				return
+			}
+		}
+		out = append(out, suggestRoute{route, val.Pattern})
+	}))
+	ok = true
+	return
+}
+
+/*
+Returns up to `limit` of the given routes with patterns closest to the given
+path, by edit distance, joined with commas.
+*/
+func suggestRoutes(routes []suggestRoute, path string, limit int) string {
+	type entry struct {
+		Route string
+		Dist  int
+	}
+
+	vals := make([]entry, len(routes))
+	for ind, val := range routes {
+		vals[ind] = entry{val.Route, editDist(path, val.Pattern)}
+	}
+
+	sort.SliceStable(vals, func(one, two int) bool { return vals[one].Dist < vals[two].Dist })
+	if len(vals) > limit {
+		vals = vals[:limit]
+	}
+
+	var buf []byte
+	for ind, val := range vals {
+		if ind > 0 {
+			buf = append(buf, `, `...)
+		}
+		buf = append(buf, val.Route...)
+	}
+	return bytesString(buf)
+}
+
+// Levenshtein distance between the bytes of the given strings.
+func editDist(one, two string) int {
+	prev := make([]int, len(two)+1)
+	next := make([]int, len(two)+1)
+	for ind := range prev {
+		prev[ind] = ind
+	}
+
+	for ind0 := 0; ind0 < len(one); ind0++ {
+		next[0] = ind0 + 1
+		for ind1 := 0; ind1 < len(two); ind1++ {
+			cost := 1
+			if one[ind0] == two[ind1] {
+				cost = 0
+			}
+			next[ind1+1] = minInt(minInt(prev[ind1+1]+1, next[ind1]+1), prev[ind1]+cost)
+		}
+		prev, next = next, prev
+	}
+	return prev[len(two)]
+}
+
+func minInt(one, two int) int {
+	if one < two {
+		return one
+	}
+	return two
+}
+
+func appendNew(out []string, val string) []string {
+	for _, prev := range out {
+		if prev == val {
+			return out
+		}
+	}
+	return append(out, val)
+}
+
+// Standard methods, in the order used by `allowSet`.
+var stdMethods = [...]string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+/*
+Methods collected by `Rou.Methods`. Standard methods are stored as a bitset of
+`stdMethods`, which avoids allocating on the common path where the block
+matches. Formats as the `Allow` header, with standard methods in the order of
+`stdMethods`, followed by other methods in the order of declaration.
+*/
+type allowSet struct {
+	Std    uint16
+	Custom []string
+}
+
+func (self *allowSet) add(val string) {
+	for ind, meth := range stdMethods {
+		if meth == val {
+			self.Std |= 1 << ind
+			return
+		}
+	}
+	self.Custom = appendNew(self.Custom, val)
+}
+
+// Supports only standard methods.
+func (self allowSet) has(val string) bool {
+	for ind, meth := range stdMethods {
+		if meth == val {
+			return self.Std&(1<<ind) != 0
+		}
+	}
+	return false
+}
+
+func (self allowSet) String() string {
+	var out []string
+	for ind, meth := range stdMethods {
+		if self.Std&(1<<ind) != 0 {
+			out = append(out, meth)
+		}
+	}
+	return strings.Join(append(out, self.Custom...), `, `)
+}
+
+// Appends without mutating the first slice. Returns the second slice as-is if
+// the first is empty.
+func concat(one, two []string) []string {
+	if len(one) == 0 {
+		return two
+	}
+	return append(one[:len(one):len(one)], two...)
+}
+
+func hanFirst(funs []Han) Han {
+	if len(funs) > 0 {
+		return funs[0]
+	}
+	return nil
+}
+
+func resFirst(funs []Res) Res {
+	if len(funs) > 0 {
+		return funs[0]
+	}
+	return nil
+}
+
 func try(err error) {
 	if err != nil {
-		panic(err)
+		panic(tryErr{err})
 	}
 }
 
-func rec(ptr *error) {
-	err := toErr(recover())
+/*
+Panic value used by `try`, which is how this package raises errors. Allows
+`Rou.Try` to distinguish such errors from arbitrary panics in user code.
+Unwrapped by `recErr`, and thus never exposed by `Rou.Route` or `Rou.OnErr`.
+*/
+type tryErr struct{ Err error }
+
+func (self tryErr) Error() string { return self.Err.Error() }
+func (self tryErr) Unwrap() error { return self.Err }
+
+/*
+The flag must be set after the deferring function returns normally. This allows
+to detect `panic(nil)` which, depending on the Go version declared in "go.mod"
+and the "panicnil" GODEBUG setting, may cause `recover` to return nil rather
+than `*runtime.PanicNilError`. This package never panics with nil; such panics
+come from user code and are reported as `ErrPanicNil`.
+*/
+func rec(ptr *error, ok *bool) {
+	err := recErr(recover(), *ok)
 	if err != nil {
 		*ptr = err
 	}
 }
 
+/*
+Used by "Try" terminal methods. Recovers only errors raised via `try`, letting
+other panics propagate.
+*/
+func recTry(ptr *error) {
+	val := recover()
+	if val == nil {
+		return
+	}
+	wrap, ok := val.(tryErr)
+	if !ok {
+		panic(val)
+	}
+	*ptr = wrap.Err
+}
+
+func recErr(val interface{}, ok bool) error {
+	if val == nil && !ok {
+		return ErrPanicNil
+	}
+	return toErr(val)
+}
+
 func toErr(val interface{}) error {
 	if val == nil {
 		return nil
 	}
+	wrap, ok := val.(tryErr)
+	if ok {
+		return wrap.Err
+	}
 	err, _ := val.(error)
 	if err != nil {
 		return err
@@ -86,17 +593,124 @@ func hasSlashSuffix(val string) bool {
 	return len(val) > 0 && val[len(val)-1] == '/'
 }
 
+// Removes one trailing slash, if any, unless the input is exactly `/`.
+func trimSlashSuffix(val string) string {
+	if len(val) > 1 && hasSlashSuffix(val) {
+		return val[:len(val)-1]
+	}
+	return val
+}
+
+/*
+Removes one trailing slash if present, otherwise appends one. The input `/` is
+returned as-is.
+*/
+func toggleSlashSuffix(val string) string {
+	if val == `/` {
+		return val
+	}
+	if hasSlashSuffix(val) {
+		return val[:len(val)-1]
+	}
+	return val + `/`
+}
+
+// Replaces each run of consecutive slashes with a single slash.
+func dedupSlash(val string) string {
+	if !strings.Contains(val, `//`) {
+		return val
+	}
+
+	buf := make([]byte, 0, len(val))
+	for ind := 0; ind < len(val); ind++ {
+		if val[ind] == '/' && ind > 0 && val[ind-1] == '/' {
+			continue
+		}
+		buf = append(buf, val[ind])
+	}
+	return bytesString(buf)
+}
+
+/*
+Supports both single-error unwrapping and multi-error unwrapping via
+`Unwrap() []error`, as in `errors.Join`, returning the first non-zero status
+found in depth-first order.
+*/
 func errStatusDeep(err error) int {
 	for err != nil {
-		impl, _ := err.(interface{ HttpStatusCode() int })
-		if impl != nil {
-			return impl.HttpStatusCode()
+		code, ok := errStatusOwn(err)
+		if ok {
+			return code
 		}
+
+		multi, _ := err.(interface{ Unwrap() []error })
+		if multi != nil {
+			return errStatusMulti(err, multi.Unwrap())
+		}
+
 		err = errUnwrap(err)
 	}
 	return 0
 }
 
+func errStatusOwn(err error) (int, bool) {
+	switch impl := err.(type) {
+	case interface{ HttpStatusCode() int }:
+		return impl.HttpStatusCode(), true
+	case interface{ StatusCode() int }:
+		return impl.StatusCode(), true
+	case interface{ HTTPStatus() int }:
+		return impl.HTTPStatus(), true
+	default:
+		return 0, false
+	}
+}
+
+func errStatusMulti(err error, vals []error) int {
+	for _, val := range vals {
+		if val == nil || r.DeepEqual(err, val) {
+			continue
+		}
+		code := errStatusDeep(val)
+		if code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
+type errStatusEntry struct {
+	Match  func(error) bool
+	Status int
+}
+
+var errStatusReg struct {
+	sync.RWMutex
+	Vals []errStatusEntry
+}
+
+func errStatusRegister(fun func(error) bool, status int) {
+	errStatusReg.Lock()
+	defer errStatusReg.Unlock()
+	errStatusReg.Vals = append(errStatusReg.Vals, errStatusEntry{fun, status})
+}
+
+func errStatusRegistered(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	errStatusReg.RLock()
+	defer errStatusReg.RUnlock()
+
+	for _, val := range errStatusReg.Vals {
+		if val.Match(err) {
+			return val.Status
+		}
+	}
+	return 0
+}
+
 /*
 Improved version of `errors.Unwrap` which returns nil if the error incorrectly
 unwraps to itself, to avoid an infinite loop.
@@ -132,6 +746,31 @@ func bytesString(val []byte) string {
 	return *(*string)(u.Pointer(&val))
 }
 
+/*
+Converts a pattern of the given style to an equivalent regexp. Returns false
+for unknown styles.
+*/
+func matchToReg(match Match, src string) (string, bool) {
+	switch match {
+	case MatchExa:
+		return exaToReg(src), true
+	case MatchSta:
+		return staToReg(src), true
+	case MatchReg:
+		return src, true
+	case MatchPat:
+		return patToReg(src), true
+	case MatchMux:
+		return muxToReg(src), true
+	case MatchCol:
+		return colToReg(src), true
+	case MatchParamSta:
+		return paramStaToReg(src), true
+	default:
+		return ``, false
+	}
+}
+
 // TODO consider caching.
 func exaToReg(src string) string {
 	return `^` + regexp.QuoteMeta(src) + `$`
@@ -142,11 +781,46 @@ func staToReg(src string) string {
 	return `^` + regexp.QuoteMeta(src)
 }
 
+func paramStaToReg(src string) string {
+	if src == `` || hasSlashSuffix(src) {
+		return `^` + regexp.QuoteMeta(src) + `(.*)$`
+	}
+	return `^` + regexp.QuoteMeta(src) + `(|/.*)$`
+}
+
 // TODO consider caching.
 func patToReg(src string) string {
 	return cachedPat(src).Reg()
 }
 
+// TODO consider caching.
+func muxToReg(src string) string {
+	return cachedMux(src).Reg()
+}
+
+// TODO consider caching.
+func colToReg(src string) string {
+	return cachedCol(src).Reg()
+}
+
+func muxToPat(src string) string { return muxPatToPat(src, cachedMux(src)) }
+
+func colToPat(src string) string { return muxPatToPat(src, cachedCol(src)) }
+
+/*
+Mux patterns without prefix or rest wildcards are equivalent to OAS patterns.
+Other mux patterns can't be converted.
+*/
+func muxPatToPat(src string, val MuxPat) string {
+	if val.Prefix || val.Rest {
+		panic(fmt.Errorf(
+			`[rout] pattern %q matches a path prefix and can't be converted to an OAS pattern`,
+			src,
+		))
+	}
+	return val.Pat.String()
+}
+
 /*
 AFAIK OAS patterns have no way to "escape" template expressions.
 Which means we can't convert it, but we can validate it.
@@ -227,6 +901,278 @@ func (self *subs) slice() []string {
 	return self.buf[:self.cur]
 }
 
+func reqPath(req *http.Request) string {
+	if req != nil && req.URL != nil {
+		return req.URL.Path
+	}
+	return ``
+}
+
+// Counts non-empty segments between slashes.
+func pathDepth(val string) (out int) {
+	hit := false
+	for ind := 0; ind < len(val); ind++ {
+		if val[ind] == '/' {
+			hit = false
+		} else if !hit {
+			hit = true
+			out++
+		}
+	}
+	return
+}
+
+func resClose(res *http.Response) {
+	if res != nil && res.Body != nil {
+		_ = res.Body.Close()
+	}
+}
+
+func reqHeader(req *http.Request) http.Header {
+	if req != nil {
+		return req.Header
+	}
+	return nil
+}
+
+/*
+Returns the q-value of the given media type according to the given `Accept`
+header values. See `Accepts` for the rules.
+*/
+func acceptQ(head []string, typ string) float64 {
+	if len(head) == 0 {
+		return 1
+	}
+
+	var out float64
+	spec := 0
+
+	for _, line := range head {
+		for line != `` {
+			var item string
+			item, line = strCut(line, ',')
+
+			rng, params := strCut(item, ';')
+			rng = strings.TrimSpace(rng)
+			if rng == `` {
+				continue
+			}
+
+			cur := mediaSpecificity(rng, typ)
+			if cur <= spec {
+				continue
+			}
+			spec = cur
+			out = paramQ(params)
+		}
+	}
+
+	if spec == 0 && headEmpty(head) {
+		return 1
+	}
+	return out
+}
+
+/*
+Returns 0 if the media range doesn't match the media type. Otherwise returns
+a positive number where higher means more specific.
+*/
+func mediaSpecificity(rng, typ string) int {
+	if rng == `*/*` || rng == `*` {
+		return 1
+	}
+	if strings.EqualFold(rng, typ) {
+		return 3
+	}
+
+	main, sub := strCut(rng, '/')
+	if sub == `*` {
+		typMain, _ := strCut(typ, '/')
+		if strings.EqualFold(main, typMain) {
+			return 2
+		}
+	}
+	return 0
+}
+
+// Finds the "q" parameter and parses it. Defaults to 1.
+func paramQ(src string) float64 {
+	for src != `` {
+		var param string
+		param, src = strCut(src, ';')
+
+		key, val := strCut(strings.TrimSpace(param), '=')
+		if !strings.EqualFold(strings.TrimSpace(key), `q`) {
+			continue
+		}
+
+		out, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil || out < 0 {
+			return 0
+		}
+		if out > 1 {
+			return 1
+		}
+		return out
+	}
+	return 1
+}
+
+// Language range from `Accept-Language` with its q-value. See `Lang`.
+type langRange struct {
+	Rng string
+	Q   float64
+}
+
+/*
+Appends the language ranges from the given `Accept-Language` header values to
+the given buffer, sorted by descending q-value. On ties, the earlier range
+comes first. Ranges with q=0, which exclude tags, come last.
+*/
+func langRanges(head []string, buf []langRange) []langRange {
+	for _, line := range head {
+		for line != `` {
+			var item string
+			item, line = strCut(line, ',')
+
+			rng, params := strCut(item, ';')
+			rng = strings.TrimSpace(rng)
+			if rng == `` {
+				continue
+			}
+
+			buf = append(buf, langRange{rng, paramQ(params)})
+			for ind := len(buf) - 1; ind > 0 && buf[ind-1].Q < buf[ind].Q; ind-- {
+				buf[ind-1], buf[ind] = buf[ind], buf[ind-1]
+			}
+		}
+	}
+	return buf
+}
+
+/*
+True if a range with q=0 excludes the given tag. Unlike `langMatch`, the range
+"en-US" excludes only "en-US" and its subtags, not "en". The wildcard "*" with
+q=0 excludes nothing, since it applies only to unlisted languages.
+*/
+func langExcluded(rngs []langRange, tag string) bool {
+	for _, val := range rngs {
+		if val.Q > 0 || val.Rng == `*` {
+			continue
+		}
+		if strings.EqualFold(val.Rng, tag) || langPrefix(tag, val.Rng) {
+			return true
+		}
+	}
+	return false
+}
+
+// Both inputs must be non-empty.
+func langMatch(rng, tag string) bool {
+	return strings.EqualFold(rng, tag) || langPrefix(tag, rng) || langPrefix(rng, tag)
+}
+
+// True if the tag begins with the prefix followed by "-", case-insensitively.
+func langPrefix(tag, prefix string) bool {
+	return len(tag) > len(prefix) &&
+		tag[len(prefix)] == '-' &&
+		strings.EqualFold(tag[:len(prefix)], prefix)
+}
+
+/*
+True if the comma-separated header values include the given token,
+case-insensitively. Token parameters such as "/13" in "websocket/13" are not
+stripped.
+*/
+func headToken(head []string, token string) bool {
+	for _, line := range head {
+		for line != `` {
+			var item string
+			item, line = strCut(line, ',')
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func headEmpty(head []string) bool {
+	for _, val := range head {
+		if strings.TrimSpace(val) != `` {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Returns the "proto" parameter of the first element of the `Forwarded` header
+(RFC 7239), if any.
+*/
+func forwardedProto(src string) string {
+	src, _ = strCut(src, ',')
+	for src != `` {
+		var pair string
+		pair, src = strCut(src, ';')
+
+		key, val := strCut(strings.TrimSpace(pair), '=')
+		if strings.EqualFold(key, `proto`) {
+			return strings.Trim(val, `"`)
+		}
+	}
+	return ``
+}
+
+func reqQuery(req *http.Request) string {
+	if req != nil && req.URL != nil {
+		return req.URL.RawQuery
+	}
+	return ``
+}
+
+/*
+Scans the raw URL query for values of the given key, returning true if the
+func returns true for any of them. Nil func matches any value. Unlike
+`url.ParseQuery`, this avoids allocating a map, and unescapes only when
+necessary.
+*/
+func queryAny(src, key string, fun func(string) bool) bool {
+	for src != `` {
+		var pair string
+		pair, src = strCut(src, '&')
+
+		name, val := strCut(pair, '=')
+		if queryUnescape(name) != key {
+			continue
+		}
+		if fun == nil || fun(queryUnescape(val)) {
+			return true
+		}
+	}
+	return false
+}
+
+func queryUnescape(val string) string {
+	if !strings.ContainsAny(val, `%+`) {
+		return val
+	}
+	out, err := url.QueryUnescape(val)
+	if err != nil {
+		return val
+	}
+	return out
+}
+
+// Similar to `strings.Cut` (Go 1.18) but for a single byte.
+func strCut(src string, sep byte) (string, string) {
+	ind := strings.IndexByte(src, sep)
+	if ind < 0 {
+		return src, ``
+	}
+	return src[:ind], src[ind+1:]
+}
+
 func strPop(ptr *string, cur int) (out string) {
 	out, *ptr = (*ptr)[:cur], (*ptr)[cur:]
 	return
@@ -249,6 +1195,14 @@ func matchPat(pat, inp string) bool {
 	return cachedPat(pat).Match(inp)
 }
 
+func matchMux(pat, inp string) bool {
+	return cachedMux(pat).Match(inp)
+}
+
+func matchCol(pat, inp string) bool {
+	return cachedCol(pat).Match(inp)
+}
+
 func submatchExa(pat, inp string) []string {
 	if matchExa(pat, inp) {
 		return []string{}
@@ -256,6 +1210,13 @@ func submatchExa(pat, inp string) []string {
 	return nil
 }
 
+func submatchParamSta(pat, inp string) []string {
+	if matchSta(pat, inp) {
+		return []string{inp[len(pat):]}
+	}
+	return nil
+}
+
 func submatchSta(pat, inp string) []string {
 	if matchSta(pat, inp) {
 		return []string{}
@@ -274,3 +1235,176 @@ func submatchReg(pat, inp string) []string {
 func submatchPat(pat, inp string) []string {
 	return cachedPat(pat).Submatch(inp)
 }
+
+func submatchMux(pat, inp string) []string {
+	return cachedMux(pat).Submatch(inp)
+}
+
+func submatchCol(pat, inp string) []string {
+	return cachedCol(pat).Submatch(inp)
+}
+
+/*
+Flushes the response writer, if it supports flushing, unwrapping writers which
+implement `Unwrap() http.ResponseWriter`, like `http.ResponseController`.
+*/
+func flush(rew http.ResponseWriter) {
+	for rew != nil {
+		val, ok := rew.(http.Flusher)
+		if ok {
+			val.Flush()
+			return
+		}
+
+		unwrap, ok := rew.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		rew = unwrap.Unwrap()
+	}
+}
+
+// See `Respond`.
+func resLength(res *http.Response) (int64, bool) {
+	out := res.ContentLength
+	if out > 0 {
+		return out, true
+	}
+	if out == 0 && (res.Body == nil || res.Body == http.NoBody) {
+		return 0, true
+	}
+	return bodyLength(res.Body)
+}
+
+/*
+Length of the remaining content of a body which is a regular file, such as
+`*os.File`, taking the current offset into account.
+*/
+func bodyLength(src io.Reader) (int64, bool) {
+	file, ok := src.(interface {
+		Stat() (fs.FileInfo, error)
+		io.Seeker
+	})
+	if !ok {
+		return 0, false
+	}
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, false
+	}
+
+	pos, err := file.Seek(0, io.SeekCurrent)
+	if err != nil || pos > info.Size() {
+		return 0, false
+	}
+	return info.Size() - pos, true
+}
+
+/*
+Copies the response body, wrapping failures into `ErrRespond`. Read errors are
+tracked by wrapping the body, except for files, which are passed as-is to
+allow "sendfile"; their read errors are recognized by type.
+*/
+func copyRes(tar io.Writer, src io.Reader, size int) (int64, error) {
+	var track *readTracker
+	if _, ok := src.(*os.File); !ok {
+		track = &readTracker{Src: src}
+		src = track
+	}
+
+	out, err := copyBody(tar, src, size)
+	if err == nil {
+		return out, nil
+	}
+
+	read := false
+	if track != nil {
+		read = track.Err != nil
+	} else {
+		var val *fs.PathError
+		read = errors.As(err, &val) && val.Op == `read`
+	}
+
+	return out, ErrRespond{
+		Err:     err,
+		Written: out,
+		Read:    read,
+		Abort:   !read && isAbortErr(err),
+	}
+}
+
+// Records the first non-EOF read error. See `copyRes`.
+type readTracker struct {
+	Src io.Reader
+	Err error
+}
+
+func (self *readTracker) Read(buf []byte) (int, error) {
+	out, err := self.Src.Read(buf)
+	if err != nil && err != io.EOF && self.Err == nil {
+		self.Err = err
+	}
+	return out, err
+}
+
+// True for errors which indicate that the client has disconnected.
+func isAbortErr(err error) bool {
+	return isAbortErrno(err) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, context.Canceled)
+}
+
+/*
+Prefers the writer's own `io.ReaderFrom`, which for `http.Server` uses
+"sendfile" when the body is a file. Unlike `io.Copy`, this doesn't try the
+reader's `io.WriterTo` first, which for `*os.File` may hide the file from the
+server. Otherwise copies via a pooled buffer of the given size.
+*/
+func copyBody(tar io.Writer, src io.Reader, size int) (int64, error) {
+	val, ok := tar.(io.ReaderFrom)
+	if ok {
+		return val.ReadFrom(src)
+	}
+
+	pool := bufPool(size)
+	buf := pool.Get().(*[]byte)
+	defer pool.Put(buf)
+	return io.CopyBuffer(tar, src, *buf)
+}
+
+const defaultBufSize = 32 * 1024
+
+// Pools of copy buffers by size. See `Responder.BufSize`.
+var bufPools sync.Map
+
+func bufPool(size int) *sync.Pool {
+	if size <= 0 {
+		size = defaultBufSize
+	}
+
+	val, ok := bufPools.Load(size)
+	if !ok {
+		val, _ = bufPools.LoadOrStore(size, &sync.Pool{New: func() interface{} {
+			buf := make([]byte, size)
+			return &buf
+		}})
+	}
+	return val.(*sync.Pool)
+}
+
+func isInfoStatus(val int) bool {
+	return val >= 100 && val <= 199 && val != http.StatusSwitchingProtocols
+}
+
+func isHttp10(req *http.Request) bool {
+	return req != nil && req.ProtoMajor == 1 && req.ProtoMinor == 0
+}
+
+func trimPkgPath(val string) string {
+	ind := strings.LastIndexByte(val, '/')
+	if ind >= 0 {
+		return val[ind+1:]
+	}
+	return val
+}
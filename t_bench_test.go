@@ -2,10 +2,12 @@ package rout
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	ht "net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -166,9 +168,17 @@ func Benchmark_bound_methods(b *testing.B) {
 }
 
 func benchBoundMethod() {
-	try(MakeRou(nil, staticReq).Route(staticState.Route))
+	try(MakeRou(NopRew{}, staticReq).Route(staticState.Route))
 }
 
+func Benchmark_bound_methods_middleware(b *testing.B) {
+	for range iter(b.N) {
+		try(MakeRou(NopRew{}, staticReq).Use(nopMiddleware).Route(staticState.Route))
+	}
+}
+
+func nopMiddleware(val hhan) hhan { return val }
+
 var staticState State
 
 type State struct{ _ map[string]string }
@@ -311,3 +321,14 @@ func BenchmarkErrStatus(b *testing.B) {
 		_ = ErrStatus(err)
 	}
 }
+
+func BenchmarkRespond(b *testing.B) {
+	rew := NopRew{}
+	body := strings.NewReader(`hello world`)
+	res := &http.Response{Body: io.NopCloser(body)}
+
+	for range iter(b.N) {
+		body.Reset(`hello world`)
+		try(Respond(rew, res))
+	}
+}
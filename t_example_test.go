@@ -1,7 +1,6 @@
 package rout_test
 
 import (
-	"io"
 	"net/http"
 
 	"github.com/mitranim/rout"
@@ -70,16 +69,11 @@ func routesApiArticles(rou rout.Rou) {
 
 // Oversimplified for example's sake.
 func allowCors(head http.Header)                  {}
-func pageIndex(req Req) Han                       { return Str(`ok`) }
-func pageArticles(req Req) Han                    { return Str(`ok`) }
-func pageArticle(req Req, args []string) Han      { return Str(`ok`) }
-func apiArticleFeed(req Req) Han                  { return Str(`ok`) }
-func apiArticleCreate(req Req) Han                { return Str(`ok`) }
-func apiArticleGet(req Req, args []string) Han    { return Str(`ok`) }
-func apiArticleUpdate(req Req, args []string) Han { return Str(`ok`) }
-func apiArticleDelete(req Req, args []string) Han { return Str(`ok`) }
-
-type Str string
-
-func (self Str) ServeHTTP(rew Rew, _ Req) { _, _ = io.WriteString(rew, string(self)) }
-func (self Str) Ptr() *Str                { return &self }
+func pageIndex(req Req) Han                       { return rout.Str(`ok`) }
+func pageArticles(req Req) Han                    { return rout.Str(`ok`) }
+func pageArticle(req Req, args []string) Han      { return rout.Str(`ok`) }
+func apiArticleFeed(req Req) Han                  { return rout.Str(`ok`) }
+func apiArticleCreate(req Req) Han                { return rout.Str(`ok`) }
+func apiArticleGet(req Req, args []string) Han    { return rout.Str(`ok`) }
+func apiArticleUpdate(req Req, args []string) Han { return rout.Str(`ok`) }
+func apiArticleDelete(req Req, args []string) Han { return rout.Str(`ok`) }
@@ -1,14 +1,19 @@
 package rout
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	ht "net/http/httptest"
 	"net/url"
 	r "reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type (
@@ -20,7 +25,7 @@ type (
 
 var (
 	staticHandlerVar hhan = Str(`hello world`)
-	staticHandlerPtr hhan = Str(`hello world`).Ptr()
+	staticHandlerPtr hhan = tPtr(Str(`hello world`))
 
 	staticReq = &http.Request{
 		Method: http.MethodPatch,
@@ -119,10 +124,21 @@ var (
 	tAnyPaths   = append(tPaths, ``)
 )
 
-type Str string
+func tRoute(req hreq, fun func(Rou)) (*ht.ResponseRecorder, error) {
+	rew := ht.NewRecorder()
+	return rew, MakeRou(rew, req).Route(fun)
+}
+
+// Returns the status code of the response, or of the routing error if any.
+func tStatus(req hreq, fun func(Rou)) int {
+	rew, err := tRoute(req, fun)
+	if err != nil {
+		return ErrStatusFallback(err)
+	}
+	return rew.Code
+}
 
-func (self Str) ServeHTTP(rew hrew, _ hreq) { _, _ = io.WriteString(rew, string(self)) }
-func (self Str) Ptr() *Str                  { return &self }
+func tPtr[A any](val A) *A { return &val }
 
 type ErrUncomparable []error
 
@@ -152,3 +168,148 @@ func (self ErrUnwrapCyclic) Error() string {
 }
 
 func (self ErrUnwrapCyclic) Unwrap() error { return self }
+
+type ErrJoinCyclic struct{}
+
+func (ErrJoinCyclic) Error() string { return `` }
+
+func (self ErrJoinCyclic) Unwrap() []error { return []error{self, nil} }
+
+type tFailRew struct{ NopRew }
+
+func (tFailRew) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+type tProblemErr struct{}
+
+func (tProblemErr) Error() string { return `problem` }
+
+func (tProblemErr) HttpStatusCode() int { return http.StatusNotFound }
+
+func (tProblemErr) ProblemDetails() map[string]interface{} {
+	return map[string]interface{}{
+		`type`:     `https://example.com/missing`,
+		`detail`:   `one`,
+		`instance`: `/one`,
+		`status`:   200,
+	}
+}
+
+type tRegisteredErr struct{}
+
+func (tRegisteredErr) Error() string { return `registered` }
+
+type tStatusCodeErr int
+
+func (self tStatusCodeErr) Error() string { return `status code` }
+
+func (self tStatusCodeErr) StatusCode() int { return int(self) }
+
+type tHTTPStatusErr int
+
+func (self tHTTPStatusErr) Error() string { return `http status` }
+
+func (self tHTTPStatusErr) HTTPStatus() int { return int(self) }
+
+// Records all status codes and the "Link" header at the time of writing each.
+type tInfoRew struct {
+	NopRew
+	Head  http.Header
+	Codes []int
+	Links [][]string
+}
+
+func (self *tInfoRew) Header() http.Header {
+	if self.Head == nil {
+		self.Head = http.Header{}
+	}
+	return self.Head
+}
+
+func (self *tInfoRew) WriteHeader(code int) {
+	self.Codes = append(self.Codes, code)
+	self.Links = append(self.Links, self.Header().Values(`Link`))
+}
+
+// Records writes and flushes. Safe for concurrent use.
+type tFlushRew struct {
+	NopRew
+	Lock    sync.Mutex
+	Log     []string
+	Flushed chan struct{}
+}
+
+func (self *tFlushRew) Write(val []byte) (int, error) {
+	self.Lock.Lock()
+	defer self.Lock.Unlock()
+	self.Log = append(self.Log, string(val))
+	return len(val), nil
+}
+
+func (self *tFlushRew) Flush() {
+	self.Lock.Lock()
+	defer self.Lock.Unlock()
+	self.Log = append(self.Log, `<flush>`)
+	if self.Flushed != nil {
+		self.Flushed <- struct{}{}
+	}
+}
+
+func (self *tFlushRew) Logged() []string {
+	self.Lock.Lock()
+	defer self.Lock.Unlock()
+	return self.Log
+}
+
+func tRecv[A any](t testing.TB, src <-chan A) A {
+	t.Helper()
+	select {
+	case val := <-src:
+		return val
+	case <-time.After(time.Second):
+		t.Fatalf(`timed out waiting for a value`)
+		panic(`unreachable`)
+	}
+}
+
+// Records the source passed to `io.ReaderFrom`.
+type tReadFromRew struct {
+	*ht.ResponseRecorder
+	Src io.Reader
+}
+
+func (self *tReadFromRew) ReadFrom(src io.Reader) (int64, error) {
+	self.Src = src
+	return io.Copy(self.ResponseRecorder, src)
+}
+
+// Accepts up to `.Limit` bytes, then fails with `.Err`.
+type tLimitRew struct {
+	NopRew
+	Limit int
+	Err   error
+}
+
+func (self *tLimitRew) Write(val []byte) (int, error) {
+	if len(val) > self.Limit {
+		out := self.Limit
+		self.Limit = 0
+		return out, self.Err
+	}
+	self.Limit -= len(val)
+	return len(val), nil
+}
+
+// Handler type larger than one word, copied on each conversion to interface.
+type tLargeHan struct{ One, Two string }
+
+func (tLargeHan) ServeHTTP(hrew, hreq) {}
+
+func tParamFunc(rew hrew, _ hreq, args []string) {
+	_, _ = io.WriteString(rew, strings.Join(args, `,`))
+}
+
+func tCompactJSON(src []byte) []byte {
+	var buf bytes.Buffer
+	try(json.Compact(&buf, src))
+	return buf.Bytes()
+}
@@ -2,13 +2,31 @@ package rout
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"net"
 	"net/http"
 	ht "net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	r "reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
 )
 
 func TestPat_Parse(t *testing.T) {
@@ -650,6 +668,11 @@ func TestErrStatus(t *testing.T) {
 	test(0, io.EOF)
 	test(http.StatusNotFound, NotFound(``, ``))
 	test(http.StatusMethodNotAllowed, MethodNotAllowed(``, ``))
+	test(http.StatusNotAcceptable, NotAcceptable(``, ``))
+	test(http.StatusUnsupportedMediaType, UnsupportedMediaType(``, ``))
+	test(http.StatusForbidden, Forbidden(``, ``))
+	test(http.StatusBadRequest, ErrBadRequest(``))
+	test(http.StatusUnauthorized, Unauthorized(``, ``))
 	test(http.StatusNotFound, fmt.Errorf(`wrapped: %w`, NotFound(``, ``)))
 
 	// Must avoid a runtime panic due to `==` on uncomparable error values.
@@ -658,6 +681,14 @@ func TestErrStatus(t *testing.T) {
 	// Must avoid an infinite loop when an error unwraps to itself.
 	test(0, ErrUnwrapCyclic{NotFound(``, ``)})
 	test(0, ErrUnwrapCyclic{})
+
+	test(http.StatusForbidden, errors.Join(io.EOF, Forbidden(``, ``), NotFound(``, ``)))
+	test(http.StatusNotFound, fmt.Errorf(`%w: %w`, io.EOF, fmt.Errorf(`wrapped: %w`, NotFound(``, ``))))
+	test(0, errors.Join(io.EOF, io.ErrUnexpectedEOF))
+	test(0, ErrJoinCyclic{})
+
+	test(http.StatusTeapot, tStatusCodeErr(http.StatusTeapot))
+	test(http.StatusConflict, fmt.Errorf(`wrapped: %w`, tHTTPStatusErr(http.StatusConflict)))
 }
 
 func TestErrStatusFallback(t *testing.T) {
@@ -702,6 +733,153 @@ func TestWriteErr(t *testing.T) {
 	test(http.StatusNotFound, fmt.Errorf(`wrapped: %w`, NotFound(``, ``)))
 }
 
+func TestWriteErrJSON(t *testing.T) {
+	test := func(expStatus int, expBody string, err error) {
+		t.Helper()
+		rew := ht.NewRecorder()
+		WriteErrJSON(rew, err)
+		eq(t, expStatus, rew.Code)
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(http.StatusOK, ``, nil)
+
+	test(
+		http.StatusInternalServerError,
+		`{"error":{"status":500,"message":"EOF"}}`+"\n",
+		io.EOF,
+	)
+
+	test(
+		http.StatusNotFound,
+		`{"error":{"status":404,"message":"not found"}}`+"\n",
+		fmt.Errorf(`not %w`, ErrNotFound(`found`)),
+	)
+
+	test(
+		http.StatusMethodNotAllowed,
+		`{"error":{"status":405,"method":"POST","path":"/one","message":"method not allowed","allow":"GET"}}`+"\n",
+		ErrRoute{Status: 405, Method: http.MethodPost, Path: `/one`, Msg: `method not allowed`, Allow: `GET`},
+	)
+
+	rew := ht.NewRecorder()
+	WriteErrJSON(rew, ErrRoute{Status: 405, Allow: `GET`})
+	eq(t, `GET`, rew.Header().Get(`Allow`))
+
+	rew = ht.NewRecorder()
+	WriteErrJSON(rew, io.EOF)
+	eq(t, `application/json`, rew.Header().Get(`Content-Type`))
+}
+
+func TestWriteErrProblem(t *testing.T) {
+	test := func(expStatus int, expBody string, err error) {
+		t.Helper()
+		rew := ht.NewRecorder()
+		WriteErrProblem(rew, err)
+		eq(t, expStatus, rew.Code)
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(http.StatusOK, ``, nil)
+
+	test(
+		http.StatusInternalServerError,
+		`{"detail":"EOF","status":500,"title":"Internal Server Error","type":"about:blank"}`+"\n",
+		io.EOF,
+	)
+
+	test(
+		http.StatusNotFound,
+		`{"detail":"one","instance":"/one","status":404,"title":"Not Found","type":"https://example.com/missing"}`+"\n",
+		fmt.Errorf(`wrapped: %w`, tProblemErr{}),
+	)
+
+	rew := ht.NewRecorder()
+	WriteErrProblem(rew, io.EOF)
+	eq(t, `application/problem+json`, rew.Header().Get(`Content-Type`))
+}
+
+func TestNegotiateErr(t *testing.T) {
+	html := func(rew hrew, _ hreq, err error) {
+		rew.Header().Set(`Content-Type`, `text/html`)
+		rew.WriteHeader(ErrStatusFallback(err))
+		_, _ = io.WriteString(rew, `<p>`+err.Error()+`</p>`)
+	}
+
+	test := func(exp string, wri NegotiateErr, accept string) {
+		t.Helper()
+		req := tReq(http.MethodGet, `/`)
+		if accept != `` {
+			req.Header = http.Header{`Accept`: {accept}}
+		}
+
+		rew := ht.NewRecorder()
+		wri.WriteErr(rew, req, ErrNotFound(`missing`))
+		eq(t, http.StatusNotFound, rew.Code)
+		eq(t, exp, rew.Header().Get(`Content-Type`))
+	}
+
+	withHtml := NegotiateErr{HTML: html}
+
+	test(``, withHtml, ``)
+	test(``, withHtml, `*/*`)
+	test(`application/json`, withHtml, `application/json`)
+	test(`application/problem+json`, withHtml, `application/problem+json, application/json;q=0.9`)
+	test(`text/html`, withHtml, `text/html,application/xhtml+xml,*/*;q=0.8`)
+	test(``, NegotiateErr{}, `text/html,application/xhtml+xml,*/*;q=0.8`)
+	test(`application/json`, NegotiateErr{}, `text/html,application/json;q=0.9`)
+
+	rew := ht.NewRecorder()
+	withHtml.WriteErr(rew, tReq(http.MethodGet, `/`), nil)
+	eq(t, http.StatusOK, rew.Code)
+}
+
+func TestErrPage(t *testing.T) {
+	page := ErrPage{
+		Default: template.Must(template.New(``).Parse(`<p>{{.Status}} {{.Title}}: {{.Message}}</p>`)),
+		Pages: map[int]ErrTemplate{
+			http.StatusNotFound: template.Must(template.New(``).Parse(`<h1>{{.Req.URL.Path}} not found</h1>`)),
+		},
+	}
+
+	test := func(expStatus int, expType, expBody string, page ErrPage, err error) {
+		t.Helper()
+		rew := ht.NewRecorder()
+		page.WriteErr(rew, tReq(http.MethodGet, `/one`), err)
+		eq(t, expStatus, rew.Code)
+		eq(t, expType, rew.Header().Get(`Content-Type`))
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(http.StatusOK, ``, ``, page, nil)
+
+	test(
+		http.StatusNotFound, `text/html; charset=utf-8`, `<h1>/one not found</h1>`,
+		page, ErrNotFound(`missing`),
+	)
+
+	test(
+		http.StatusInternalServerError, `text/html; charset=utf-8`,
+		`<p>500 Internal Server Error: &lt;b&gt;</p>`,
+		page, errors.New(`<b>`),
+	)
+
+	test(
+		http.StatusForbidden, ``, `denied`,
+		ErrPage{}, ErrForbidden(`denied`),
+	)
+
+	test(
+		http.StatusForbidden, ``, `denied`,
+		ErrPage{Default: template.Must(template.New(``).Parse(`{{.Missing}}`))},
+		ErrForbidden(`denied`),
+	)
+
+	rew := ht.NewRecorder()
+	page.WriteErr(rew, tReq(http.MethodPost, `/one`), ErrRoute{Status: 405, Allow: `GET`})
+	eq(t, `GET`, rew.Header().Get(`Allow`))
+}
+
 func TestRespond(t *testing.T) {
 	eq(t, nil, Respond(nil, nil))
 	eq(t, nil, Respond(nil, new(http.Response)))
@@ -722,256 +900,4419 @@ func TestRespond(t *testing.T) {
 	eq(t, io.NopCloser(bytes.NewReader([]byte(`hello world`))), res.Body)
 }
 
-/*
-This investigates various quirks of conversion of non-interfaces to interfaces.
-We're relying on implementation details that may be inconsistent between
-different Go implementations, or even compiler versions. Might revise in the
-future.
-*/
-func TestIdent(t *testing.T) {
-	test := func(val interface{}) {
+func TestEarlyHints(t *testing.T) {
+	EarlyHints(nil, `</one.css>`)
+	WriteInfo(nil, http.StatusEarlyHints, nil)
+
+	rew := new(tInfoRew)
+	EarlyHints(rew)
+	WriteInfo(rew, http.StatusSwitchingProtocols, nil)
+	WriteInfo(rew, http.StatusOK, nil)
+	eq(t, []int(nil), rew.Codes)
+
+	EarlyHints(rew, `</one.css>; rel=preload`, `</two.js>; rel=preload`)
+	WriteInfo(rew, http.StatusProcessing, http.Header{`One`: {`two`}})
+	eq(t, []int{http.StatusEarlyHints, http.StatusProcessing}, rew.Codes)
+	eq(t, []string{`</one.css>; rel=preload`, `</two.js>; rel=preload`}, rew.Links[0])
+	eq(t, `two`, rew.Head.Get(`One`))
+}
+
+func TestHandlerTypes(t *testing.T) {
+	test := func(expStatus int, expType, expBody string, han hhan, head http.Header) {
 		t.Helper()
-		eq(t, Ident(val), Ident(val))
-		notEq(t, [2]uintptr{}, Ident(val))
-		notEq(t, Ident(func() {}), Ident(val))
+		rew := ht.NewRecorder()
+		for key, val := range head {
+			rew.Header()[key] = val
+		}
+		han.ServeHTTP(rew, tReq(http.MethodGet, `/`))
+		eq(t, expStatus, rew.Code)
+		eq(t, expType, rew.Header().Get(`Content-Type`))
+		eq(t, expBody, rew.Body.String())
 	}
 
-	test(t)
-	test(Ident)
-	test(eq)
-	test(`hello world`)
-	test([]byte(`hello world`))
-	test(Str(`hello world`))
-	test(staticHandlerVar)
-	test(staticHandlerPtr)
-	test(staticHandlerConst)
-	test(struct{}{})
+	test(http.StatusOK, `text/plain; charset=utf-8`, `ok`, Str(`ok`), nil)
+	test(http.StatusOK, `text/html`, `<p>ok</p>`, Str(`<p>ok</p>`), http.Header{`Content-Type`: {`text/html`}})
+	test(http.StatusOK, `text/plain; charset=utf-8`, `ok`, Bytes(`ok`), nil)
+	test(http.StatusOK, `image/png`, `ok`, Bytes(`ok`), http.Header{`Content-Type`: {`image/png`}})
+	test(http.StatusOK, ``, ``, StatusOnly(0), nil)
+	test(http.StatusNoContent, ``, ``, StatusOnly(http.StatusNoContent), nil)
+	test(http.StatusOK, `application/json`, "{\"one\":\"two\"}\n", JSONOf(map[string]string{`one`: `two`}), nil)
+	test(http.StatusCreated, `application/json`, "10\n", JSONVal{http.StatusCreated, 10}, nil)
 
-	// Constants get deduplicated.
-	eq(t, Ident(`hello world`), Ident(`hello world`))
-	eq(t, Ident(Str(`hello world`)), Ident(Str(`hello world`)))
-	eq(t, Ident(123.456i), Ident(123.456i))
+	_, err := tRoute(tReq(http.MethodGet, `/`), func(rou Rou) {
+		rou.Handler(JSONOf(func() {}))
+	})
+	errs(t, `unsupported type`, err)
+}
 
-	type Zero0 struct{}
-	type Zero1 struct{}
+func TestRecordRew(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Func(func(rew hrew, _ hreq) {
+			EarlyHints(rew, `</one.css>`)
+			rew.Header().Set(`One`, `two`)
+			rew.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(rew, `hello `)
+			try(http.NewResponseController(rew).Flush())
+			_, _ = io.WriteString(rew, `world`)
+		})
+		rou.Exa(`/two`).Func(func(rew hrew, _ hreq) {
+			_, _ = io.WriteString(rew, `two`)
+		})
+	}
 
-	// Zero-sized values get deduplicated, using zerobase.
-	eq(t, Ident(struct{}{}), Ident(struct{}{}))
-	eq(t, Ident(Zero0{}), Ident(Zero0{}))
-	eq(t, Ident(Zero1{}), Ident(Zero1{}))
-	eq(t, Ident([0]byte{}), Ident([0]byte{}))
-	eq(t, Ident(Zero0{})[1], Ident([0]byte{})[1])
+	t.Run(`standalone`, func(t *testing.T) {
+		var rec RecordRew
+		try(MakeRou(&rec, tReq(http.MethodGet, `/one`)).Route(route))
 
-	// Zero-sized values of different types are not the same.
-	notEq(t, Ident(struct{}{}), Ident(Zero0{}))
-	notEq(t, Ident(struct{}{}), Ident(Zero1{}))
-	notEq(t, Ident(Zero0{}), Ident(Zero1{}))
-	notEq(t, Ident(Zero0{}), Ident([0]byte{}))
+		eq(t, http.StatusCreated, rec.Status)
+		eq(t, `two`, rec.Header().Get(`One`))
+		eq(t, `hello world`, rec.Body.String())
+		eq(t, nil, rec.Unwrap())
 
-	// Non-zero-sized non-constants aren't identical even if they're equal or equivalent.
-	notEq(t, Ident(func() {}), Ident(func() {}))
+		res := rec.Res()
+		eq(t, http.StatusCreated, res.StatusCode)
+		eq(t, int64(11), res.ContentLength)
 
-	// These values used to be different before Go 1.18. The test is disabled
-	// because this is not consistent across Go versions, and we don't actually
-	// rely on this behavior. It's left here as a warning.
-	//
-	// notEq(t, Ident([1]byte{}), Ident([1]byte{}))
+		rew := ht.NewRecorder()
+		try(Respond(rew, res))
+		eq(t, http.StatusCreated, rew.Code)
+		eq(t, `hello world`, rew.Body.String())
+		eq(t, `two`, rew.Header().Get(`One`))
+	})
 
-	// Non-constants with size <= word don't get copied on interface conversion.
-	var char byte = 127
-	eq(t, Ident(char), Ident(char))
-	var fun = func() {}
-	eq(t, Ident(fun), Ident(fun))
+	t.Run(`forward`, func(t *testing.T) {
+		rew := ht.NewRecorder()
+		rec := RecordRew{Rew: rew}
+		try(MakeRou(&rec, tReq(http.MethodGet, `/one`)).Route(route))
 
-	// Non-constants larger than 1 word get copied on each interface conversion.
-	var large = `hello world`
-	notEq(t, Ident(large), Ident(large))
+		eq(t, http.StatusCreated, rec.Status)
+		eq(t, `hello world`, rec.Body.String())
+		eq(t, hrew(rew), rec.Unwrap())
 
-	// Pre-converting to interface allows deduplication regardless of size.
-	var largish interface{} = `hello world`
-	eq(t, Ident(largish), Ident(largish))
-}
+		eq(t, http.StatusEarlyHints, rew.Code)
+		eq(t, true, rew.Flushed)
+		eq(t, `hello world`, rew.Body.String())
+		eq(t, `two`, rew.Header().Get(`One`))
+	})
 
-func TestIdentType(t *testing.T) {
-	test := func(exp r.Type, typ interface{}) {
-		t.Helper()
-		eq(t, exp, IdentType(Ident(typ)))
-	}
+	t.Run(`implicit_status`, func(t *testing.T) {
+		var rec RecordRew
+		try(MakeRou(&rec, tReq(http.MethodGet, `/two`)).Route(route))
+		eq(t, http.StatusOK, rec.Status)
+		eq(t, `two`, rec.Body.String())
 
-	test(nil, nil)
-	test(r.TypeOf(0), 123)
-	test(r.TypeOf(``), `str`)
-	test(r.TypeOf(http.Request{}), http.Request{})
+		eq(t, http.StatusOK, new(RecordRew).Res().StatusCode)
+	})
 }
 
-func TestRou_Vis(t *testing.T) {
-	var (
-		handlerFunc = func(hrew, hreq) { panic(`unreachable`) }
-		handler     = http.HandlerFunc(handlerFunc)
-		han         = func(hreq) hhan { panic(`unreachable`) }
-		paramHan    = func(hreq, []string) hhan { panic(`unreachable`) }
-		res         = func(hreq) hres { panic(`unreachable`) }
-		paramRes    = func(hreq, []string) hres { panic(`unreachable`) }
-	)
+func TestRou_Compress(t *testing.T) {
+	const text = `hello world hello world hello world`
 
 	route := func(rou Rou) {
-		rou.Exa(`/handlerFunc`).Get().Func(handlerFunc)
-		rou.Exa(`/handler`).Get().Handler(handler)
-		rou.Exa(`/han`).Get().Han(han)
-		rou.Exa(`/paramHan`).Get().ParamHan(paramHan)
-		rou.Exa(`/res`).Get().Res(res)
-		rou.Exa(`/paramRes`).Get().ParamRes(paramRes)
+		rou = rou.Compress(Gzip{}, Deflate{})
+		rou.Exa(`/text`).Func(func(rew hrew, _ hreq) {
+			rew.Header().Set(`Etag`, `"one"`)
+			_, _ = io.WriteString(rew, text)
+		})
+		rou.Exa(`/json`).Res(func(hreq) *http.Response {
+			return &http.Response{
+				StatusCode:    http.StatusCreated,
+				Header:        http.Header{`Content-Type`: {`application/json`}},
+				ContentLength: int64(len(text)),
+				Body:          io.NopCloser(strings.NewReader(text)),
+			}
+		})
+		rou.Exa(`/image`).Func(func(rew hrew, _ hreq) {
+			rew.Header().Set(`Content-Type`, `image/png`)
+			_, _ = io.WriteString(rew, text)
+		})
+		rou.Exa(`/encoded`).Func(func(rew hrew, _ hreq) {
+			rew.Header().Set(`Content-Encoding`, `br`)
+			_, _ = io.WriteString(rew, text)
+		})
+		rou.Exa(`/empty`).Func(func(rew hrew, _ hreq) {
+			rew.WriteHeader(http.StatusNoContent)
+		})
+	}
 
-		rou.Sta(`/one`).Sub(func(rou Rou) {
-			rou.Pat(`/one/handlerFunc`).Post().Func(handlerFunc)
-			rou.Pat(`/one/handler`).Post().Handler(handler)
-			rou.Pat(`/one/han`).Post().Han(han)
-			rou.Pat(`/one/paramHan`).Post().ParamHan(paramHan)
-			rou.Pat(`/one/res`).Post().Res(res)
-			rou.Pat(`/one/paramRes`).Post().ParamRes(paramRes)
+	test := func(expEnc string, meth, path, accept string) *http.Response {
+		t.Helper()
+		req := tReq(meth, path)
+		if accept != `` {
+			req.Header = http.Header{`Accept-Encoding`: {accept}}
+		}
 
-			rou.Reg(`^/two/([^/])$`).Methods(func(rou Rou) {
-				rou.Get().Func(handlerFunc)
-				rou.Get().Handler(handler)
-				rou.Get().Han(han)
-				rou.Get().ParamHan(paramHan)
-				rou.Get().Res(res)
-				rou.Get().ParamRes(paramRes)
+		rew := ht.NewRecorder()
+		try(MakeRou(rew, req).Route(route))
+		res := rew.Result()
 
-				rou.Patch().Func(handlerFunc)
-				rou.Patch().Handler(handler)
-				rou.Patch().Han(han)
-				rou.Patch().ParamHan(paramHan)
-				rou.Patch().Res(res)
-				rou.Patch().ParamRes(paramRes)
-			})
-		})
+		eq(t, `Accept-Encoding`, res.Header.Get(`Vary`))
+		eq(t, expEnc, res.Header.Get(`Content-Encoding`))
+		return res
 	}
 
-	var endpoints []Endpoint
+	decode := func(res *http.Response) string {
+		t.Helper()
+		var src io.Reader
+		var err error
+		switch res.Header.Get(`Content-Encoding`) {
+		case `gzip`:
+			src, err = gzip.NewReader(res.Body)
+		case `deflate`:
+			src, err = zlib.NewReader(res.Body)
+		default:
+			src = res.Body
+		}
+		try(err)
+		out, err := io.ReadAll(src)
+		try(err)
+		return string(out)
+	}
 
-	Visit(route, VisitorFunc(func(val Endpoint) {
-		endpoints = append(endpoints, val)
-	}))
+	res := test(`gzip`, http.MethodGet, `/text`, `gzip, deflate`)
+	eq(t, text, decode(res))
+	eq(t, `text/plain; charset=utf-8`, res.Header.Get(`Content-Type`))
+	eq(t, `W/"one"`, res.Header.Get(`Etag`))
 
-	eq(
-		t,
-		[]Endpoint{
-			{`/handlerFunc`, MatchExa, http.MethodGet, Ident(Func(handlerFunc))},
-			{`/handler`, MatchExa, http.MethodGet, Ident(http.Handler(handler))},
-			{`/han`, MatchExa, http.MethodGet, Ident(Han(han))},
-			{`/paramHan`, MatchExa, http.MethodGet, Ident(ParamHan(paramHan))},
-			{`/res`, MatchExa, http.MethodGet, Ident(Res(res))},
-			{`/paramRes`, MatchExa, http.MethodGet, Ident(ParamRes(paramRes))},
+	res = test(`deflate`, http.MethodGet, `/text`, `gzip;q=0.5, deflate`)
+	eq(t, text, decode(res))
 
-			{`/one/handlerFunc`, MatchPat, http.MethodPost, Ident(Func(handlerFunc))},
-			{`/one/handler`, MatchPat, http.MethodPost, Ident(http.Handler(handler))},
-			{`/one/han`, MatchPat, http.MethodPost, Ident(Han(han))},
-			{`/one/paramHan`, MatchPat, http.MethodPost, Ident(ParamHan(paramHan))},
-			{`/one/res`, MatchPat, http.MethodPost, Ident(Res(res))},
-			{`/one/paramRes`, MatchPat, http.MethodPost, Ident(ParamRes(paramRes))},
+	res = test(``, http.MethodGet, `/text`, ``)
+	eq(t, text, decode(res))
+	eq(t, `"one"`, res.Header.Get(`Etag`))
 
-			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(Func(handlerFunc))},
-			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(http.Handler(handler))},
-			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(Han(han))},
-			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(ParamHan(paramHan))},
-			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(Res(res))},
-			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(ParamRes(paramRes))},
+	res = test(``, http.MethodGet, `/text`, `br`)
+	eq(t, text, decode(res))
 
-			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(Func(handlerFunc))},
-			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(http.Handler(handler))},
-			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(Han(han))},
-			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(ParamHan(paramHan))},
-			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(Res(res))},
-			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(ParamRes(paramRes))},
-		},
-		endpoints,
-	)
-}
+	res = test(`gzip`, http.MethodGet, `/json`, `*`)
+	eq(t, http.StatusCreated, res.StatusCode)
+	eq(t, ``, res.Header.Get(`Content-Length`))
+	eq(t, text, decode(res))
 
-func TestRegexpVisitor(t *testing.T) {
-	var (
-		hanExa = func(hreq) hhan { panic(`unreachable`) }
-		hanSta = func(hreq) hhan { panic(`unreachable`) }
-		hanReg = func(hreq) hhan { panic(`unreachable`) }
-		hanPat = func(hreq) hhan { panic(`unreachable`) }
-	)
+	res = test(``, http.MethodGet, `/image`, `gzip`)
+	eq(t, text, decode(res))
 
-	notEq(t, Ident(hanExa), Ident(hanSta))
+	res = test(`br`, http.MethodGet, `/encoded`, `gzip`)
+	eq(t, text, decode(res))
+
+	res = test(``, http.MethodGet, `/empty`, `gzip`)
+	eq(t, http.StatusNoContent, res.StatusCode)
+
+	res = test(``, http.MethodHead, `/json`, `gzip`)
+	eq(t, strconv.Itoa(len(text)), res.Header.Get(`Content-Length`))
+}
 
+func TestRou_Compress_flush(t *testing.T) {
 	route := func(rou Rou) {
-		rou.Exa(`/one/exa`).Post().Han(hanExa)
-		rou.Sta(`/two/sta`).Post().Han(hanSta)
-		rou.Reg(`^/three/reg/([^/]+)$`).Post().Han(hanReg)
-		rou.Pat(`/four/pat/{}`).Post().Han(hanPat)
+		rou.Compress(Gzip{}).Exa(`/one`).Responder(Responder{FlushInterval: -1}).Res(
+			func(hreq) *http.Response {
+				return &http.Response{
+					Header: http.Header{`Content-Type`: {`text/plain`}},
+					Body:   io.NopCloser(strings.NewReader(`hello world`)),
+				}
+			},
+		)
 	}
 
-	var endpoints []Endpoint
+	req := tReq(http.MethodGet, `/one`)
+	req.Header = http.Header{`Accept-Encoding`: {`gzip`}}
+	rew := ht.NewRecorder()
+	try(MakeRou(rew, req).Route(route))
 
-	Visit(route, RegexpVisitor{SimpleVisitorFunc(func(path, meth string, ident [2]uintptr) {
-		endpoints = append(endpoints, Endpoint{path, MatchReg, meth, ident})
-	})})
+	eq(t, true, rew.Flushed)
+	eq(t, `gzip`, rew.Header().Get(`Content-Encoding`))
 
-	eq(
+	src, err := gzip.NewReader(rew.Body)
+	try(err)
+	out, err := io.ReadAll(src)
+	try(err)
+	eq(t, `hello world`, string(out))
+}
+
+func TestRou_ETag(t *testing.T) {
+	const body = `hello world`
+	hash := etagHash([]byte(body))
+	var wrapped int
+
+	route := func(rou Rou) {
+		rou = rou.Use(func(han hhan) hhan {
+			return http.HandlerFunc(func(rew hrew, req hreq) {
+				wrapped++
+				han.ServeHTTP(rew, req)
+			})
+		})
+
+		rou.Sta(`/version`).ETag(func(req hreq) string {
+			if req.URL.Path == `/version/none` {
+				return ``
+			}
+			return `v1`
+		}).Sub(func(rou Rou) {
+			rou.Func(func(rew hrew, _ hreq) { _, _ = io.WriteString(rew, body) })
+		})
+
+		rou = rou.ETag(nil)
+		rou.Exa(`/hash`).Func(func(rew hrew, _ hreq) {
+			rew.Header().Set(`Content-Type`, `text/plain`)
+			_, _ = io.WriteString(rew, body)
+		})
+		rou.Exa(`/own`).Func(func(rew hrew, _ hreq) {
+			rew.Header().Set(`Etag`, `"own"`)
+			_, _ = io.WriteString(rew, body)
+		})
+		rou.Exa(`/created`).Func(func(rew hrew, _ hreq) {
+			rew.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(rew, body)
+		})
+		rou.Exa(`/stream`).Func(func(rew hrew, _ hreq) {
+			_, _ = io.WriteString(rew, `hello `)
+			flush(rew)
+			_, _ = io.WriteString(rew, `world`)
+		})
+		rou.Exa(`/res`).Res(func(hreq) *http.Response { return ResText(0, body) })
+	}
+
+	test := func(expStatus int, expTag, expBody string, meth, path, match string) {
+		t.Helper()
+		req := tReq(meth, path)
+		if match != `` {
+			req.Header = http.Header{`If-None-Match`: {match}}
+		}
+		rew, err := tRoute(req, route)
+		try(err)
+		eq(t, expStatus, rew.Code)
+		eq(t, expTag, rew.Header().Get(`Etag`))
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(http.StatusOK, `"v1"`, body, http.MethodGet, `/version`, ``)
+	test(http.StatusOK, `"v1"`, body, http.MethodGet, `/version`, `"v0"`)
+	test(http.StatusNotModified, `"v1"`, ``, http.MethodGet, `/version`, `"v0", W/"v1"`)
+	test(http.StatusNotModified, `"v1"`, ``, http.MethodHead, `/version`, `*`)
+	test(http.StatusOK, ``, body, http.MethodPost, `/version`, `*`)
+	test(http.StatusOK, hash, body, http.MethodGet, `/version/none`, ``)
+
+	test(http.StatusOK, hash, body, http.MethodGet, `/hash`, ``)
+	test(http.StatusNotModified, hash, ``, http.MethodGet, `/hash`, hash)
+	test(http.StatusOK, `"own"`, body, http.MethodGet, `/own`, hash)
+	test(http.StatusNotModified, `"own"`, ``, http.MethodGet, `/own`, `"own"`)
+	test(http.StatusCreated, ``, body, http.MethodGet, `/created`, `*`)
+	test(http.StatusOK, ``, body, http.MethodGet, `/stream`, `*`)
+	test(http.StatusNotModified, hash, ``, http.MethodGet, `/res`, hash)
+
+	eq(t, 13, wrapped)
+
+	req := tReq(http.MethodGet, `/hash`)
+	req.Header = http.Header{`If-None-Match`: {hash}}
+	rew, err := tRoute(req, route)
+	try(err)
+	eq(t, ``, rew.Header().Get(`Content-Type`))
+}
+
+func TestRou_Hints(t *testing.T) {
+	route := func(rou Rou) {
+		rou = rou.Hints(`</one.css>; rel=preload`)
+		rou.Exa(`/one`).Hints(`</two.js>; rel=preload`).Res(func(hreq) *http.Response {
+			return &http.Response{StatusCode: http.StatusCreated}
+		})
+		rou.Exa(`/two`).Get().Func(reachableFunc)
+		rou.Exa(`/three`).Guard(func(hreq) error { return Forbidden(``, ``) }).Func(reachableFunc)
+	}
+
+	test := func(expCodes []int, expLinks [][]string, req hreq) {
+		t.Helper()
+		rew := new(tInfoRew)
+		try(MakeRou(rew, req).Route(route))
+		eq(t, expCodes, rew.Codes)
+		eq(t, expLinks, rew.Links)
+	}
+
+	test(
+		[]int{http.StatusEarlyHints, http.StatusCreated},
+		[][]string{
+			{`</one.css>; rel=preload`, `</two.js>; rel=preload`},
+			{`</one.css>; rel=preload`, `</two.js>; rel=preload`},
+		},
+		tReq(http.MethodGet, `/one`),
+	)
+
+	test(
+		[]int{http.StatusEarlyHints, http.StatusCreated},
+		[][]string{{`</one.css>; rel=preload`}, {`</one.css>; rel=preload`}},
+		tReq(http.MethodGet, `/two`),
+	)
+
+	req := tReq(http.MethodGet, `/one`)
+	req.ProtoMajor, req.ProtoMinor = 1, 0
+	test([]int{http.StatusCreated}, [][]string{nil}, req)
+
+	rew := new(tInfoRew)
+	err := MakeRou(rew, tReq(http.MethodGet, `/three`)).Route(route)
+	eq(t, http.StatusForbidden, ErrStatus(err))
+	eq(t, []int(nil), rew.Codes)
+
+	Visit(route, VisitorFunc(func(Endpoint) {}))
+}
+
+func TestResBytes(t *testing.T) {
+	test := func(expStatus int, expHead http.Header, expBody string, res *http.Response) {
+		t.Helper()
+		rew := ht.NewRecorder()
+		try(Respond(rew, res))
+		eq(t, expStatus, rew.Code)
+		eq(t, expHead, rew.Header())
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(
+		http.StatusOK,
+		http.Header{`Content-Length`: {`0`}},
+		``,
+		ResBytes(0, ``, nil),
+	)
+
+	test(
+		http.StatusCreated,
+		http.Header{`Content-Type`: {`text/plain; charset=utf-8`}, `Content-Length`: {`11`}},
+		`hello world`,
+		ResText(http.StatusCreated, `hello world`),
+	)
+
+	test(
+		http.StatusNotFound,
+		http.Header{`Content-Type`: {`text/html; charset=utf-8`}, `Content-Length`: {`12`}},
+		`<p>hello</p>`,
+		ResHTML(http.StatusNotFound, `<p>hello</p>`),
+	)
+
+	test(
+		http.StatusOK,
+		http.Header{`Content-Type`: {`application/json`}, `Content-Length`: {`13`}},
+		`{"one":"two"}`,
+		ResJSON(http.StatusOK, map[string]string{`one`: `two`}),
+	)
+
+	test(
+		http.StatusFound,
+		http.Header{`Location`: {`/one`}, `Content-Length`: {`0`}},
+		``,
+		ResRedirect(0, `/one`),
+	)
+
+	test(
+		http.StatusMovedPermanently,
+		http.Header{`Location`: {`https://example.com`}, `Content-Length`: {`0`}},
+		``,
+		ResRedirect(http.StatusMovedPermanently, `https://example.com`),
+	)
+
+	_, err := tRoute(tReq(http.MethodGet, `/one`), func(rou Rou) {
+		rou.Exa(`/one`).Res(func(hreq) *http.Response {
+			return ResJSON(http.StatusOK, func() {})
+		})
+	})
+	errs(t, `unsupported type`, err)
+}
+
+func TestResRange(t *testing.T) {
+	const body = `hello world`
+
+	test := func(expStatus int, expRange, expBody string, head http.Header, res *http.Response) {
+		t.Helper()
+		req := tReq(http.MethodGet, `/`)
+		req.Header = head
+
+		rew := ht.NewRecorder()
+		try(Respond(rew, ResRange(req, res)))
+		eq(t, expStatus, rew.Code)
+		eq(t, expRange, rew.Header().Get(`Content-Range`))
+		eq(t, expBody, rew.Body.String())
+		if expStatus == http.StatusPartialContent {
+			eq(t, strconv.Itoa(len(expBody)), rew.Header().Get(`Content-Length`))
+		}
+	}
+
+	ranged := func(val string) http.Header { return http.Header{`Range`: {val}} }
+	res := func() *http.Response { return ResText(0, body) }
+
+	test(http.StatusOK, ``, body, nil, res())
+	test(http.StatusPartialContent, `bytes 0-4/11`, `hello`, ranged(`bytes=0-4`), res())
+	test(http.StatusPartialContent, `bytes 6-10/11`, `world`, ranged(`bytes=6-`), res())
+	test(http.StatusPartialContent, `bytes 6-10/11`, `world`, ranged(`bytes=6-100`), res())
+	test(http.StatusPartialContent, `bytes 8-10/11`, `rld`, ranged(`bytes=-3`), res())
+	test(http.StatusPartialContent, `bytes 0-10/11`, body, ranged(`bytes=-30`), res())
+	test(http.StatusRequestedRangeNotSatisfiable, `bytes */11`, ``, ranged(`bytes=11-`), res())
+	test(http.StatusRequestedRangeNotSatisfiable, `bytes */11`, ``, ranged(`bytes=-0`), res())
+	test(http.StatusOK, ``, body, ranged(`bytes=0-1,3-4`), res())
+	test(http.StatusOK, ``, body, ranged(`bytes=4-2`), res())
+	test(http.StatusOK, ``, body, ranged(`items=0-1`), res())
+	test(http.StatusOK, ``, body, ranged(`bytes=one-two`), res())
+
+	tagged := func() *http.Response {
+		out := res()
+		out.Header.Set(`Etag`, `"one"`)
+		out.Header.Set(`Last-Modified`, `Mon, 02 Jan 2006 15:04:05 GMT`)
+		return out
+	}
+
+	test(http.StatusPartialContent, `bytes 0-4/11`, `hello`, http.Header{`Range`: {`bytes=0-4`}, `If-Range`: {`"one"`}}, tagged())
+	test(http.StatusOK, ``, body, http.Header{`Range`: {`bytes=0-4`}, `If-Range`: {`"two"`}}, tagged())
+	test(http.StatusOK, ``, body, http.Header{`Range`: {`bytes=0-4`}, `If-Range`: {`W/"one"`}}, tagged())
+	test(http.StatusPartialContent, `bytes 0-4/11`, `hello`, http.Header{`Range`: {`bytes=0-4`}, `If-Range`: {`Mon, 02 Jan 2006 15:04:05 GMT`}}, tagged())
+	test(http.StatusOK, ``, body, http.Header{`Range`: {`bytes=0-4`}, `If-Range`: {`Tue, 03 Jan 2006 15:04:05 GMT`}}, tagged())
+
+	test(http.StatusCreated, ``, body, ranged(`bytes=0-4`), ResText(http.StatusCreated, body))
+	test(http.StatusOK, ``, body, ranged(`bytes=0-4`), &http.Response{Body: io.NopCloser(strings.NewReader(body))})
+
+	offset := res()
+	_, err := offset.Body.(io.Seeker).Seek(6, io.SeekStart)
+	try(err)
+	offset.ContentLength = 5
+	test(http.StatusPartialContent, `bytes 1-2/5`, `or`, ranged(`bytes=1-2`), offset)
+
+	req := tReq(http.MethodPost, `/`)
+	req.Header = ranged(`bytes=0-4`)
+	eq(t, ``, ResRange(req, res()).Header.Get(`Accept-Ranges`))
+	eq(t, `bytes`, ResRange(tReq(http.MethodGet, `/`), res()).Header.Get(`Accept-Ranges`))
+	eq(t, (*http.Response)(nil), ResRange(req, nil))
+}
+
+func TestRespond_file(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `file.txt`)
+	try(os.WriteFile(path, []byte(`hello world`), os.ModePerm))
+
+	test := func(expLen, expBody string, offset int64) {
+		t.Helper()
+
+		file, err := os.Open(path)
+		try(err)
+		defer file.Close()
+
+		_, err = file.Seek(offset, io.SeekStart)
+		try(err)
+
+		rew := &tReadFromRew{ResponseRecorder: ht.NewRecorder()}
+		try(Respond(rew, &http.Response{Body: file}))
+
+		eq(t, expLen, rew.Header().Get(`Content-Length`))
+		eq(t, expBody, rew.Body.String())
+		eq(t, io.Reader(file), rew.Src)
+	}
+
+	test(`11`, `hello world`, 0)
+	test(`5`, `world`, 6)
+	test(``, ``, 12)
+
+	rew := ht.NewRecorder()
+	try(Respond(rew, &http.Response{Body: io.NopCloser(strings.NewReader(`hello world`))}))
+	eq(t, ``, rew.Header().Get(`Content-Length`))
+}
+
+func TestRespondN(t *testing.T) {
+	body := func() io.ReadCloser { return io.NopCloser(strings.NewReader(`hello world`)) }
+
+	size, err := RespondN(ht.NewRecorder(), &http.Response{Body: body()})
+	eq(t, int64(11), size)
+	eq(t, nil, err)
+
+	size, err = RespondN(nil, &http.Response{Body: body()})
+	eq(t, int64(0), size)
+	eq(t, nil, err)
+
+	test := func(exp ErrRespond, expAbort bool, rew hrew, body io.Reader) {
+		t.Helper()
+		size, err := RespondN(rew, &http.Response{Body: io.NopCloser(body)})
+		eq(t, exp.Written, size)
+		eq(t, error(exp), err)
+		eq(t, expAbort, errors.Is(err, ErrClientAbort))
+		eq(t, true, errors.Is(err, exp.Err))
+	}
+
+	test(
+		ErrRespond{Err: syscall.EPIPE, Written: 3, Abort: true},
+		true,
+		&tLimitRew{Limit: 3, Err: syscall.EPIPE},
+		strings.NewReader(`hello world`),
+	)
+
+	test(
+		ErrRespond{Err: io.ErrShortWrite, Written: 5},
+		false,
+		&tLimitRew{Limit: 5, Err: io.ErrShortWrite},
+		strings.NewReader(`hello world`),
+	)
+
+	test(
+		ErrRespond{Err: syscall.ECONNRESET, Written: 5, Read: true},
+		false,
+		NopRew{},
+		io.MultiReader(strings.NewReader(`hello`), iotest.ErrReader(syscall.ECONNRESET)),
+	)
+
+	test(
+		ErrRespond{Err: syscall.ECONNRESET, Written: 5, Abort: true},
+		true,
+		&tLimitRew{Limit: 5, Err: syscall.ECONNRESET},
+		strings.NewReader(`hello world`),
+	)
+}
+
+func TestErrRespond(t *testing.T) {
+	eq(
+		t,
+		`[rout] failed writing response body: client aborted after 3 bytes: broken pipe`,
+		ErrRespond{Err: syscall.EPIPE, Written: 3, Abort: true}.Error(),
+	)
+	eq(
+		t,
+		`[rout] failed reading response body after 0 bytes: EOF`,
+		ErrRespond{Err: io.EOF, Read: true}.Error(),
+	)
+	eq(t, false, errors.Is(ErrRespond{Err: syscall.EPIPE}, ErrClientAbort))
+	eq(t, http.StatusInternalServerError, ErrStatusFallback(ErrRespond{}))
+}
+
+func TestResponder(t *testing.T) {
+	test := func(exp []string, resp Responder, body string) {
+		t.Helper()
+		rew := new(tFlushRew)
+		try(resp.Respond(rew, &http.Response{
+			Body: io.NopCloser(iotest.OneByteReader(strings.NewReader(body))),
+		}))
+		eq(t, exp, rew.Logged())
+	}
+
+	test([]string{`a`, `b`, `c`}, Responder{}, `abc`)
+
+	test(
+		[]string{`<flush>`, `a`, `<flush>`, `b`, `<flush>`, `c`, `<flush>`},
+		Responder{FlushInterval: -1},
+		`abc`,
+	)
+
+	test(
+		[]string{`<flush>`, `a`, `b`, `c`, `<flush>`, `d`, `e`, `f`, `<flush>`, `g`},
+		Responder{FlushSize: 3},
+		`abcdefg`,
+	)
+
+	test(
+		[]string{`<flush>`, `a`, `b`, `c`},
+		Responder{FlushInterval: time.Hour},
+		`abc`,
+	)
+}
+
+func TestResponder_BufSize(t *testing.T) {
+	test := func(exp []string, resp Responder) {
+		t.Helper()
+		rew := new(tFlushRew)
+		try(resp.Respond(rew, &http.Response{Body: io.NopCloser(strings.NewReader(`hello world`))}))
+		eq(t, exp, rew.Logged())
+	}
+
+	test([]string{`hello world`}, Responder{})
+	test([]string{`hell`, `o wo`, `rld`}, Responder{BufSize: 4})
+	test([]string{`<flush>`, `hello`, ` worl`, `d`}, Responder{BufSize: 5, FlushInterval: time.Hour})
+
+	eq(t, bufPool(0), bufPool(defaultBufSize))
+	eq(t, 4, len(*bufPool(4).Get().(*[]byte)))
+}
+
+func TestResponder_interval(t *testing.T) {
+	rew := &tFlushRew{Flushed: make(chan struct{}, 8)}
+	src, out := io.Pipe()
+	done := make(chan error)
+
+	go func() {
+		done <- Responder{FlushInterval: time.Millisecond}.Respond(rew, &http.Response{Body: src})
+	}()
+
+	tRecv(t, rew.Flushed)
+	_, _ = io.WriteString(out, `one`)
+	tRecv(t, rew.Flushed)
+	eq(t, []string{`<flush>`, `one`, `<flush>`}, rew.Logged())
+
+	try(out.Close())
+	eq(t, nil, <-done)
+}
+
+func TestRou_Responder(t *testing.T) {
+	route := func(rou Rou) {
+		rou = rou.Responder(Responder{FlushInterval: -1})
+		rou.Exa(`/one`).Res(func(hreq) *http.Response {
+			return &http.Response{Body: io.NopCloser(strings.NewReader(`one`))}
+		})
+		rou.Exa(`/two`).ResAny(nil, func(hreq) *http.Response {
+			return &http.Response{Body: io.NopCloser(strings.NewReader(`two`))}
+		})
+	}
+
+	test := func(exp []string, path string) {
+		t.Helper()
+		rew := new(tFlushRew)
+		try(MakeRou(rew, tReq(http.MethodGet, path)).Route(route))
+		eq(t, exp, rew.Logged())
+	}
+
+	test([]string{`<flush>`, `one`, `<flush>`}, `/one`)
+	test([]string{`<flush>`, `two`, `<flush>`}, `/two`)
+}
+
+func TestRespond_length(t *testing.T) {
+	test := func(exp string, head http.Header, res *http.Response) {
+		t.Helper()
+		rew := ht.NewRecorder()
+		for key, val := range head {
+			rew.Header()[key] = val
+		}
+		try(Respond(rew, res))
+		eq(t, exp, rew.Header().Get(`Content-Length`))
+	}
+
+	body := func() io.ReadCloser { return io.NopCloser(strings.NewReader(`hello world`)) }
+
+	test(``, nil, &http.Response{Body: body()})
+	test(``, nil, &http.Response{Body: body(), ContentLength: -1})
+	test(`11`, nil, &http.Response{Body: body(), ContentLength: 11})
+	test(`0`, nil, &http.Response{})
+	test(`0`, nil, &http.Response{Body: http.NoBody})
+	test(``, nil, &http.Response{ContentLength: -1})
+	test(`12`, nil, &http.Response{Body: body(), ContentLength: 12, Header: http.Header{`Content-Length`: {`12`}}})
+	test(`10`, http.Header{`Content-Length`: {`10`}}, &http.Response{Body: body(), ContentLength: 11})
+}
+
+/*
+This investigates various quirks of conversion of non-interfaces to interfaces.
+We're relying on implementation details that may be inconsistent between
+different Go implementations, or even compiler versions. Might revise in the
+future.
+*/
+func TestIdent(t *testing.T) {
+	test := func(val interface{}) {
+		t.Helper()
+		eq(t, Ident(val), Ident(val))
+		notEq(t, [2]uintptr{}, Ident(val))
+		notEq(t, Ident(func() {}), Ident(val))
+	}
+
+	test(t)
+	test(Ident)
+	test(eq)
+	test(`hello world`)
+	test([]byte(`hello world`))
+	test(Str(`hello world`))
+	test(staticHandlerVar)
+	test(staticHandlerPtr)
+	test(staticHandlerConst)
+	test(struct{}{})
+
+	// Constants get deduplicated.
+	eq(t, Ident(`hello world`), Ident(`hello world`))
+	eq(t, Ident(Str(`hello world`)), Ident(Str(`hello world`)))
+	eq(t, Ident(123.456i), Ident(123.456i))
+
+	type Zero0 struct{}
+	type Zero1 struct{}
+
+	// Zero-sized values get deduplicated, using zerobase.
+	eq(t, Ident(struct{}{}), Ident(struct{}{}))
+	eq(t, Ident(Zero0{}), Ident(Zero0{}))
+	eq(t, Ident(Zero1{}), Ident(Zero1{}))
+	eq(t, Ident([0]byte{}), Ident([0]byte{}))
+	eq(t, Ident(Zero0{})[1], Ident([0]byte{})[1])
+
+	// Zero-sized values of different types are not the same.
+	notEq(t, Ident(struct{}{}), Ident(Zero0{}))
+	notEq(t, Ident(struct{}{}), Ident(Zero1{}))
+	notEq(t, Ident(Zero0{}), Ident(Zero1{}))
+	notEq(t, Ident(Zero0{}), Ident([0]byte{}))
+
+	// Non-zero-sized non-constants aren't identical even if they're equal or equivalent.
+	notEq(t, Ident(func() {}), Ident(func() {}))
+
+	// These values used to be different before Go 1.18. The test is disabled
+	// because this is not consistent across Go versions, and we don't actually
+	// rely on this behavior. It's left here as a warning.
+	//
+	// notEq(t, Ident([1]byte{}), Ident([1]byte{}))
+
+	// Non-constants with size <= word don't get copied on interface conversion.
+	var char byte = 127
+	eq(t, Ident(char), Ident(char))
+	var fun = func() {}
+	eq(t, Ident(fun), Ident(fun))
+
+	// Non-constants larger than 1 word get copied on each interface conversion.
+	var large = `hello world`
+	notEq(t, Ident(large), Ident(large))
+
+	// Pre-converting to interface allows deduplication regardless of size.
+	var largish interface{} = `hello world`
+	eq(t, Ident(largish), Ident(largish))
+}
+
+func TestIdentType(t *testing.T) {
+	test := func(exp r.Type, typ interface{}) {
+		t.Helper()
+		eq(t, exp, IdentType(Ident(typ)))
+	}
+
+	test(nil, nil)
+	test(r.TypeOf(0), 123)
+	test(r.TypeOf(``), `str`)
+	test(r.TypeOf(http.Request{}), http.Request{})
+}
+
+func TestRegistry(t *testing.T) {
+	var reg Registry[string]
+	eq(t, 0, reg.Len())
+
+	han := func(hreq) hhan { return nil }
+	reg.Set(han, `han`)
+	fun := reg.Func(`func`, func(hrew, hreq) {})
+	large := reg.Handler(`large`, tLargeHan{`one`, `two`})
+	str := reg.Handler(`str`, Str(`str`))
+	eq(t, 4, reg.Len())
+
+	route := func(rou Rou) {
+		rou.Exa(`/han`).Han(han)
+		rou.Exa(`/func`).Func(fun)
+		rou.Exa(`/large`).Handler(large)
+		rou.Exa(`/str`).Handler(str)
+		rou.Exa(`/other`).Handler(Str(`other`))
+	}
+
+	var docs []string
+	Visit(route, VisitorFunc(func(val Endpoint) {
+		doc, _ := reg.Endpoint(val)
+		docs = append(docs, doc)
+	}))
+	eq(t, []string{`han`, `func`, `large`, `str`, ``}, docs)
+
+	val, ok := reg.Get(Han(han))
+	eq(t, `han`, val)
+	eq(t, true, ok)
+
+	reg.Delete(han)
+	val, ok = reg.Get(han)
+	eq(t, ``, val)
+	eq(t, false, ok)
+	eq(t, 3, reg.Len())
+
+	var gro sync.WaitGroup
+	for ind := 0; ind < 8; ind++ {
+		gro.Add(1)
+		go func(ind int) {
+			defer gro.Done()
+			reg.SetIdent(Ident(ind), `num`)
+			_, _ = reg.GetIdent(Ident(str))
+		}(ind)
+	}
+	gro.Wait()
+}
+
+func TestRou_Vis(t *testing.T) {
+	var (
+		handlerFunc = func(hrew, hreq) { panic(`unreachable`) }
+		handler     = http.HandlerFunc(handlerFunc)
+		han         = func(hreq) hhan { panic(`unreachable`) }
+		paramHan    = func(hreq, []string) hhan { panic(`unreachable`) }
+		res         = func(hreq) hres { panic(`unreachable`) }
+		paramRes    = func(hreq, []string) hres { panic(`unreachable`) }
+	)
+
+	route := func(rou Rou) {
+		rou.Exa(`/handlerFunc`).Get().Func(handlerFunc)
+		rou.Exa(`/handler`).Get().Handler(handler)
+		rou.Exa(`/han`).Get().Han(han)
+		rou.Exa(`/paramHan`).Get().ParamHan(paramHan)
+		rou.Exa(`/res`).Get().Res(res)
+		rou.Exa(`/paramRes`).Get().ParamRes(paramRes)
+
+		rou.Sta(`/one`).Sub(func(rou Rou) {
+			rou.Pat(`/one/handlerFunc`).Post().Func(handlerFunc)
+			rou.Pat(`/one/handler`).Post().Handler(handler)
+			rou.Pat(`/one/han`).Post().Han(han)
+			rou.Pat(`/one/paramHan`).Post().ParamHan(paramHan)
+			rou.Pat(`/one/res`).Post().Res(res)
+			rou.Pat(`/one/paramRes`).Post().ParamRes(paramRes)
+
+			rou.Reg(`^/two/([^/])$`).Methods(func(rou Rou) {
+				rou.Get().Func(handlerFunc)
+				rou.Get().Handler(handler)
+				rou.Get().Han(han)
+				rou.Get().ParamHan(paramHan)
+				rou.Get().Res(res)
+				rou.Get().ParamRes(paramRes)
+
+				rou.Patch().Func(handlerFunc)
+				rou.Patch().Handler(handler)
+				rou.Patch().Han(han)
+				rou.Patch().ParamHan(paramHan)
+				rou.Patch().Res(res)
+				rou.Patch().ParamRes(paramRes)
+			})
+		})
+	}
+
+	var endpoints []Endpoint
+
+	Visit(route, VisitorFunc(func(val Endpoint) {
+		endpoints = append(endpoints, val)
+	}))
+
+	eq(
+		t,
+		[]Endpoint{
+			{`/handlerFunc`, MatchExa, http.MethodGet, Ident(Func(handlerFunc))},
+			{`/handler`, MatchExa, http.MethodGet, Ident(http.Handler(handler))},
+			{`/han`, MatchExa, http.MethodGet, Ident(Han(han))},
+			{`/paramHan`, MatchExa, http.MethodGet, Ident(ParamHan(paramHan))},
+			{`/res`, MatchExa, http.MethodGet, Ident(Res(res))},
+			{`/paramRes`, MatchExa, http.MethodGet, Ident(ParamRes(paramRes))},
+
+			{`/one/handlerFunc`, MatchPat, http.MethodPost, Ident(Func(handlerFunc))},
+			{`/one/handler`, MatchPat, http.MethodPost, Ident(http.Handler(handler))},
+			{`/one/han`, MatchPat, http.MethodPost, Ident(Han(han))},
+			{`/one/paramHan`, MatchPat, http.MethodPost, Ident(ParamHan(paramHan))},
+			{`/one/res`, MatchPat, http.MethodPost, Ident(Res(res))},
+			{`/one/paramRes`, MatchPat, http.MethodPost, Ident(ParamRes(paramRes))},
+
+			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(Func(handlerFunc))},
+			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(http.Handler(handler))},
+			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(Han(han))},
+			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(ParamHan(paramHan))},
+			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(Res(res))},
+			{`^/two/([^/])$`, MatchReg, http.MethodGet, Ident(ParamRes(paramRes))},
+
+			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(Func(handlerFunc))},
+			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(http.Handler(handler))},
+			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(Han(han))},
+			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(ParamHan(paramHan))},
+			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(Res(res))},
+			{`^/two/([^/])$`, MatchReg, http.MethodPatch, Ident(ParamRes(paramRes))},
+		},
+		endpoints,
+	)
+}
+
+func TestRegexpVisitor(t *testing.T) {
+	var (
+		hanExa = func(hreq) hhan { panic(`unreachable`) }
+		hanSta = func(hreq) hhan { panic(`unreachable`) }
+		hanReg = func(hreq) hhan { panic(`unreachable`) }
+		hanPat = func(hreq) hhan { panic(`unreachable`) }
+	)
+
+	notEq(t, Ident(hanExa), Ident(hanSta))
+
+	route := func(rou Rou) {
+		rou.Exa(`/one/exa`).Post().Han(hanExa)
+		rou.Sta(`/two/sta`).Post().Han(hanSta)
+		rou.Reg(`^/three/reg/([^/]+)$`).Post().Han(hanReg)
+		rou.Pat(`/four/pat/{}`).Post().Han(hanPat)
+	}
+
+	var endpoints []Endpoint
+
+	Visit(route, RegexpVisitor{SimpleVisitorFunc(func(path, meth string, ident [2]uintptr) {
+		endpoints = append(endpoints, Endpoint{path, MatchReg, meth, ident})
+	})})
+
+	eq(
+		t,
+		[]Endpoint{
+			{`^/one/exa$`, MatchReg, http.MethodPost, Ident(hanExa)},
+			{`^/two/sta`, MatchReg, http.MethodPost, Ident(hanSta)},
+			{`^/three/reg/([^/]+)$`, MatchReg, http.MethodPost, Ident(hanReg)},
+			{`^/four/pat/([^/?#]+)$`, MatchReg, http.MethodPost, Ident(hanPat)},
+		},
+		endpoints,
+	)
+}
+
+func TestPatternVisitor(t *testing.T) {
+	var (
+		hanExa = func(hreq) hhan { panic(`unreachable`) }
+		hanPat = func(hreq) hhan { panic(`unreachable`) }
+	)
+
+	notEq(t, Ident(hanExa), Ident(hanPat))
+
+	// This adapter supports only "exact" and "pattern" matches.
+	route := func(rou Rou) {
+		rou.Exa(`/one/exa`).Post().Han(hanExa)
+		rou.Pat(`/four/pat/{}`).Post().Han(hanPat)
+	}
+
+	var endpoints []Endpoint
+
+	vis := PatternVisitor{SimpleVisitorFunc(func(path, meth string, ident [2]uintptr) {
+		endpoints = append(endpoints, Endpoint{path, MatchPat, meth, ident})
+	})}
+
+	Visit(route, vis)
+
+	eq(
+		t,
+		[]Endpoint{
+			{`/one/exa`, MatchPat, http.MethodPost, Ident(hanExa)},
+			{`/four/pat/{}`, MatchPat, http.MethodPost, Ident(hanPat)},
+		},
+		endpoints,
+	)
+
+	routeReg := func(rou Rou) {
+		rou.Reg(`^/three/reg/([^/]+)$`).Post().Han(nil)
+	}
+
+	panics(
+		t,
+		`[rout] unable to convert match "reg" for route "^/three/reg/([^/]+)$" "POST" to OAS pattern`,
+		func() { Visit(routeReg, vis) },
+	)
+
+	routeSta := func(rou Rou) {
+		rou.Sta(`/two/sta`).Post().Han(nil)
+	}
+
+	panics(
+		t,
+		`[rout] unable to convert match "sta" for route "/two/sta" "POST" to OAS pattern`,
+		func() { Visit(routeSta, vis) },
+	)
+}
+
+func TestPathDepth(t *testing.T) {
+	test := func(exp int, src string) {
+		t.Helper()
+		eq(t, exp, pathDepth(src))
+	}
+
+	test(0, ``)
+	test(0, `/`)
+	test(0, `//`)
+	test(1, `one`)
+	test(1, `/one`)
+	test(1, `/one/`)
+	test(2, `/one/two`)
+	test(2, `/one//two/`)
+	test(3, `/one/two/three`)
+}
+
+func TestRou_Depth(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Sta(`/api`).Depth(3, 3).Sub(func(rou Rou) {
+			rou.Pat(`/api/{}/{}`).Func(reachableFunc)
+		})
+		rou.Sta(`/api`).Depth(0, 1).Func(reachableFunc)
+	}
+
+	test := func(exp int, path string) {
+		t.Helper()
+		eq(t, exp, tStatus(tReq(http.MethodGet, path), route))
+	}
+
+	test(201, `/api`)
+	test(201, `/api/one/two`)
+	test(http.StatusNotFound, `/api/one`)
+	test(http.StatusNotFound, `/api/one/two/three`)
+}
+
+func TestRou_Exacts(t *testing.T) {
+	var (
+		hanOne = func(hreq) hhan { return Str(`one`) }
+		hanTwo = func(hreq) hhan { return Str(`two`) }
+		hans   = map[string]Han{`/two`: hanTwo, `/one`: hanOne}
+	)
+
+	route := func(rou Rou) { rou.Get().Exacts(hans) }
+
+	test := func(exp string, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(http.MethodGet, path), route)
+		try(err)
+		eq(t, exp, rew.Body.String())
+	}
+
+	test(`one`, `/one`)
+	test(`two`, `/two`)
+
+	_, err := tRoute(tReq(http.MethodGet, `/three`), route)
+	errs(t, `no such endpoint`, err)
+
+	_, err = tRoute(tReq(http.MethodPost, `/one`), route)
+	errs(t, `method not allowed`, err)
+
+	var endpoints []Endpoint
+	Visit(route, VisitorFunc(func(val Endpoint) {
+		endpoints = append(endpoints, val)
+	}))
+
+	eq(
+		t,
+		[]Endpoint{
+			{`/one`, MatchExa, http.MethodGet, Ident(Han(hanOne))},
+			{`/two`, MatchExa, http.MethodGet, Ident(Han(hanTwo))},
+		},
+		endpoints,
+	)
+}
+
+func TestRou_LaxSlash(t *testing.T) {
+	hans := map[string]Han{`/three`: func(hreq) hhan { return Str(`three`) }}
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Func(reachableFunc)
+		rou.Pat(`/two/{}/`).Func(reachableFunc)
+		rou.Exa(`/`).Func(reachableFunc)
+		rou.Exacts(hans)
+	}
+
+	strict := func(exp int, path string) {
+		t.Helper()
+		eq(t, exp, tStatus(tReq(http.MethodGet, path), route))
+	}
+
+	lax := func(exp int, path string) {
+		t.Helper()
+		eq(t, exp, tStatus(tReq(http.MethodGet, path), func(rou Rou) {
+			rou.LaxSlash().Sub(route)
+		}))
+	}
+
+	strict(201, `/one`)
+	strict(404, `/one/`)
+	strict(404, `/two/three`)
+	strict(201, `/two/three/`)
+	strict(200, `/three`)
+	strict(404, `/three/`)
+	strict(201, `/`)
+
+	lax(201, `/one`)
+	lax(201, `/one/`)
+	lax(404, `/one//`)
+	lax(201, `/two/three`)
+	lax(201, `/two/three/`)
+	lax(200, `/three`)
+	lax(200, `/three/`)
+	lax(201, `/`)
+	lax(404, ``)
+}
+
+func TestMuxPat_Parse(t *testing.T) {
+	fail := func(src string) {
+		t.Helper()
+		errs(t, `[rout] invalid mux pattern`, new(MuxPat).Parse(src))
+	}
+
+	fail(``)
+	fail(`one`)
+	fail(`GET /one`)
+	fail(`example.com/one`)
+	fail(`/one{}`)
+	fail(`/{one}two`)
+	fail(`/{{}}`)
+	fail(`/{$}/one`)
+	fail(`/{one...}/two`)
+
+	test := func(exp MuxPat, src string) {
+		t.Helper()
+		var tar MuxPat
+		try(tar.Parse(src))
+		eq(t, exp, tar)
+	}
+
+	test(MuxPat{Pat: Pat{`/`}, Prefix: true}, `/`)
+	test(MuxPat{Pat: Pat{`/`}}, `/{$}`)
+	test(MuxPat{Pat: Pat{`/one`}}, `/one`)
+	test(MuxPat{Pat: Pat{`/one/`}, Prefix: true}, `/one/`)
+	test(MuxPat{Pat: Pat{`/one/`}}, `/one/{$}`)
+	test(MuxPat{Pat: Pat{`/one/`, ``}}, `/one/{id}`)
+	test(MuxPat{Pat: Pat{`/one/`, ``, `/`}}, `/one/{id}/{$}`)
+	test(MuxPat{Pat: Pat{`/one/`, ``, `/two/`, ``}}, `/one/{}/two/{}`)
+	test(MuxPat{Pat: Pat{`/one/`}, Rest: true}, `/one/{path...}`)
+	test(MuxPat{Pat: Pat{`/`, ``, `/`}, Rest: true}, `/{id}/{path...}`)
+}
+
+func TestMuxPat_Submatch(t *testing.T) {
+	test := func(exp []string, src, inp string) {
+		t.Helper()
+		eq(t, exp, cachedMux(src).Submatch(inp))
+		eq(t, exp != nil, cachedMux(src).Match(inp))
+	}
+
+	test([]string{}, `/`, `/`)
+	test([]string{}, `/`, `/one/two`)
+	test([]string{}, `/{$}`, `/`)
+	test(nil, `/{$}`, `/one`)
+	test([]string{}, `/one`, `/one`)
+	test(nil, `/one`, `/one/`)
+	test(nil, `/one/`, `/one`)
+	test([]string{}, `/one/`, `/one/`)
+	test([]string{}, `/one/`, `/one/two/three`)
+	test([]string{}, `/one/{$}`, `/one/`)
+	test(nil, `/one/{$}`, `/one/two`)
+	test([]string{`two`}, `/one/{id}`, `/one/two`)
+	test(nil, `/one/{id}`, `/one/`)
+	test(nil, `/one/{id}`, `/one/two/`)
+	test([]string{`two`}, `/one/{id}/{$}`, `/one/two/`)
+	test([]string{``}, `/one/{path...}`, `/one/`)
+	test([]string{`two/three`}, `/one/{path...}`, `/one/two/three`)
+	test([]string{`one`, `two/three`}, `/{id}/{path...}`, `/one/two/three`)
+}
+
+func TestMuxPat_Reg(t *testing.T) {
+	test := func(exp, src string) {
+		t.Helper()
+		eq(t, exp, cachedMux(src).Reg())
+	}
+
+	test(`^/`, `/`)
+	test(`^/$`, `/{$}`)
+	test(`^/one$`, `/one`)
+	test(`^/one/`, `/one/`)
+	test(`^/one/([^/?#]+)$`, `/one/{id}`)
+	test(`^/one/(.*)$`, `/one/{path...}`)
+}
+
+func TestRou_Mux(t *testing.T) {
+	var args []string
+
+	route := func(rou Rou) {
+		rou.Mux(`GET /one/{id}`).ParamFunc(func(_ hrew, _ hreq, val []string) { args = val })
+		rou.Mux(`/two/{path...}`).ParamFunc(func(_ hrew, _ hreq, val []string) { args = val })
+	}
+
+	args = nil
+	try(MakeRou(NopRew{}, tReq(http.MethodGet, `/one/two`)).Route(route))
+	eq(t, []string{`two`}, args)
+
+	args = nil
+	try(MakeRou(NopRew{}, tReq(http.MethodPost, `/two/three/four`)).Route(route))
+	eq(t, []string{`three/four`}, args)
+
+	errs(t, `method not allowed`, MakeRou(NopRew{}, tReq(http.MethodPost, `/one/two`)).Route(route))
+
+	var endpoints []Endpoint
+	Visit(route, RegexpVisitor{SimpleVisitorFunc(func(path, meth string, _ [2]uintptr) {
+		endpoints = append(endpoints, Endpoint{Pattern: path, Match: MatchReg, Method: meth})
+	})})
+
+	eq(
+		t,
+		[]Endpoint{
+			{Pattern: `^/one/([^/?#]+)$`, Match: MatchReg, Method: http.MethodGet},
+			{Pattern: `^/two/(.*)$`, Match: MatchReg},
+		},
+		endpoints,
+	)
+}
+
+func TestMuxPat_ParseColon(t *testing.T) {
+	fail := func(src string) {
+		t.Helper()
+		errs(t, `[rout] invalid colon-style pattern`, new(MuxPat).ParseColon(src))
+	}
+
+	fail(``)
+	fail(`one`)
+	fail(`/:`)
+	fail(`/*`)
+	fail(`/:one:two`)
+	fail(`/*one/two`)
+	fail(`/one*two`)
+
+	test := func(exp MuxPat, src string) {
+		t.Helper()
+		var tar MuxPat
+		try(tar.ParseColon(src))
+		eq(t, exp, tar)
+	}
+
+	test(MuxPat{Pat: Pat{`/`}}, `/`)
+	test(MuxPat{Pat: Pat{`/one/`}}, `/one/`)
+	test(MuxPat{Pat: Pat{`/`, ``}}, `/:id`)
+	test(MuxPat{Pat: Pat{`/one/`, ``, `/two`}}, `/one/:id/two`)
+	test(MuxPat{Pat: Pat{`/one_`, ``}}, `/one_:id`)
+	test(MuxPat{Pat: Pat{`/`, ``, `/`, ``}}, `/:one/:two`)
+	test(MuxPat{Pat: Pat{`/one/`}, Rest: true}, `/one/*path`)
+}
+
+func TestRou_Col(t *testing.T) {
+	test := func(exp []string, pat, path string) {
+		t.Helper()
+		var args []string
+		_, _ = tRoute(tReq(http.MethodGet, path), func(rou Rou) {
+			rou.Col(pat).ParamFunc(func(_ hrew, _ hreq, val []string) { args = val })
+		})
+		eq(t, exp, args)
+	}
+
+	test([]string{`two`}, `/one/:id`, `/one/two`)
+	test(nil, `/one/:id`, `/one/two/three`)
+	test([]string{`two`, `three`}, `/one/:id/:sub`, `/one/two/three`)
+	test([]string{``}, `/one/*path`, `/one/`)
+	test([]string{`two/three`}, `/one/*path`, `/one/two/three`)
+	test(nil, `/one/*path`, `/one`)
+}
+
+func TestHostPat_Parse(t *testing.T) {
+	fail := func(src string) {
+		t.Helper()
+		errs(t, `[rout] invalid host pattern`, new(HostPat).Parse(src))
+	}
+
+	fail(``)
+	fail(`.`)
+	fail(`one..two`)
+	fail(`{one`)
+	fail(`one}`)
+	fail(`{one}two`)
+	fail(`{{}}`)
+	fail(`{}.{}.{}.{}.{}.{}.{}.{}.{}`)
+
+	test := func(exp HostPat, src string) {
+		t.Helper()
+		var tar HostPat
+		try(tar.Parse(src))
+		eq(t, exp, tar)
+		eq(t, exp.String(), tar.String())
+	}
+
+	test(HostPat{`localhost`}, `localhost`)
+	test(HostPat{`example`, `com`}, `example.com`)
+	test(HostPat{``, `example`, `com`}, `{tenant}.example.com`)
+	test(HostPat{``, ``, `example`, `com`}, `{}.{}.example.com`)
+}
+
+func TestHostPat_Submatch(t *testing.T) {
+	test := func(exp []string, src, inp string) {
+		t.Helper()
+		eq(t, exp, cachedHost(src).Submatch(inp))
+		eq(t, exp != nil, cachedHost(src).Match(inp))
+	}
+
+	test([]string{}, `example.com`, `example.com`)
+	test([]string{}, `example.com`, `EXAMPLE.com:8080`)
+	test(nil, `example.com`, `one.example.com`)
+	test(nil, `example.com`, `example.org`)
+	test(nil, `example.com`, ``)
+	test([]string{`one`}, `{}.example.com`, `one.example.com`)
+	test([]string{`one`}, `{}.example.com`, `one.example.com:8080`)
+	test(nil, `{}.example.com`, `example.com`)
+	test(nil, `{}.example.com`, `.example.com`)
+	test(nil, `{}.example.com`, `one.two.example.com`)
+	test([]string{`one`, `two`}, `{}.{}.example.com`, `one.two.example.com`)
+}
+
+func TestRou_HostPat(t *testing.T) {
+	test := func(exp []string, host, path string) {
+		t.Helper()
+		var args []string
+		req := tReq(http.MethodGet, path)
+		req.Host = host
+
+		_, _ = tRoute(req, func(rou Rou) {
+			rou.HostPat(`{}.example.com`).Sub(func(rou Rou) {
+				rou.Pat(`/one/{}`).ParamFunc(func(_ hrew, _ hreq, val []string) { args = val })
+				rou.Exa(`/two`).ParamFunc(func(_ hrew, _ hreq, val []string) { args = val })
+			})
+		})
+		eq(t, exp, args)
+	}
+
+	test([]string{`two`, `tenant`}, `tenant.example.com`, `/one/two`)
+	test([]string{`tenant`}, `tenant.example.com:8080`, `/two`)
+	test(nil, `example.com`, `/two`)
+	test(nil, `tenant.example.org`, `/two`)
+}
+
+func TestQueryAny(t *testing.T) {
+	test := func(exp bool, src, key string, val string) {
+		t.Helper()
+		eq(t, exp, queryAny(src, key, func(str string) bool { return str == val }))
+	}
+
+	test(false, ``, ``, ``)
+	test(false, ``, `one`, ``)
+	test(true, `one`, `one`, ``)
+	test(true, `one=`, `one`, ``)
+	test(true, `one=two`, `one`, `two`)
+	test(false, `one=two`, `one`, `three`)
+	test(true, `one=two&one=three`, `one`, `three`)
+	test(true, `three=four&one=two`, `one`, `two`)
+	test(true, `one=two+three`, `one`, `two three`)
+	test(true, `o%6Ee=two%20three`, `one`, `two three`)
+	test(false, `ones=two`, `one`, `two`)
+}
+
+func TestRou_Query(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Query(`format`, `csv`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/one`).QueryExists(`debug`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(203) })
+		rou.Exa(`/one`).Func(reachableFunc)
+	}
+
+	test := func(exp int, query string) {
+		t.Helper()
+		req := tReq(http.MethodGet, `/one`)
+		req.URL.RawQuery = query
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(201, ``)
+	test(201, `format=json`)
+	test(202, `format=csv`)
+	test(202, `format=json&format=csv`)
+	test(203, `debug`)
+	test(203, `debug=`)
+	test(202, `debug&format=csv`)
+}
+
+func TestRou_Hdr(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Hdr(`X-Requested-With`, `XMLHttpRequest`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/one`).HdrPrefix(`authorization`, `Bearer `).Func(func(rew hrew, _ hreq) { rew.WriteHeader(203) })
+		rou.Exa(`/one`).Func(reachableFunc)
+	}
+
+	test := func(exp int, head http.Header) {
+		t.Helper()
+		req := tReq(http.MethodGet, `/one`)
+		req.Header = head
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(201, nil)
+	test(201, http.Header{`X-Requested-With`: {`fetch`}})
+	test(202, http.Header{`X-Requested-With`: {`XMLHttpRequest`}})
+	test(202, http.Header{`X-Requested-With`: {`fetch`, `XMLHttpRequest`}})
+	test(201, http.Header{`Authorization`: {`Basic abc`}})
+	test(203, http.Header{`Authorization`: {`Bearer abc`}})
+}
+
+func TestAcceptQ(t *testing.T) {
+	test := func(exp float64, head []string, typ string) {
+		t.Helper()
+		eq(t, exp, acceptQ(head, typ))
+	}
+
+	const json = `application/json`
+
+	test(1, nil, json)
+	test(1, []string{``}, json)
+	test(1, []string{json}, json)
+	test(1, []string{`APPLICATION/JSON`}, json)
+	test(0, []string{`text/html`}, json)
+	test(1, []string{`*/*`}, json)
+	test(1, []string{`application/*`}, json)
+	test(0, []string{`text/*`}, json)
+	test(0.5, []string{`text/html, application/json;q=0.5`}, json)
+	test(0.5, []string{`text/html`, `application/json ; q=0.5`}, json)
+	test(0.1, []string{`text/html, */*;q=0.1`}, json)
+	test(0, []string{`*/*, application/json;q=0`}, json)
+	test(0.8, []string{`application/json;q=0.8, application/*;q=0.2`}, json)
+	test(0.8, []string{`application/*;q=0.2, application/json;q=0.8`}, json)
+	test(0, []string{`application/json;q=invalid`}, json)
+}
+
+func TestRou_Negotiate(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Negotiate(func(rou Rou) {
+			rou.Accepts(`application/json`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+			rou.Accepts(`text/html`, `application/xhtml+xml`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(203) })
+		})
+	}
+
+	test := func(exp int, meth string, accept string) {
+		t.Helper()
+		req := tReq(meth, `/one`)
+		req.Header = http.Header{`Accept`: {accept}}
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(202, http.MethodGet, ``)
+	test(202, http.MethodGet, `*/*`)
+	test(202, http.MethodGet, `application/json`)
+	test(203, http.MethodGet, `text/html`)
+	test(203, http.MethodGet, `application/xhtml+xml`)
+	test(203, http.MethodGet, `application/json;q=0, */*`)
+	test(http.StatusNotAcceptable, http.MethodGet, `image/png`)
+	test(http.StatusMethodNotAllowed, http.MethodPost, `image/png`)
+}
+
+func TestRou_ContentTypes(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Post().ContentTypes(func(rou Rou) {
+			rou.ContentType(`application/json`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+			rou.ContentType(`multipart/*`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(203) })
+		})
+	}
+
+	test := func(exp int, typ string) {
+		t.Helper()
+		req := tReq(http.MethodPost, `/one`)
+		req.Header = http.Header{`Content-Type`: {typ}}
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(202, `application/json`)
+	test(202, `Application/JSON; charset=utf-8`)
+	test(203, `multipart/form-data; boundary=something`)
+	test(203, `multipart/mixed`)
+	test(http.StatusUnsupportedMediaType, ``)
+	test(http.StatusUnsupportedMediaType, `text/plain`)
+	test(http.StatusUnsupportedMediaType, `application/x-www-form-urlencoded`)
+}
+
+func TestReqScheme(t *testing.T) {
+	test := func(exp string, req hreq) {
+		t.Helper()
+		eq(t, exp, ReqScheme(req))
+	}
+
+	test(``, nil)
+	test(`http`, tReq(``, ``))
+	test(`https`, &http.Request{TLS: new(tls.ConnectionState)})
+	test(`https`, &http.Request{URL: &url.URL{Scheme: `https`}})
+	test(`https`, &http.Request{Header: http.Header{`X-Forwarded-Proto`: {`HTTPS`}}})
+	test(`https`, &http.Request{Header: http.Header{`X-Forwarded-Proto`: {`https, http`}}})
+	test(`https`, &http.Request{Header: http.Header{`Forwarded`: {`for=1.2.3.4;proto=https`}}})
+	test(`http`, &http.Request{Header: http.Header{`Forwarded`: {`for=1.2.3.4;proto=http, proto=https`}}})
+}
+
+func TestRou_TLS(t *testing.T) {
+	route := func(rou Rou) {
+		rou.TLS().Func(reachableFunc)
+		rou.Scheme(`http`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(http.StatusPermanentRedirect) })
+	}
+
+	req := tReq(http.MethodGet, `/`)
+	eq(t, http.StatusPermanentRedirect, tStatus(req, route))
+
+	req.TLS = new(tls.ConnectionState)
+	eq(t, 201, tStatus(req, route))
+}
+
+func TestRou_If(t *testing.T) {
+	var calls int
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).If(func(req hreq) bool {
+			calls++
+			return req.Method == http.MethodPost
+		}).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+
+		rou.Exa(`/one`).If(nil).Func(reachableFunc)
+	}
+
+	eq(t, 202, tStatus(tReq(http.MethodPost, `/one`), route))
+	eq(t, 1, calls)
+
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/one`), route))
+	eq(t, 2, calls)
+
+	// The predicate is not invoked when the pattern doesn't match.
+	eq(t, 404, tStatus(tReq(http.MethodGet, `/two`), route))
+	eq(t, 2, calls)
+}
+
+func TestRou_Meths(t *testing.T) {
+	han := func(hreq) hhan { return nil }
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Meths(http.MethodPut, http.MethodPatch).Han(han)
+		rou.Exa(`/two`).Meths().Func(reachableFunc)
+	}
+
+	test := func(exp int, meth, path string) {
+		t.Helper()
+		eq(t, exp, tStatus(tReq(meth, path), route))
+	}
+
+	test(200, http.MethodPut, `/one`)
+	test(200, http.MethodPatch, `/one`)
+	test(http.StatusMethodNotAllowed, http.MethodGet, `/one`)
+	test(201, http.MethodGet, `/two`)
+	test(201, http.MethodDelete, `/two`)
+
+	rou := MakeRou(NopRew{}, tReq(http.MethodPatch, `/one`))
+	try(rou.Route(route))
+	eq(t, Endpoint{`/one`, MatchExa, http.MethodPatch, Ident(Han(han))}, rou.Mut.Endpoint)
+
+	var endpoints []Endpoint
+	Visit(route, VisitorFunc(func(val Endpoint) {
+		endpoints = append(endpoints, val)
+	}))
+
+	eq(
+		t,
+		[]Endpoint{
+			{`/one`, MatchExa, http.MethodPut, Ident(Han(han))},
+			{`/one`, MatchExa, http.MethodPatch, Ident(Han(han))},
+			{`/two`, MatchExa, ``, Ident(Func(reachableFunc))},
+		},
+		endpoints,
+	)
+}
+
+func TestRou_GetHead(t *testing.T) {
+	route := func(rou Rou) { rou.Exa(`/one`).GetHead().Func(reachableFunc) }
+
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/one`), route))
+	eq(t, 201, tStatus(tReq(http.MethodHead, `/one`), route))
+	eq(t, http.StatusMethodNotAllowed, tStatus(tReq(http.MethodPost, `/one`), route))
+}
+
+func TestRou_Any(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Sta(`/one`).Sub(func(rou Rou) {
+			rou.Exa(`/one/two`).Get().Func(reachableFunc)
+			rou.AnyFunc(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		})
+		rou.Sta(`/two`).Post().Sub(func(rou Rou) {
+			rou.Any(http.HandlerFunc(func(rew hrew, _ hreq) { rew.WriteHeader(203) }))
+		})
+	}
+
+	test := func(exp int, meth, path string) {
+		t.Helper()
+		eq(t, exp, tStatus(tReq(meth, path), route))
+	}
+
+	test(201, http.MethodGet, `/one/two`)
+	test(http.StatusMethodNotAllowed, http.MethodPost, `/one/two`)
+	test(202, http.MethodPost, `/one/three`)
+	test(202, http.MethodDelete, `/one`)
+	test(203, http.MethodPost, `/two/three`)
+	test(http.StatusMethodNotAllowed, http.MethodGet, `/two/three`)
+	test(http.StatusNotFound, http.MethodGet, `/three`)
+}
+
+func TestRou_Cookie(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).CookieVal(`cohort`, `b`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/one`).Cookie(`session`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(203) })
+		rou.Exa(`/one`).Func(reachableFunc)
+	}
+
+	test := func(exp int, cookie string) {
+		t.Helper()
+		req := tReq(http.MethodGet, `/one`)
+		req.Header = http.Header{`Cookie`: {cookie}}
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(201, ``)
+	test(201, `other=123`)
+	test(203, `session=123`)
+	test(203, `session=`)
+	test(203, `cohort=a; session=123`)
+	test(202, `cohort=b; session=123`)
+	test(202, `cohort=b`)
+}
+
+func TestLangRanges(t *testing.T) {
+	test := func(exp []langRange, head ...string) {
+		t.Helper()
+		eq(t, exp, langRanges(head, nil))
+	}
+
+	test(nil)
+	test(nil, ``)
+	test([]langRange{{`en`, 1}}, `en`)
+	test([]langRange{{`en-US`, 1}, {`en`, 0.9}}, `en-US, en;q=0.9`)
+	test([]langRange{{`de`, 1}, {`en`, 0.5}}, `en;q=0.5, de`)
+	test([]langRange{{`de`, 0.8}, {`fr`, 0.8}, {`en`, 0.5}}, `en;q=0.5`, `de;q=0.8, fr;q=0.8`)
+	test([]langRange{{`*`, 1}, {`en`, 0}}, `en;q=0, *`)
+}
+
+func TestRou_Lang(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Lang(`de`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Lang(`en-GB`, `fr`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(203) })
+		rou.Func(reachableFunc)
+	}
+
+	test := func(exp int, lang string) {
+		t.Helper()
+		req := tReq(http.MethodGet, `/`)
+		req.Header = http.Header{`Accept-Language`: {lang}}
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(201, ``)
+	test(202, `de`)
+	test(202, `DE-at`)
+	test(202, `*`)
+	test(202, `en;q=0.5, de;q=0.9`)
+	test(202, `en, de;q=0.9`)
+	test(202, `es, de;q=0.1`)
+	test(203, `en`)
+	test(203, `en-GB`)
+	test(201, `en-US`)
+	test(203, `fr-CA, es;q=0.9`)
+	test(203, `es, en-US, fr;q=0.1`)
+	test(201, `es`)
+	test(201, `de;q=0`)
+	test(201, `es, de;q=0`)
+	test(203, `*, de;q=0`)
+	test(202, `de-AT;q=0, de`)
+	test(201, `*;q=0`)
+}
+
+func TestRou_CIDR(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Sta(`/debug`).Restrict(func(rou Rou) {
+			rou.CIDR(`10.0.0.0/8`, `127.0.0.1`, `::1`).Sub(func(rou Rou) {
+				rou.Exa(`/debug/one`).Func(reachableFunc)
+			})
+		})
+	}
+
+	test := func(exp int, addr, path string) {
+		t.Helper()
+		req := tReq(http.MethodGet, path)
+		req.RemoteAddr = addr
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(201, `10.1.2.3:1234`, `/debug/one`)
+	test(201, `127.0.0.1:1234`, `/debug/one`)
+	test(201, `[::1]:1234`, `/debug/one`)
+	test(404, `10.1.2.3:1234`, `/debug/two`)
+	test(403, `127.0.0.2:1234`, `/debug/one`)
+	test(403, `11.1.2.3:1234`, `/debug/one`)
+	test(403, ``, `/debug/one`)
+	test(404, `11.1.2.3:1234`, `/one`)
+
+	panics(t, `[rout] invalid network "one"`, func() {
+		CIDR{`one`}.Match(&http.Request{RemoteAddr: `127.0.0.1:1234`})
+	})
+}
+
+func TestWeight(t *testing.T) {
+	reqWith := func(head, cookie string) *http.Request {
+		req := tReq(http.MethodGet, `/`)
+		req.Header = http.Header{}
+		if head != `` {
+			req.Header.Set(`X-Client-Id`, head)
+		}
+		if cookie != `` {
+			req.AddCookie(&http.Cookie{Name: `session`, Value: cookie})
+		}
+		return req
+	}
+
+	test := func(exp bool, val Weight, req *http.Request) {
+		t.Helper()
+		for range iter(8) {
+			eq(t, exp, val.Match(req))
+		}
+	}
+
+	both := Weight{Percent: 50, Header: `X-Client-Id`, Cookie: `session`}
+
+	test(false, Weight{}, reqWith(`one`, ``))
+	test(false, Weight{Header: `X-Client-Id`}, reqWith(`one`, ``))
+	test(true, Weight{Percent: 100}, nil)
+	test(true, Weight{Percent: 100, Header: `X-Client-Id`}, reqWith(``, ``))
+	test(false, Weight{Percent: 99, Header: `X-Client-Id`}, nil)
+	test(false, Weight{Percent: 99, Header: `X-Client-Id`}, reqWith(``, `one`))
+	test(false, Weight{Percent: 99, Cookie: `session`}, reqWith(`one`, ``))
+	test(false, Weight{Percent: 99}, reqWith(`one`, `one`))
+
+	// Stable for a given client.
+	test(both.Match(reqWith(`one`, ``)), both, reqWith(`one`, ``))
+	test(both.Match(reqWith(`one`, ``)), both, reqWith(``, `one`))
+	test(both.Match(reqWith(`one`, ``)), both, reqWith(`one`, `two`))
+
+	count := func(val Weight) (out int) {
+		for ind := range iter(1000) {
+			if val.Match(reqWith(fmt.Sprint(ind), ``)) {
+				out++
+			}
+		}
+		return
+	}
+
+	one := count(Weight{Percent: 30, Header: `X-Client-Id`})
+	if one < 200 || one > 400 {
+		t.Fatalf(`expected roughly 30%% of 1000 clients to match, got %v`, one)
+	}
+
+	// Salt makes experiments on the same identifier independent.
+	var overlap int
+	for ind := range iter(1000) {
+		req := reqWith(fmt.Sprint(ind), ``)
+		if (Weight{Percent: 50, Header: `X-Client-Id`, Salt: `one`}).Match(req) &&
+			(Weight{Percent: 50, Header: `X-Client-Id`, Salt: `two`}).Match(req) {
+			overlap++
+		}
+	}
+	if overlap < 150 || overlap > 350 {
+		t.Fatalf(`expected roughly 25%% of 1000 clients to match both experiments, got %v`, overlap)
+	}
+}
+
+func TestRou_Weight(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Weight(100, `X-Client-Id`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/two`).Weight(0, `X-Client-Id`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/three`).Weight(99, `X-Client-Id`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/four`).WeightCookie(99, `session`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/five`).Methods(func(rou Rou) {
+			rou.Weight(50, `X-Client-Id`).Get().Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+			rou.Post().Func(reachableFunc)
+		})
+		rou.Func(reachableFunc)
+	}
+
+	eq(t, 202, tStatus(tReq(http.MethodGet, `/one`), route))
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/two`), route))
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/three`), route))
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/four`), route))
+
+	req := tReq(http.MethodGet, `/four`)
+	req.Header = http.Header{}
+	req.AddCookie(&http.Cookie{Name: `session`, Value: `two`})
+	eq(t, (Weight{Percent: 99, Cookie: `session`}).Match(req), tStatus(req, route) == 202)
+
+	// The decision doesn't change when the block re-runs, for example for HEAD.
+	for ind := range iter(32) {
+		req := tReq(http.MethodHead, `/five`)
+		req.Header = http.Header{`X-Client-Id`: {fmt.Sprint(ind)}}
+		exp := 405
+		if (Weight{Percent: 50, Header: `X-Client-Id`}).Match(req) {
+			exp = 202
+		}
+		eq(t, exp, tStatus(req, route))
+	}
+}
+
+func TestRou_Websocket(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Websocket().Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		rou.Exa(`/one`).Upgrade().Func(func(rew hrew, _ hreq) { rew.WriteHeader(203) })
+		rou.Exa(`/one`).Func(reachableFunc)
+	}
+
+	test := func(exp int, head http.Header) {
+		t.Helper()
+		req := tReq(http.MethodGet, `/one`)
+		req.Header = head
+		eq(t, exp, tStatus(req, route))
+	}
+
+	test(201, nil)
+	test(201, http.Header{`Upgrade`: {`websocket`}})
+	test(201, http.Header{`Connection`: {`Upgrade`}})
+	test(202, http.Header{`Connection`: {`Upgrade`}, `Upgrade`: {`websocket`}})
+	test(202, http.Header{`Connection`: {`keep-alive, Upgrade`}, `Upgrade`: {`WebSocket`}})
+	test(203, http.Header{`Connection`: {`upgrade`}, `Upgrade`: {`h2c`}})
+}
+
+func TestRou_ErrFunc(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).ErrFunc(func(rew hrew, _ hreq) error {
+			rew.WriteHeader(201)
+			return nil
+		})
+		rou.Exa(`/two`).ErrFunc(func(hrew, hreq) error { return io.EOF })
+		rou.Exa(`/three`).ErrFunc(func(hrew, hreq) error { return NotFound(``, `/three`) })
+		rou.Exa(`/four`).ErrFunc(nil)
+	}
+
+	test := func(path string) (*ht.ResponseRecorder, error) {
+		return tRoute(tReq(http.MethodGet, path), route)
+	}
+
+	rew, err := test(`/one`)
+	eq(t, nil, err)
+	eq(t, 201, rew.Code)
+
+	_, err = test(`/two`)
+	eq(t, io.EOF, err)
+
+	_, err = test(`/three`)
+	eq(t, NotFound(``, `/three`), err)
+
+	_, err = test(`/four`)
+	eq(t, nil, err)
+}
+
+func TestRou_ParamErrFunc(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Pat(`/one/{}`).ParamErrFunc(func(rew hrew, _ hreq, args []string) error {
+			if args[0] == `invalid` {
+				return io.EOF
+			}
+			_, _ = io.WriteString(rew, args[0])
+			return nil
+		})
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one/two`), route)
+	eq(t, nil, err)
+	eq(t, `two`, rew.Body.String())
+
+	_, err = tRoute(tReq(http.MethodGet, `/one/invalid`), route)
+	eq(t, io.EOF, err)
+
+	_, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	errs(t, `no such endpoint`, err)
+}
+
+func TestRou_HanErr(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).HanErr(func(hreq) (hhan, error) { return Str(`one`), nil })
+		rou.Exa(`/two`).HanErr(func(hreq) (hhan, error) { return Str(`two`), io.EOF })
+		rou.Exa(`/three`).HanErr(func(hreq) (hhan, error) { return nil, nil })
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, `one`, rew.Body.String())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, io.EOF, err)
+	eq(t, ``, rew.Body.String())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	eq(t, nil, err)
+	eq(t, ``, rew.Body.String())
+}
+
+func TestRou_ResErr(t *testing.T) {
+	res := func(body string) hres {
+		return &http.Response{
+			StatusCode: http.StatusAccepted,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).ResErr(func(hreq) (hres, error) { return res(`one`), nil })
+		rou.Exa(`/two`).ResErr(func(hreq) (hres, error) { return res(`two`), io.EOF })
+		rou.Exa(`/three`).ResErr(func(hreq) (hres, error) { return nil, io.EOF })
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusAccepted, rew.Code)
+	eq(t, `one`, rew.Body.String())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, io.EOF, err)
+	eq(t, ``, rew.Body.String())
+
+	_, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	eq(t, io.EOF, err)
+}
+
+func TestJSON(t *testing.T) {
+	type Inp struct{ Num int }
+	type Out struct{ Num int }
+
+	han := JSON(func(_ hreq, inp Inp) (Out, error) {
+		switch inp.Num {
+		case -1:
+			return Out{}, ErrNotFound(`not found`)
+		case -2:
+			return Out{}, errors.New(`connection to db.internal:5432 refused`)
+		case -3:
+			return Out{}, ErrRoute{Status: 404, Method: http.MethodPost, Path: `/secret`, Msg: `not found`, Redact: true}
+		}
+		return Out{inp.Num * 2}, nil
+	})
+
+	test := func(expStatus int, expBody string, body string) {
+		t.Helper()
+		req := tReq(http.MethodPost, `/`)
+		req.Body = io.NopCloser(strings.NewReader(body))
+
+		rew, err := tRoute(req, func(rou Rou) { rou.Han(han) })
+		try(err)
+
+		eq(t, expStatus, rew.Code)
+		eq(t, `application/json`, rew.Header().Get(`Content-Type`))
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(200, `{"Num":0}`+"\n", ``)
+	test(200, `{"Num":20}`+"\n", `{"Num": 10}`)
+	test(400, `{"error":"unexpected EOF"}`+"\n", `{"Num": 10`)
+	test(404, `{"error":"not found"}`+"\n", `{"Num": -1}`)
+	test(500, `{"error":"Internal Server Error"}`+"\n", `{"Num": -2}`)
+	test(404, `{"error":"[rout] routing error (HTTP status 404): not found"}`+"\n", `{"Num": -3}`)
+}
+
+func TestMatch_Names(t *testing.T) {
+	eq(t, []string(nil), MatchExa.Names(`/one`))
+	eq(t, []string(nil), MatchSta.Names(`/one`))
+	eq(t, []string(nil), MatchPat.Names(``))
+	eq(t, []string{`id`, ``}, MatchPat.Names(`/one/{id}/two/{}`))
+	eq(t, []string{`id`, ``}, MatchReg.Names(`^/one/(?P<id>[^/]+)/([^/]+)$`))
+	eq(t, []string{`id`, `path`}, MatchMux.Names(`/one/{id}/{path...}`))
+	eq(t, []string{`id`}, MatchMux.Names(`/one/{id}/{$}`))
+	eq(t, []string{`id`, `path`}, MatchCol.Names(`/one/:id/*path`))
+}
+
+func TestBind(t *testing.T) {
+	type Params struct {
+		Id     uint64 `rout:"id"`
+		Second string `rout:"1"`
+		Flag   bool
+		Skip   string `rout:"-"`
+		Other  string
+		hidden string
+	}
+
+	var out Params
+	route := func(rou Rou) {
+		Bind(rou.Pat(`/one/{id}/{}/{flag}`).Get(), func(_ hrew, _ hreq, val Params) {
+			out = val
+		})
+	}
+
+	eq(t, 200, tStatus(tReq(http.MethodGet, `/one/12/two/true`), route))
+	eq(t, Params{Id: 12, Second: `two`, Flag: true}, out)
+
+	eq(t, 400, tStatus(tReq(http.MethodGet, `/one/-12/two/true`), route))
+	eq(t, 400, tStatus(tReq(http.MethodGet, `/one/12/two/three`), route))
+	eq(t, 405, tStatus(tReq(http.MethodPost, `/one/12/two/true`), route))
+	eq(t, 404, tStatus(tReq(http.MethodGet, `/one/12/two`), route))
+
+	_, err := tRoute(tReq(http.MethodGet, `/one/two`), func(rou Rou) {
+		Bind(rou.Pat(`/one/{}`), func(hrew, hreq, Params) {})
+	})
+	errs(t, `pattern "/one/{}" has no capture "id"`, err)
+	eq(t, 0, ErrStatus(err))
+
+	_, err = tRoute(tReq(http.MethodGet, `/one/-12`), func(rou Rou) {
+		Bind(rou.Reg(`^/one/(?P<id>[^/]+)$`), func(hrew, hreq, struct{ Id int }) {})
+	})
+	eq(t, nil, err)
+}
+
+func TestRou_ParamMapFunc(t *testing.T) {
+	var out map[string]string
+	fun := func(_ hrew, _ hreq, val map[string]string) { out = val }
+
+	test := func(exp map[string]string, req hreq, fun func(Rou)) {
+		t.Helper()
+		out = nil
+		_, err := tRoute(req, fun)
+		try(err)
+		eq(t, exp, out)
+	}
+
+	test(
+		map[string]string{`id`: `two`, `action`: `four`},
+		tReq(http.MethodGet, `/one/two/three/four`),
+		func(rou Rou) { rou.Pat(`/one/{id}/{}/{action}`).ParamMapFunc(fun) },
+	)
+
+	test(
+		map[string]string{`id`: `two`},
+		tReq(http.MethodGet, `/one/two/three`),
+		func(rou Rou) { rou.Reg(`^/one/(?P<id>[^/]+)/([^/]+)$`).ParamMapFunc(fun) },
+	)
+
+	test(
+		map[string]string{`id`: `two`, `tenant`: `acme`},
+		&http.Request{Method: http.MethodGet, Host: `acme.example.com`, URL: &url.URL{Path: `/one/two`}},
+		func(rou Rou) { rou.HostPat(`{tenant}.example.com`).Mux(`/one/{id}`).ParamMapFunc(fun) },
+	)
+
+	test(
+		map[string]string{},
+		tReq(http.MethodGet, `/one`),
+		func(rou Rou) { rou.Exa(`/one`).ParamMapFunc(fun) },
+	)
+}
+
+func TestRou_ParamMapHan(t *testing.T) {
+	rew, err := tRoute(tReq(http.MethodGet, `/one/two`), func(rou Rou) {
+		rou.Col(`/one/:id`).ParamMapHan(func(_ hreq, val map[string]string) hhan {
+			return Str(val[`id`])
+		})
+	})
+	eq(t, nil, err)
+	eq(t, `two`, rew.Body.String())
+}
+
+func TestRou_Group(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Sta(`/api`).Post().Hdr(`X-Version`, `2`).Group(func(rou Rou) {
+			rou.Exa(`/api/one`).Func(reachableFunc)
+			rou.Exa(`/api/two`).Get().Han(func(hreq) hhan { return Str(`two`) })
+		})
+		rou.Exa(`/api/one`).Handler(Str(`fallback`))
+		rou.Exa(`/three`).Handler(Str(`three`))
+	}
+
+	req := func(meth, path, ver string) hreq {
+		out := tReq(meth, path)
+		out.Header = http.Header{`X-Version`: {ver}}
+		return out
+	}
+
+	eq(t, 201, tStatus(req(http.MethodPost, `/api/one`, `2`), route))
+	eq(t, 200, tStatus(req(http.MethodPost, `/api/one`, `1`), route))
+	eq(t, 405, tStatus(req(http.MethodPost, `/api/two`, `2`), route))
+	eq(t, 200, tStatus(req(http.MethodGet, `/api/two`, `2`), route))
+	eq(t, 404, tStatus(req(http.MethodGet, `/api/two`, `1`), route))
+	eq(t, 200, tStatus(req(http.MethodPost, `/three`, `2`), route))
+
+	var visited []Endpoint
+	Visit(route, VisitorFunc(func(val Endpoint) {
+		val.Handler = [2]uintptr{}
+		visited = append(visited, val)
+	}))
+
+	eq(
+		t,
+		[]Endpoint{
+			{`/api/one`, MatchExa, http.MethodPost, [2]uintptr{}},
+			{`/api/two`, MatchExa, http.MethodGet, [2]uintptr{}},
+			{`/api/one`, MatchExa, ``, [2]uintptr{}},
+			{`/three`, MatchExa, ``, [2]uintptr{}},
+		},
+		visited,
+	)
+}
+
+func TestRou_Use(t *testing.T) {
+	var trace []string
+
+	mid := func(name string) func(hhan) hhan {
+		return func(next hhan) hhan {
+			return http.HandlerFunc(func(rew hrew, req hreq) {
+				trace = append(trace, name)
+				rew.Header().Set(`X-`+name, `1`)
+				next.ServeHTTP(rew, req)
+			})
+		}
+	}
+
+	route := func(rou Rou) {
+		rou = rou.Use(mid(`One`))
+		rou.Exa(`/one`).Func(reachableFunc)
+		rou.Sta(`/two`).Use(mid(`Two`), nil).Sub(func(rou Rou) {
+			rou.Pat(`/two/{}`).ParamHan(func(_ hreq, args []string) hhan { return Str(args[0]) })
+		})
+		rou.Exa(`/three`).Handler(Str(`three`))
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, 201, rew.Code)
+	eq(t, []string{`One`}, trace)
+
+	trace = nil
+	rew, err = tRoute(tReq(http.MethodGet, `/two/three`), route)
+	eq(t, nil, err)
+	eq(t, `three`, rew.Body.String())
+	eq(t, `1`, rew.Header().Get(`X-Two`))
+	eq(t, []string{`One`, `Two`}, trace)
+
+	trace = nil
+	_, err = tRoute(tReq(http.MethodGet, `/four`), route)
+	errs(t, `no such endpoint`, err)
+	eq(t, []string(nil), trace)
+
+	_, err = tRoute(tReq(http.MethodGet, `/one`), func(rou Rou) {
+		rou.Use(func(hhan) hhan { return Str(`blocked`) }).ErrFunc(func(hrew, hreq) error {
+			panic(`unreachable`)
+		})
+	})
+	eq(t, nil, err)
+}
+
+/*
+Without middleware, serving a top-level handler must not box it into an
+interface: the only allocation is `Mut`.
+*/
+func TestRou_Use_allocs(t *testing.T) {
+	test := func(path string) {
+		t.Helper()
+		req := tReq(http.MethodPost, path)
+		eq(t, 1.0, testing.AllocsPerRun(64, func() {
+			try(MakeRou(NopRew{}, req).Route(tRouteAllocs))
+		}))
+	}
+
+	test(`/func`)
+	test(`/err`)
+	test(`/han`)
+}
+
+func tRouteAllocs(rou Rou) {
+	rou.Exa(`/func`).Post().Func(reachableFunc)
+	rou.Exa(`/err`).Post().ErrFunc(tNopErrFunc)
+	rou.Exa(`/han`).Post().Han(tNilHan)
+}
+
+func tNopErrFunc(hrew, hreq) error { return nil }
+func tNilHan(hreq) hhan            { return nil }
+
+func TestRou_Guard(t *testing.T) {
+	var guarded int
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Func(reachableFunc)
+
+		rou.Sta(`/admin`).Guard(func(req hreq) error {
+			guarded++
+			switch req.Header.Get(`Role`) {
+			case `admin`:
+				return nil
+			case ``:
+				return Unauthorized(req.Method, req.URL.Path)
+			default:
+				return io.EOF
+			}
+		}).Sub(func(rou Rou) {
+			rou.Exa(`/admin/one`).Get().Func(reachableFunc)
+		})
+	}
+
+	req := func(meth, path, role string) hreq {
+		out := tReq(meth, path)
+		out.Header = http.Header{`Role`: {role}}
+		return out
+	}
+
+	eq(t, 201, tStatus(req(http.MethodGet, `/one`, ``), route))
+	eq(t, 0, guarded)
+
+	eq(t, 201, tStatus(req(http.MethodGet, `/admin/one`, `admin`), route))
+	eq(t, 401, tStatus(req(http.MethodGet, `/admin/one`, ``), route))
+
+	_, err := tRoute(req(http.MethodGet, `/admin/one`, `user`), route)
+	eq(t, 403, ErrStatus(err))
+	eq(t, true, errors.Is(err, io.EOF))
+	eq(t, 3, guarded)
+
+	eq(t, 404, tStatus(req(http.MethodGet, `/admin/two`, ``), route))
+	eq(t, 405, tStatus(req(http.MethodPost, `/admin/one`, ``), route))
+	eq(t, 3, guarded)
+}
+
+func TestInterpolate(t *testing.T) {
+	names := []string{`id`, ``}
+	args := []string{`one two`, `three/four`}
+
+	eq(t, `/new`, interpolate(`/new`, names, args))
+	eq(t, `/new/one%20two`, interpolate(`/new/{id}`, names, args))
+	eq(t, `/new/one%20two/three/four`, interpolate(`/new/{0}/{1}`, names, args))
+	eq(t, `/new/{2}/{}/{-1}/{other}`, interpolate(`/new/{2}/{}/{-1}/{other}`, names, args))
+	eq(t, `/new/{id`, interpolate(`/new/{id`, names, args))
+}
+
+func TestRou_Redirect(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Pat(`/old/{id}`).Get().Redirect(http.StatusMovedPermanently, `/new/{id}`)
+		rou.Exa(`/temp`).Redirect(0, `/other`)
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/old/one`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusMovedPermanently, rew.Code)
+	eq(t, `/new/one`, rew.Header().Get(`Location`))
+
+	rew, err = tRoute(tReq(http.MethodPost, `/temp`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusFound, rew.Code)
+	eq(t, `/other`, rew.Header().Get(`Location`))
+
+	eq(t, 405, tStatus(tReq(http.MethodPost, `/old/one`), route))
+	eq(t, 404, tStatus(tReq(http.MethodGet, `/new/one`), route))
+}
+
+func TestResFS(t *testing.T) {
+	mod := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		`one.txt`:   {Data: []byte(`hello world`), ModTime: mod},
+		`two`:       {Data: []byte(`<!DOCTYPE html><p>two</p>`)},
+		`dir/three`: {Data: []byte(`three`)},
+	}
+
+	route := func(rou Rou) {
+		rou.Pat(`/fs/{}`).ParamRes(func(req hreq, args []string) *http.Response {
+			return ResRange(req, ResFS(fsys, args[0]))
+		})
+		rou.Pat(`/file/{}`).ETag(nil).ParamRes(func(_ hreq, args []string) *http.Response {
+			return ResFile(filepath.Join(`testdata`, args[0]))
+		})
+	}
+
+	test := func(expStatus int, expHead http.Header, expBody string, req hreq) {
+		t.Helper()
+		rew, err := tRoute(req, route)
+		if err != nil {
+			eq(t, expStatus, ErrStatus(err))
+			eq(t, true, errors.Is(err, ErrNotFoundBase))
+			eq(t, `[rout] routing error (HTTP status 404): no such file`, err.Error())
+			return
+		}
+		eq(t, expStatus, rew.Code)
+		eq(t, expBody, rew.Body.String())
+		for key := range expHead {
+			eq(t, expHead.Get(key), rew.Header().Get(key))
+		}
+	}
+
+	tag := `W/"` + strconv.FormatInt(mod.UnixNano(), 16) + `-b"`
+
+	test(
+		http.StatusOK,
+		http.Header{
+			`Content-Type`:   {`text/plain; charset=utf-8`},
+			`Content-Length`: {`11`},
+			`Last-Modified`:  {`Mon, 02 Jan 2006 15:04:05 GMT`},
+			`Etag`:           {tag},
+			`Accept-Ranges`:  {`bytes`},
+		},
+		`hello world`,
+		tReq(http.MethodGet, `/fs/one.txt`),
+	)
+
+	req := tReq(http.MethodGet, `/fs/one.txt`)
+	req.Header = http.Header{`Range`: {`bytes=6-`}}
+	test(
+		http.StatusPartialContent,
+		http.Header{`Content-Range`: {`bytes 6-10/11`}, `Content-Length`: {`5`}},
+		`world`,
+		req,
+	)
+
+	test(
+		http.StatusOK,
+		http.Header{`Content-Type`: {`text/html; charset=utf-8`}, `Last-Modified`: nil, `Etag`: nil},
+		`<!DOCTYPE html><p>two</p>`,
+		tReq(http.MethodGet, `/fs/two`),
+	)
+
+	test(http.StatusNotFound, nil, ``, tReq(http.MethodGet, `/fs/three`))
+	test(http.StatusNotFound, nil, ``, tReq(http.MethodGet, `/fs/dir`))
+	test(http.StatusNotFound, nil, ``, tReq(http.MethodGet, `/fs/..`))
+	test(http.StatusNotFound, nil, ``, tReq(http.MethodGet, `/file/missing.txt`))
+
+	path := filepath.Join(t.TempDir(), `one.json`)
+	try(os.WriteFile(path, []byte(`{}`), os.ModePerm))
+	res := ResFile(path)
+	defer res.Body.Close()
+	eq(t, `application/json`, res.Header.Get(`Content-Type`))
+	eq(t, int64(2), res.ContentLength)
+	_, ok := res.Body.(*os.File)
+	eq(t, true, ok)
+
+	req = tReq(http.MethodGet, `/fs/one.txt`)
+	req.Header = http.Header{`If-None-Match`: {tag}}
+	rew, err := tRoute(req, func(rou Rou) {
+		rou.ETag(nil).Exa(`/fs/one.txt`).Res(func(hreq) *http.Response { return ResFS(fsys, `one.txt`) })
+	})
+	try(err)
+	eq(t, http.StatusNotModified, rew.Code)
+}
+
+func TestRou_Static(t *testing.T) {
+	fsys := fstest.MapFS{
+		`one.txt`:             {Data: []byte(`one`)},
+		`dir/index.html`:      {Data: []byte(`<p>index</p>`)},
+		`dir/two.txt`:         {Data: []byte(`two`)},
+		`empty/three.txt`:     {Data: []byte(`three`)},
+		`nested/index.html/x`: {Data: []byte(`x`)},
+	}
+
+	route := func(rou Rou) {
+		rou.Static(`/static`, fsys)
+		rou.Exa(`/other`).Handler(Str(`other`))
+		rou.Exa(`/staticdir`).Handler(Str(`fallthrough`))
+	}
+
+	test := func(expStatus int, expBody string, meth, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(meth, path), route)
+		if err != nil {
+			eq(t, expStatus, ErrStatus(err))
+			return
+		}
+		eq(t, expStatus, rew.Code)
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(200, `one`, http.MethodGet, `/static/one.txt`)
+	test(200, ``, http.MethodHead, `/static/one.txt`)
+	test(200, `two`, http.MethodGet, `/static/dir/two.txt`)
+	test(200, `<p>index</p>`, http.MethodGet, `/static/dir`)
+	test(200, `<p>index</p>`, http.MethodGet, `/static/dir/`)
+	test(200, `one`, http.MethodGet, `/static/dir/../one.txt`)
+	test(404, ``, http.MethodGet, `/static/../static/one.txt`)
+	test(404, ``, http.MethodGet, `/static`)
+	test(404, ``, http.MethodGet, `/static/missing.txt`)
+	test(404, ``, http.MethodGet, `/static/empty`)
+	test(404, ``, http.MethodGet, `/static/nested`)
+	test(404, ``, http.MethodGet, `/staticx/one.txt`)
+	test(404, ``, http.MethodGet, `/staticone.txt`)
+	test(404, ``, http.MethodPost, `/staticone.txt`)
+	test(405, ``, http.MethodPost, `/static/one.txt`)
+	test(200, `other`, http.MethodGet, `/other`)
+	test(200, `fallthrough`, http.MethodGet, `/staticdir`)
+
+	rew, err := tRoute(tReq(http.MethodGet, `/static/one.txt`), route)
+	eq(t, nil, err)
+	eq(t, `text/plain; charset=utf-8`, rew.Header().Get(`Content-Type`))
+
+	_, err = tRoute(tReq(http.MethodGet, `/static/missing.txt`), route)
+	eq(t, true, errors.As(err, new(ErrRoute)))
+	eq(t, true, errors.Is(err, ErrNotFoundBase))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/static/one.txt`), func(rou Rou) {
+		rou.Static(`/static/`, fsys)
+	})
+	eq(t, nil, err)
+	eq(t, `one`, rew.Body.String())
+}
+
+func TestRou_OnErr(t *testing.T) {
+	onErr := func(rew hrew, _ hreq, err error) {
+		rew.WriteHeader(ErrStatusFallback(err))
+		_, _ = io.WriteString(rew, `caught: `+err.Error())
+	}
+
+	route := func(rou Rou) {
+		rou.Sta(`/api`).OnErr(onErr).Sub(func(rou Rou) {
+			rou.Exa(`/api/one`).Get().Func(reachableFunc)
+			rou.Exa(`/api/two`).ErrFunc(func(hrew, hreq) error { return io.EOF })
+			rou.Exa(`/api/three`).Methods(func(rou Rou) {
+				rou.Get().Func(reachableFunc)
+			})
+		})
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+	}
+
+	test := func(expStatus int, expBody string, meth, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(meth, path), route)
+		eq(t, nil, err)
+		eq(t, expStatus, rew.Code)
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(201, ``, http.MethodGet, `/api/one`)
+	test(405, `caught: `+MethodNotAllowed(http.MethodPost, `/api/one`).Error(), http.MethodPost, `/api/one`)
+	test(500, `caught: EOF`, http.MethodGet, `/api/two`)
+	test(405, `caught: `+MethodNotAllowed(http.MethodPost, `/api/three`).Error(), http.MethodPost, `/api/three`)
+	test(404, `caught: `+NotFound(http.MethodGet, `/api/four`).Error(), http.MethodGet, `/api/four`)
+
+	_, err := tRoute(tReq(http.MethodPost, `/one`), route)
+	errs(t, `method not allowed`, err)
+
+	_, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	errs(t, `no such endpoint`, err)
+}
+
+func TestRou_Try(t *testing.T) {
+	routes := func(rou Rou) (bool, error) {
+		if ok, err := rou.Exa(`/one`).Get().TryHandler(Str(`one`)); ok || err != nil {
+			return ok, err
+		}
+		if ok, err := rou.Exa(`/two`).TryFunc(func(hrew, hreq) error { return io.EOF }); ok || err != nil {
+			return ok, err
+		}
+		if ok, err := rou.Pat(`/three/{}`).TryParamFunc(func(rew hrew, _ hreq, args []string) error {
+			_, err := io.WriteString(rew, args[0])
+			return err
+		}); ok || err != nil {
+			return ok, err
+		}
+		if ok, err := rou.Exa(`/four`).Guard(func(hreq) error {
+			return Forbidden(``, ``)
+		}).TryHandler(Str(`four`)); ok || err != nil {
+			return ok, err
+		}
+		return false, nil
+	}
+
+	test := func(expOk bool, expBody string, expErr error, meth, path string) {
+		t.Helper()
+		rew := ht.NewRecorder()
+		ok, err := routes(MakeRou(rew, tReq(meth, path)))
+		eq(t, expOk, ok)
+		eq(t, expErr, err)
+		eq(t, expBody, rew.Body.String())
+	}
+
+	test(true, `one`, nil, http.MethodGet, `/one`)
+	test(
+		false, ``,
+		ErrRoute{
+			Status: http.StatusMethodNotAllowed,
+			Method: http.MethodPost,
+			Path:   `/one`,
+			Msg:    `method not allowed`,
+			Allow:  `GET, HEAD`,
+		},
+		http.MethodPost, `/one`,
+	)
+	test(true, ``, io.EOF, http.MethodGet, `/two`)
+	test(true, `four`, nil, http.MethodGet, `/three/four`)
+	test(true, ``, Forbidden(``, ``), http.MethodGet, `/four`)
+	test(false, ``, nil, http.MethodGet, `/five`)
+
+	ok, err := Rou{}.TryHandler(nil)
+	eq(t, false, ok)
+	eq(t, ErrInit, err)
+}
+
+func TestRou_Try_serve(t *testing.T) {
+	const text = `hello world hello world hello world`
+
+	write := func(rew hrew, _ hreq) error {
+		_, err := io.WriteString(rew, text)
+		return err
+	}
+
+	t.Run(`head`, func(t *testing.T) {
+		rew := ht.NewRecorder()
+		ok, err := MakeRou(rew, tReq(http.MethodHead, `/`)).Exa(`/`).Get().TryFunc(write)
+		eq(t, true, ok)
+		eq(t, nil, err)
+		eq(t, ``, rew.Body.String())
+
+		rew = ht.NewRecorder()
+		ok, err = MakeRou(rew, tReq(http.MethodHead, `/one`)).Pat(`/{}`).Get().TryParamFunc(
+			func(rew hrew, req hreq, _ []string) error { return write(rew, req) },
+		)
+		eq(t, true, ok)
+		eq(t, nil, err)
+		eq(t, ``, rew.Body.String())
+	})
+
+	t.Run(`compress`, func(t *testing.T) {
+		req := tReq(http.MethodGet, `/`)
+		req.Header = http.Header{`Accept-Encoding`: {`gzip`}}
+		rew := ht.NewRecorder()
+
+		ok, err := MakeRou(rew, req).Compress(Gzip{}).Exa(`/`).TryFunc(write)
+		eq(t, true, ok)
+		eq(t, nil, err)
+		eq(t, `gzip`, rew.Header().Get(`Content-Encoding`))
+
+		src, err := gzip.NewReader(rew.Body)
+		try(err)
+		out, err := io.ReadAll(src)
+		try(err)
+		eq(t, text, string(out))
+	})
+
+	t.Run(`etag`, func(t *testing.T) {
+		rew := ht.NewRecorder()
+		ok, err := MakeRou(rew, tReq(http.MethodGet, `/`)).ETag(nil).Exa(`/`).TryFunc(write)
+		eq(t, true, ok)
+		eq(t, nil, err)
+		eq(t, text, rew.Body.String())
+
+		tag := rew.Header().Get(`Etag`)
+		notEq(t, ``, tag)
+
+		req := tReq(http.MethodGet, `/`)
+		req.Header = http.Header{`If-None-Match`: {tag}}
+		rew = ht.NewRecorder()
+		ok, err = MakeRou(rew, req).ETag(nil).Exa(`/`).TryFunc(write)
+		eq(t, true, ok)
+		eq(t, nil, err)
+		eq(t, http.StatusNotModified, rew.Code)
+		eq(t, ``, rew.Body.String())
+	})
+
+	t.Run(`error`, func(t *testing.T) {
+		rew := ht.NewRecorder()
+		ok, err := MakeRou(rew, tReq(http.MethodGet, `/`)).ETag(nil).Exa(`/`).TryFunc(
+			func(rew hrew, _ hreq) error {
+				_, _ = io.WriteString(rew, text)
+				return io.EOF
+			},
+		)
+		eq(t, true, ok)
+		eq(t, io.EOF, err)
+		eq(t, ``, rew.Body.String())
+	})
+
+	t.Run(`recover`, func(t *testing.T) {
+		ok, err := MakeRou(ht.NewRecorder(), tReq(http.MethodGet, `/`)).Try().Exa(`/`).TryFunc(
+			func(hrew, hreq) error { panic(`one`) },
+		)
+		eq(t, true, ok)
+		val, isPanic := err.(ErrPanic)
+		eq(t, true, isPanic)
+		eq(t, `one`, val.Val)
+
+		panics(t, `two`, func() {
+			_, _ = MakeRou(ht.NewRecorder(), tReq(http.MethodGet, `/`)).Exa(`/`).TryFunc(
+				func(hrew, hreq) error { panic(`two`) },
+			)
+		})
+	})
+}
+
+func TestRou_init(t *testing.T) {
+	route := func(rou Rou) { rou.Exa(`/one`).Get().Func(reachableFunc) }
+	req := tReq(http.MethodGet, `/one`)
+
+	eq(t, ErrInit, Rou{}.Route(route))
+	eq(t, ErrInit, Rou{Req: req}.Route(route))
+	eq(t, ErrInit, Rou{Req: req, Mut: new(Mut)}.Route(route))
+	eq(t, ErrInit, Rou{Rew: NopRew{}, Mut: new(Mut)}.Route(route))
+
+	ok, err := Rou{Req: req, Mut: new(Mut)}.TryFunc(nil)
+	eq(t, false, ok)
+	eq(t, ErrInit, err)
+
+	Visit(route, VisitorFunc(func(Endpoint) {}))
+}
+
+func TestRou_Route_panic_nil(t *testing.T) {
+	_, err := tRoute(tReq(http.MethodGet, `/one`), func(rou Rou) {
+		rou.Exa(`/one`).Func(func(hrew, hreq) { panic(nil) })
+	})
+	eq(t, true, err != nil)
+	eq(t, false, errors.Is(err, ErrNotFound(``)))
+
+	var caught error
+	_, err = tRoute(tReq(http.MethodGet, `/one`), func(rou Rou) {
+		rou.OnErr(func(_ hrew, _ hreq, err error) { caught = err }).Group(func(rou Rou) {
+			rou.Exa(`/one`).Func(func(hrew, hreq) { panic(nil) })
+		})
+	})
+	eq(t, nil, err)
+	eq(t, true, caught != nil)
+
+	eq(t, ErrPanicNil, recErr(nil, false))
+	eq(t, nil, recErr(nil, true))
+	eq(t, io.EOF, recErr(io.EOF, false))
+}
+
+func TestRou_Handle(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Handle(http.MethodGet, `/one`, Str(`one`))
+		rou.Handle(http.MethodGet, `/two`, func(rew hrew, _ hreq) { _, _ = io.WriteString(rew, `two`) })
+		rou.Handle(http.MethodGet, `/three/{}`, func(_ hreq, args []string) hhan { return Str(args[0]) })
+		rou.Handle(http.MethodGet, `/four/{id}`, func(_ hreq, args map[string]string) hhan { return Str(args[`id`]) })
+		rou.Handle(``, `/five/{}`, func(rou Rou) {
+			rou.Pat(`/five/six`).Get().Handler(Str(`six`))
+		})
+		rou.Handle(http.MethodGet, `/seven`, func(hreq) *http.Response {
+			return &http.Response{Body: io.NopCloser(strings.NewReader(`seven`))}
+		})
+		rou.Handle(http.MethodGet, `/eight`, func(hrew, hreq) error { return io.EOF })
+	}
+
+	test := func(exp string, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(http.MethodGet, path), route)
+		eq(t, nil, err)
+		eq(t, exp, rew.Body.String())
+	}
+
+	test(`one`, `/one`)
+	test(`two`, `/two`)
+	test(`three`, `/three/three`)
+	test(`four`, `/four/four`)
+	test(`six`, `/five/six`)
+	test(`seven`, `/seven`)
+
+	_, err := tRoute(tReq(http.MethodGet, `/eight`), route)
+	eq(t, io.EOF, err)
+
+	eq(t, 405, tStatus(tReq(http.MethodPost, `/one`), route))
+
+	_, err = tRoute(tReq(http.MethodGet, `/one`), func(rou Rou) {
+		rou.Handle(http.MethodGet, `/one`, `one`)
+	})
+	errs(t, `unsupported handler type string for route "GET" "/one"`, err)
+}
+
+func TestJoin(t *testing.T) {
+	var trace []string
+
+	route := Join(
+		func(rou Rou) {
+			trace = append(trace, `one`)
+			rou.Exa(`/one`).Handler(Str(`one`))
+		},
+		nil,
+		func(rou Rou) {
+			trace = append(trace, `two`)
+			rou.Exa(`/two`).Handler(Str(`two`))
+		},
+	)
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, `one`, rew.Body.String())
+	eq(t, []string{`one`}, trace)
+
+	trace = nil
+	rew, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, nil, err)
+	eq(t, `two`, rew.Body.String())
+	eq(t, []string{`one`, `two`}, trace)
+
+	trace = nil
+	_, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	errs(t, `no such endpoint`, err)
+	eq(t, []string{`one`, `two`}, trace)
+
+	var visited []string
+	Visit(route, VisitorFunc(func(val Endpoint) { visited = append(visited, val.Pattern) }))
+	eq(t, []string{`/one`, `/two`}, visited)
+}
+
+func TestRou_Try_panic(t *testing.T) {
+	route := func(rou Rou) {
+		rou = rou.Try()
+		rou.Exa(`/one`).Func(func(hrew, hreq) { panic(`one`) })
+		rou.Exa(`/two`).ErrFunc(func(hrew, hreq) error { return io.EOF })
+		rou.Exa(`/three`).Func(func(hrew, hreq) {
+			var val *Str
+			_ = *val
+		})
+		rou.Exa(`/four`).Func(reachableFunc)
+		rou.Exa(`/six`).Func(func(hrew, hreq) { panic(errTryPanic) })
+		rou.Exa(`/seven`).Func(func(hrew, hreq) { panic(http.ErrAbortHandler) })
+		rou.Exa(`/eight`).Func(func(hrew, hreq) { try(io.EOF) })
+	}
+
+	_, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	val, ok := err.(ErrPanic)
+	eq(t, true, ok)
+	eq(t, `one`, val.Val)
+	eq(t, `[rout] panic in handler: one`, val.Error())
+	eq(t, true, bytes.Contains(val.Stack, []byte(`TestRou_Try`)))
+	eq(t, 500, ErrStatus(err))
+
+	_, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, io.EOF, err)
+
+	_, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	_, ok = err.(ErrPanic)
+	eq(t, true, ok)
+	errs(t, `nil pointer dereference`, err)
+
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/four`), route))
+	eq(t, 404, tStatus(tReq(http.MethodGet, `/five`), route))
+
+	_, err = tRoute(tReq(http.MethodGet, `/six`), route)
+	val, ok = err.(ErrPanic)
+	eq(t, true, ok)
+	eq(t, errTryPanic, val.Val)
+	eq(t, true, errors.Is(err, errTryPanic))
+	eq(t, true, bytes.Contains(val.Stack, []byte(`TestRou_Try`)))
+	eq(t, 500, ErrStatus(err))
+
+	_, err = tRoute(tReq(http.MethodGet, `/seven`), route)
+	eq(t, http.ErrAbortHandler, err)
+
+	_, err = tRoute(tReq(http.MethodGet, `/eight`), route)
+	eq(t, io.EOF, err)
+}
+
+var errTryPanic = errors.New(`plain error`)
+
+func TestRou_SetHeader(t *testing.T) {
+	route := func(rou Rou) {
+		rou = rou.SetHeader(`X-One`, `one`)
+		rou.Exa(`/one`).Func(reachableFunc)
+		rou.Sta(`/two`).SetHeader(`X-Two`, `two`).SetHeader(`X-One`, `three`).Sub(func(rou Rou) {
+			rou.Exa(`/two`).Func(func(rew hrew, _ hreq) { rew.Header().Set(`X-Two`, `four`) })
+			rou.Exa(`/two/guarded`).Guard(func(hreq) error { return Forbidden(``, ``) }).Func(reachableFunc)
+		})
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, http.Header{`X-One`: {`one`}}, rew.Header())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, nil, err)
+	eq(t, http.Header{`X-One`: {`three`}, `X-Two`: {`four`}}, rew.Header())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two/guarded`), route)
+	eq(t, 403, ErrStatus(err))
+	eq(t, `two`, rew.Header().Get(`X-Two`))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	errs(t, `no such endpoint`, err)
+	eq(t, http.Header{}, rew.Header())
+}
+
+func TestRou_Cache(t *testing.T) {
+	route := func(rou Rou) {
+		rou = rou.Cache(time.Hour+time.Millisecond, `public`)
+		rou.Exa(`/one`).Func(reachableFunc)
+		rou.Exa(`/two`).Cache(-1, `no-store`).Func(reachableFunc)
+		rou.Exa(`/three`).Cache(0).Func(reachableFunc)
+		rou.Exa(`/four`).Cache(-1).Func(reachableFunc)
+		rou.Exa(`/five`).Cache(time.Minute, `private`, ``, `must-revalidate`).Func(reachableFunc)
+		rou.Exa(`/six`).Func(func(rew hrew, _ hreq) { rew.Header().Set(`Cache-Control`, `no-cache`) })
+	}
+
+	test := func(exp, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(http.MethodGet, path), route)
+		try(err)
+		eq(t, exp, rew.Header().Get(`Cache-Control`))
+	}
+
+	test(`max-age=3600, public`, `/one`)
+	test(`no-store`, `/two`)
+	test(`max-age=0`, `/three`)
+	test(`max-age=3600, public`, `/four`)
+	test(`max-age=60, private, must-revalidate`, `/five`)
+	test(`no-cache`, `/six`)
+}
+
+func TestRou_Name(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Name(`one`).Desc(`first`).Get().Func(reachableFunc)
+		rou.Exa(`/two`).Name(`two`).Methods(func(rou Rou) {
+			rou.Get().Func(reachableFunc)
+			rou.Post().Name(`twoPost`).Func(reachableFunc)
+		})
+		rou.Sta(`/three`).Name(`three`).Sub(func(rou Rou) {
+			rou.Exa(`/three/four`).Func(reachableFunc)
+		})
+	}
+
+	var visited [][3]string
+	Visit(route, InfoVisitorFunc(func(val EndpointInfo) {
+		visited = append(visited, [3]string{val.Pattern + ` ` + val.Method, val.Name, val.Desc})
+	}))
+
+	eq(
+		t,
+		[][3]string{
+			{`/one GET`, `one`, `first`},
+			{`/two GET`, `two`, ``},
+			{`/two POST`, `twoPost`, ``},
+			{`/three/four `, ``, ``},
+		},
+		visited,
+	)
+
+	// Plain visitors don't receive annotations.
+	visited = nil
+	Visit(route, VisitorFunc(InfoVisitorFunc(func(val EndpointInfo) {
+		visited = append(visited, [3]string{val.Pattern + ` ` + val.Method, val.Name, val.Desc})
+	}).Endpoint))
+	eq(t, [3]string{`/one GET`, ``, ``}, visited[0])
+}
+
+func TestEndpoints(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Pat(`/two/{}`).Post().Handler(Str(`two`))
+	}
+
+	eq(
+		t,
+		[]EndpointInfo{
+			{Endpoint: Endpoint{`/one`, MatchExa, http.MethodGet, Ident(reachableFunc)}, HandlerName: `rout.reachableFunc`},
+			{Endpoint: Endpoint{`/two/{}`, MatchPat, http.MethodPost, Ident(Str(`two`))}, HandlerName: `rout.Str`},
+		},
+		Endpoints(route),
+	)
+	eq(t, []EndpointInfo(nil), Endpoints(func(Rou) {}))
+
+	names := func(fun func(Rou)) (out []string) {
+		for _, val := range Endpoints(fun) {
+			out = append(out, val.HandlerName)
+		}
+		return
+	}
+	eq(
+		t,
+		[]string{`rout.reachableFunc`, `rout.reachableFunc`, `rout.unreachableHan`, ``, `rout.StatusOnly`},
+		names(func(rou Rou) {
+			rou.Exa(`/one`).Meths(http.MethodGet, http.MethodPut).Func(reachableFunc)
+			rou.Exa(`/two`).Han(unreachableHan)
+			rou.Exa(`/three`).Han(nil)
+			rou.Exa(`/four`).Handler(StatusOnly(http.StatusNoContent))
+		}),
+	)
+
+	rou := MakeRou(NopRew{}, tReq(http.MethodGet, `/one`))
+	try(rou.Route(route))
+	end, ok := rou.Matched()
+	eq(t, true, ok)
+	eq(t, Endpoint{`/one`, MatchExa, http.MethodGet, Ident(reachableFunc)}, end)
+	eq(t, `rout.reachableFunc`, IdentName(end.Handler))
+
+	// Closure names are generated by the compiler and vary between versions.
+	name := names(func(rou Rou) { rou.Exa(``).Func(func(hrew, hreq) {}) })[0]
+	eq(t, true, strings.HasPrefix(name, `rout.TestEndpoints.func`))
+}
+
+func TestVisitReq(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		if rou.Req.Host == `admin.example.com` {
+			rou.Exa(`/admin`).Get().Func(reachableFunc)
+		}
+		if rou.Req.Header.Get(`X-Beta`) != `` {
+			rou.Exa(`/beta`).Get().Func(reachableFunc)
+		}
+	}
+
+	visit := func(reqs ...*http.Request) (out []string) {
+		VisitReq(route, VisitorFunc(func(val Endpoint) {
+			out = append(out, val.Pattern)
+		}), reqs...)
+		return
+	}
+
+	eq(t, []string{`/one`}, visit())
+	eq(t, []string{`/one`}, visit(nil))
+
+	admin := ht.NewRequest(http.MethodGet, `https://admin.example.com`, nil)
+	beta := &http.Request{Header: http.Header{`X-Beta`: {`1`}}}
+
+	eq(t, []string{`/one`, `/admin`}, visit(admin))
+	eq(t, []string{`/one`, `/beta`}, visit(beta))
+	eq(t, []string{`/one`, `/one`, `/admin`, `/one`, `/beta`}, visit(nil, admin, beta))
+	eq(t, (*url.URL)(nil), beta.URL)
+}
+
+func TestEndpointInfo_Parents(t *testing.T) {
+	vals := Endpoints(func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Sta(`/api`).Sub(func(rou Rou) {
+			rou.Exa(`/api/two`).Get().Func(reachableFunc)
+			rou.Pat(`/api/three/{}`).Methods(func(rou Rou) {
+				rou.Get().Func(reachableFunc)
+				rou.Post().Func(reachableFunc)
+			})
+		})
+		rou.Group(func(rou Rou) { rou.Exa(`/four`).Func(reachableFunc) })
+	})
+
+	parents := func(val EndpointInfo) (out []string) {
+		for _, val := range val.Parents() {
+			out = append(out, val.Match.String()+` `+val.Pattern)
+		}
+		return
+	}
+
+	eq(t, 5, len(vals))
+
+	eq(t, []string(nil), parents(vals[0]))
+	eq(t, 0, vals[0].Depth())
+
+	eq(t, []string{`sta /api`}, parents(vals[1]))
+	eq(t, 1, vals[1].Depth())
+
+	eq(t, []string{`sta /api`, `pat /api/three/{}`}, parents(vals[2]))
+	eq(t, 2, vals[2].Depth())
+	eq(t, true, vals[2].Parent == vals[3].Parent)
+	eq(t, true, vals[1].Parent == vals[2].Parent.Parent)
+
+	eq(t, []string{`exa `}, parents(vals[4]))
+
+	eq(t, []Scope(nil), EndpointInfo{}.Parents())
+	eq(t, 0, EndpointInfo{}.Depth())
+}
+
+func TestConflicts(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Pat(`/a/{}`).Get().Func(reachableFunc)
+		rou.Reg(`^/a/b$`).Get().Func(reachableFunc)
+		rou.Reg(`^/a/c$`).Post().Func(reachableFunc)
+		rou.Exa(`/b`).Get().Func(reachableFunc)
+		rou.Col(`/b/:id`).Get().Func(reachableFunc)
+		rou.Sta(`/c`).Func(reachableFunc)
+		rou.Exa(`/c/d`).Put().Func(reachableFunc)
+		rou.Reg(`^/(e|f)/\d+$`).Get().Func(reachableFunc)
+		rou.Mux(`/f/{id}`).Get().Func(reachableFunc)
+	}
+
+	vals := Conflicts(route)
+	eq(t, 3, len(vals))
+
+	eq(t, [2]string{`/a/{}`, `^/a/b$`}, [2]string{vals[0].A.Pattern, vals[0].B.Pattern})
+	eq(t, `/a/b`, vals[0].Path)
+
+	eq(t, [2]string{`/c`, `/c/d`}, [2]string{vals[1].A.Pattern, vals[1].B.Pattern})
+	eq(t, `/c/d`, vals[1].Path)
+
+	eq(t, [2]string{`^/(e|f)/\d+$`, `/f/{id}`}, [2]string{vals[2].A.Pattern, vals[2].B.Pattern})
+	eq(t, `/f/1`, vals[2].Path)
+
+	eq(
 		t,
-		[]Endpoint{
-			{`^/one/exa$`, MatchReg, http.MethodPost, Ident(hanExa)},
-			{`^/two/sta`, MatchReg, http.MethodPost, Ident(hanSta)},
-			{`^/three/reg/([^/]+)$`, MatchReg, http.MethodPost, Ident(hanReg)},
-			{`^/four/pat/([^/?#]+)$`, MatchReg, http.MethodPost, Ident(hanPat)},
+		`[rout] route "GET" pat "/a/{}" overlaps with earlier route "" pat "/a/{}"; example path: "/a/x"`,
+		Conflict{
+			A:    Endpoint{Pattern: `/a/{}`, Match: MatchPat},
+			B:    Endpoint{Pattern: `/a/{}`, Match: MatchPat, Method: http.MethodGet},
+			Path: `/a/x`,
+		}.Error(),
+	)
+
+	err := CheckConflicts(route)
+	errs(t, `example path: "/c/d"`, err)
+	var conflict Conflict
+	eq(t, true, errors.As(err, &conflict))
+	eq(t, vals[0].Path, conflict.Path)
+
+	try(CheckConflicts(func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Exa(`/one`).Post().Func(reachableFunc)
+		rou.Pat(`/one/{}`).Get().Func(reachableFunc)
+		rou.Reg(`^/two/\d+$`).Get().Func(reachableFunc)
+		rou.Exa(`/two/new`).Get().Func(reachableFunc)
+	}))
+
+	eq(t, 2, len(Conflicts(func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Exa(``).Func(reachableFunc)
+		rou.Sta(`/two`).Post().Func(reachableFunc)
+	})))
+}
+
+func TestGen(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Sta(`/api`).Sub(func(rou Rou) {
+			rou.Pat(`/api/two/{}`).Post().ParamFunc(tParamFunc)
+			rou.Reg("^/api/`three`$").Func(reachableFunc)
+		})
+	}
+
+	src, err := Gen{Package: `rout`, Var: `tDispatch`}.Source(route)
+	try(err)
+	eq(
+		t,
+		"// Code generated by rout.Gen. DO NOT EDIT.\n\n"+
+			"package rout\n\n"+
+			"import \"github.com/mitranim/rout\"\n\n"+
+			"var tDispatch = rout.MakeDispatch(\n"+
+			"\trout.DispatchEntry{Method: `GET`, Match: rout.MatchExa, Pattern: `/one`, Func: reachableFunc},\n"+
+			"\trout.DispatchEntry{Method: `POST`, Match: rout.MatchPat, Pattern: `/api/two/{}`, ParamFunc: tParamFunc},\n"+
+			"\trout.DispatchEntry{Match: rout.MatchReg, Pattern: \"^/api/`three`$\", Func: reachableFunc},\n"+
+			")\n",
+		string(src),
+	)
+
+	src, err = Gen{Package: `rout`}.Source(func(Rou) {})
+	try(err)
+	eq(t, true, strings.Contains(string(src), "var Dispatch = rout.MakeDispatch()\n"))
+
+	test := func(msg string, gen Gen, fun func(Rou)) {
+		t.Helper()
+		_, err := gen.Source(fun)
+		errs(t, msg, err)
+	}
+
+	test(`invalid package name ""`, Gen{}, route)
+	test(`invalid variable name "one two"`, Gen{Package: `rout`, Var: `one two`}, route)
+	test(`handler "rout.reachableFunc" is not a top-level function in package "main"`, Gen{Package: `main`}, route)
+	test(`unsupported handler type func(*http.Request) http.Handler`, Gen{Package: `rout`}, func(rou Rou) {
+		rou.Exa(`/one`).Han(unreachableHan)
+	})
+	test(`is not a top-level function in package "rout"`, Gen{Package: `rout`}, func(rou Rou) {
+		rou.Exa(`/one`).Func(func(hrew, hreq) {})
+	})
+}
+
+func TestDispatch(t *testing.T) {
+	name := func(val string) Func {
+		return func(rew hrew, _ hreq) { _, _ = io.WriteString(rew, val) }
+	}
+
+	disp := MakeDispatch(
+		DispatchEntry{Method: http.MethodGet, Match: MatchPat, Pattern: `/a/{}`, Func: name(`a`)},
+		DispatchEntry{Method: http.MethodGet, Match: MatchReg, Pattern: `^/a/b$`, Func: name(`b`)},
+		DispatchEntry{Method: http.MethodGet, Match: MatchExa, Pattern: `/c`, Func: name(`c`)},
+		DispatchEntry{Match: MatchSta, Pattern: `/c`, Func: name(`d`)},
+		DispatchEntry{Method: http.MethodPost, Match: MatchMux, Pattern: `/e/{id}/{rest...}`, ParamFunc: tParamFunc},
+		DispatchEntry{Match: MatchExa, Pattern: `/f`, Func: name(`f0`)},
+		DispatchEntry{Method: http.MethodGet, Match: MatchExa, Pattern: `/f`, Func: name(`f1`)},
+		DispatchEntry{Method: http.MethodGet, Match: MatchCol, Pattern: `/g/:id/h`, ParamFunc: tParamFunc},
+		DispatchEntry{Method: http.MethodGet, Match: MatchPat, Pattern: `/g/one/h`, Func: name(`g`)},
+	)
+
+	test := func(exp string, meth, path string) {
+		t.Helper()
+		rew := ht.NewRecorder()
+		disp.ServeHTTP(rew, tReq(meth, path))
+		eq(t, exp, rew.Body.String())
+	}
+
+	test(`a`, http.MethodGet, `/a/one`)
+	test(`a`, http.MethodGet, `/a/b`)
+	test(`c`, http.MethodGet, `/c`)
+	test(`c`, http.MethodHead, `/c`)
+	test(`d`, http.MethodPost, `/c`)
+	test(`d`, http.MethodGet, `/c/y`)
+	test(`one,two/three`, http.MethodPost, `/e/one/two/three`)
+	test(`f0`, http.MethodGet, `/f`)
+	test(`one`, http.MethodGet, `/g/one/h`)
+
+	rew := ht.NewRecorder()
+	disp.ServeHTTP(rew, tReq(http.MethodPost, `/a/one`))
+	eq(t, http.StatusNotFound, rew.Code)
+
+	eq(t, false, disp.Serve(NopRew{}, tReq(http.MethodGet, `/e/one/two`)))
+	eq(t, false, (*Dispatch)(nil).Serve(NopRew{}, tReq(http.MethodGet, `/a/one`)))
+}
+
+func TestTS(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/`).Name(`index`).Get().Func(reachableFunc)
+		rou.Pat(`/articles/{id}`).Name(`articleGet`).Desc(`Returns an article.`).Get().Func(reachableFunc)
+		rou.Pat(`/articles/{id}`).Name(`articleGet`).Post().Func(reachableFunc)
+		rou.Mux(`/files/{dir}/{path...}`).Name(`file`).Func(reachableFunc)
+		rou.Col(`/users/:default/posts/:id`).Name(`userPost`).Func(reachableFunc)
+		rou.Exa(`/unnamed`).Func(reachableFunc)
+	}
+
+	src, err := TS{}.Source(route)
+	try(err)
+	eq(
+		t,
+		"// Code generated by rout.TS. DO NOT EDIT.\n\n"+
+			"export type Param = string | number\n\n"+
+			"/** GET / */\n"+
+			"export function index(): string {\n\treturn `/`\n}\n\n"+
+			"/** GET /articles/{id}. Returns an article. */\n"+
+			"export function articleGet(id: Param): string {\n\treturn `/articles/${enc(id)}`\n}\n\n"+
+			"/** /files/{dir}/{path...} */\n"+
+			"export function file(dir: Param, path: Param): string {\n\treturn `/files/${enc(dir)}/${encRest(path)}`\n}\n\n"+
+			"/** /users/:default/posts/:id */\n"+
+			"export function userPost(arg0: Param, id: Param): string {\n\treturn `/users/${enc(arg0)}/posts/${enc(id)}`\n}\n\n"+
+			"export const routes = {\n"+
+			"\tindex: {method: `GET`, pattern: `/`, path: index},\n"+
+			"\tarticleGet: {method: `GET`, pattern: `/articles/{id}`, path: articleGet},\n"+
+			"\tfile: {method: ``, pattern: `/files/{dir}/{path...}`, path: file},\n"+
+			"\tuserPost: {method: ``, pattern: `/users/:default/posts/:id`, path: userPost},\n"+
+			"} as const\n\n"+
+			"function enc(val: Param): string {return encodeURIComponent(String(val))}\n\n"+
+			"function encRest(val: Param): string {return String(val).split(`/`).map(encodeURIComponent).join(`/`)}\n",
+		string(src),
+	)
+
+	src, err = TS{JS: true}.Source(route)
+	try(err)
+	eq(t, true, strings.Contains(string(src), "export function file(dir, path) {\n"))
+	eq(t, true, strings.Contains(string(src), "function enc(val) {"))
+	eq(t, false, strings.Contains(string(src), `Param`))
+
+	src, err = TS{}.Source(func(rou Rou) {
+		rou.Exa("/a`b${c}").Name(`odd`).Func(reachableFunc)
+	})
+	try(err)
+	eq(t, true, strings.Contains(string(src), "return `/a\\`b\\${c}`"))
+
+	_, err = TS{}.Source(func(rou Rou) {
+		rou.Reg(`^/one$`).Name(`one`).Func(reachableFunc)
+	})
+	errs(t, `unable to generate URL builder for route "one": unsupported match "reg"`, err)
+
+	_, err = TS{}.Source(func(rou Rou) {
+		rou.Exa(`/one`).Name(`one-two`).Func(reachableFunc)
+	})
+	errs(t, `route name "one-two" is not a valid JS identifier`, err)
+}
+
+func TestPostman(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Pat(`/articles/{id}`).Name(`articleGet`).Desc(`Returns an article.`).Get().Func(reachableFunc)
+		rou.Pat(`/articles/{}/v{version}`).Deprecated(time.Time{}, ``).Post().Func(reachableFunc)
+		rou.Mux(`/files/{path...}`).Func(reachableFunc)
+	}
+
+	coll, err := Postman{Name: `Test`, BaseURL: `http://localhost`}.Collection(route)
+	try(err)
+	eq(
+		t,
+		PostmanCollection{
+			Info:     PostmanInfo{Name: `Test`, Schema: PostmanSchema},
+			Variable: []PostmanVar{{Key: `baseUrl`, Value: `http://localhost`}},
+			Item: []PostmanItem{
+				{
+					Name: `articleGet`,
+					Request: PostmanRequest{
+						Method: http.MethodGet,
+						URL: PostmanURL{
+							Raw:      `{{baseUrl}}/articles/:id`,
+							Host:     []string{`{{baseUrl}}`},
+							Path:     []string{`articles`, `:id`},
+							Variable: []PostmanVar{{Key: `id`}},
+						},
+						Description: `Returns an article.`,
+					},
+				},
+				{
+					Name: `POST /articles/{}/v{version}`,
+					Request: PostmanRequest{
+						Method: http.MethodPost,
+						URL: PostmanURL{
+							Raw:      `{{baseUrl}}/articles/:arg0/v{{version}}`,
+							Host:     []string{`{{baseUrl}}`},
+							Path:     []string{`articles`, `:arg0`, `v{{version}}`},
+							Variable: []PostmanVar{{Key: `arg0`}},
+						},
+						Description: `Deprecated.`,
+					},
+				},
+				{
+					Name: `/files/{path...}`,
+					Request: PostmanRequest{
+						Method: http.MethodGet,
+						URL: PostmanURL{
+							Raw:      `{{baseUrl}}/files/:path`,
+							Host:     []string{`{{baseUrl}}`},
+							Path:     []string{`files`, `:path`},
+							Variable: []PostmanVar{{Key: `path`}},
+						},
+					},
+				},
+			},
+		},
+		coll,
+	)
+
+	src, err := Postman{Name: `Test`}.Source(func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+	})
+	try(err)
+	eq(
+		t,
+		`{"info":{"name":"Test","schema":"`+PostmanSchema+`"},`+
+			`"item":[{"name":"GET /one","request":{"method":"GET","url":{"raw":"{{baseUrl}}/one","host":["{{baseUrl}}"],"path":["one"]}}}],`+
+			`"variable":[{"key":"baseUrl","value":""}]}`,
+		string(tCompactJSON(src)),
+	)
+
+	_, err = Postman{}.Collection(func(rou Rou) { rou.Reg(`^/one$`).Func(reachableFunc) })
+	errs(t, `unable to export route "" "^/one$": unsupported match "reg"`, err)
+}
+
+func TestDiagram(t *testing.T) {
+	diag := CollectDiagram(func(rou Rou) {
+		rou.Exa(`/`).Get().Func(reachableFunc)
+		rou.Sta(`/api`).Sub(func(rou Rou) {
+			rou.Pat(`/api/articles/{id}`).Methods(func(rou Rou) {
+				rou.Get().Func(reachableFunc)
+				rou.Post().Han(unreachableHan)
+			})
+			rou.Exa(`/api/"quoted"`).Handler(StatusOnly(0))
+		})
+	})
+
+	eq(
+		t,
+		"flowchart LR\n"+
+			"\tn0[\"routes\"]\n"+
+			"\tn1[\"GET exa /<br/>rout.reachableFunc\"]\n"+
+			"\tn0 --> n1\n"+
+			"\tn2[\"sta /api\"]\n"+
+			"\tn0 --> n2\n"+
+			"\tn3[\"pat /api/articles/{id}\"]\n"+
+			"\tn2 --> n3\n"+
+			"\tn4[\"GET pat /api/articles/{id}<br/>rout.reachableFunc\"]\n"+
+			"\tn3 --> n4\n"+
+			"\tn5[\"POST pat /api/articles/{id}<br/>rout.unreachableHan\"]\n"+
+			"\tn3 --> n5\n"+
+			"\tn6[\"ANY exa /api/#quot;quoted#quot;<br/>rout.StatusOnly\"]\n"+
+			"\tn2 --> n6\n",
+		diag.Mermaid(),
+	)
+
+	eq(
+		t,
+		"digraph routes {\n"+
+			"\trankdir=LR;\n"+
+			"\tnode [shape=box];\n"+
+			"\tn0 [label=\"routes\"];\n"+
+			"\tn1 [label=\"GET exa /\\nrout.reachableFunc\"];\n"+
+			"\tn0 -> n1;\n"+
+			"\tn2 [label=\"sta /api\"];\n"+
+			"\tn0 -> n2;\n"+
+			"\tn3 [label=\"pat /api/articles/{id}\"];\n"+
+			"\tn2 -> n3;\n"+
+			"\tn4 [label=\"GET pat /api/articles/{id}\\nrout.reachableFunc\"];\n"+
+			"\tn3 -> n4;\n"+
+			"\tn5 [label=\"POST pat /api/articles/{id}\\nrout.unreachableHan\"];\n"+
+			"\tn3 -> n5;\n"+
+			"\tn6 [label=\"ANY exa /api/\\\"quoted\\\"\\nrout.StatusOnly\"];\n"+
+			"\tn2 -> n6;\n"+
+			"}\n",
+		diag.DOT(),
+	)
+
+	eq(t, "flowchart LR\n\tn0[\"routes\"]\n", Diagram(nil).Mermaid())
+}
+
+func TestPrecompile(t *testing.T) {
+	try(Precompile(func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Sta(`/api`).Sub(func(rou Rou) {
+			rou.Reg(`^/api/precompile/(?P<id>\d+)$`).Func(reachableFunc)
+			rou.Pat(`/api/precompile/{id}`).Func(reachableFunc)
+			rou.Mux(`/api/precompile/{path...}`).Func(reachableFunc)
+			rou.Col(`/api/precompile/:id`).Func(reachableFunc)
+		})
+	}))
+
+	_, ok := regexpCache.Load(`^/api/precompile/(?P<id>\d+)$`)
+	eq(t, true, ok)
+	_, ok = patCache.Load(`/api/precompile/{id}`)
+	eq(t, true, ok)
+	_, ok = muxCache.Load(`/api/precompile/{path...}`)
+	eq(t, true, ok)
+	_, ok = colCache.Load(`/api/precompile/:id`)
+	eq(t, true, ok)
+
+	err := Precompile(func(rou Rou) {
+		rou.Reg(`^/one/(`).Sub(func(rou Rou) {
+			rou.Reg(`^/one/(`).Func(reachableFunc)
+			rou.Pat(`/two/{`).Func(reachableFunc)
+			rou.Exa(`/three`).Func(reachableFunc)
+		})
+	})
+	errs(t, `invalid pattern "^/one/(" of type "reg": error parsing regexp`, err)
+	errs(t, `invalid pattern "/two/{" of type "pat"`, err)
+	eq(t, 1, strings.Count(err.Error(), `invalid pattern "^/one/("`))
+
+	err = Precompile(func(rou Rou) {
+		rou.Exa(`/one`).Func(reachableFunc)
+		panic(NotFound(``, `/one`))
+	})
+	errs(t, `no such endpoint`, err)
+}
+
+func TestCoverage(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Pat(`/two/{}`).Meths(http.MethodPut, http.MethodPatch).ParamFunc(tParamFunc)
+		rou.Exa(`/three`).Func(func(rew hrew, _ hreq) { rew.WriteHeader(http.StatusAccepted) })
+		rou.Exa(`/four`).Post().Func(reachableFunc)
+	}
+
+	var cov Coverage
+	han := cov.Handler(route)
+
+	serve := func(meth, path string) int {
+		rew := ht.NewRecorder()
+		han.ServeHTTP(rew, tReq(meth, path))
+		return rew.Code
+	}
+
+	eq(t, 5, len(cov.Untested(route)))
+
+	eq(t, 201, serve(http.MethodHead, `/one`))
+	eq(t, 200, serve(http.MethodPatch, `/two/one`))
+	eq(t, 202, serve(http.MethodDelete, `/three`))
+	eq(t, 202, serve(http.MethodGet, `/three`))
+	eq(t, 404, serve(http.MethodGet, `/five`))
+	eq(t, 405, serve(http.MethodGet, `/four`))
+
+	eq(t, 1, cov.Hits(Endpoint{Method: http.MethodGet, Match: MatchExa, Pattern: `/one`}))
+	eq(t, 2, cov.Hits(Endpoint{Match: MatchExa, Pattern: `/three`}))
+
+	var untested []string
+	for _, val := range cov.Untested(route) {
+		untested = append(untested, val.Method+` `+val.Pattern)
+	}
+	eq(t, []string{`PUT /two/{}`, `POST /four`}, untested)
+
+	eq(
+		t,
+		"[rout] 2 untested endpoints:\n"+
+			"\tPUT pat /two/{} rout.tParamFunc\n"+
+			"\tPOST exa /four rout.reachableFunc",
+		cov.Check(route).Error(),
+	)
+
+	cov.Add(Endpoint{Method: http.MethodPut, Match: MatchPat, Pattern: `/two/{}`})
+	cov.Add(Endpoint{Method: http.MethodPost, Match: MatchExa, Pattern: `/four`})
+	try(cov.Check(route))
+}
+
+func TestRouteTable(t *testing.T) {
+	sunset := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Name(`one`).Desc(`first`).Get().Func(reachableFunc)
+		rou.Pat(`/two/{}`).Post().Handler(Str(`two`))
+		rou.Reg(`^/three$`).Deprecated(sunset, ``).Han(unreachableHan)
+		rou.Exa(`/five`).Handler(StatusOnly(http.StatusNoContent))
+	}
+
+	table := CollectRouteTable(route)
+	eq(
+		t,
+		RouteTable{
+			{Pattern: `/one`, Style: `exa`, Method: http.MethodGet, Handler: `rout.reachableFunc`, Name: `one`, Desc: `first`},
+			{Pattern: `/two/{}`, Style: `pat`, Method: http.MethodPost, Handler: `rout.Str`},
+			{Pattern: `^/three$`, Style: `reg`, Handler: `rout.unreachableHan`, Deprecated: true, Sunset: &sunset},
+			{Pattern: `/five`, Style: `exa`, Handler: `rout.StatusOnly`},
+		},
+		table,
+	)
+
+	out, err := json.Marshal(table[:3])
+	try(err)
+	eq(
+		t,
+		`[`+
+			`{"pattern":"/one","style":"exa","method":"GET","handler":"rout.reachableFunc","name":"one","desc":"first"},`+
+			`{"pattern":"/two/{}","style":"pat","method":"POST","handler":"rout.Str"},`+
+			`{"pattern":"^/three$","style":"reg","handler":"rout.unreachableHan","deprecated":true,"sunset":"2030-01-02T03:04:05Z"}`+
+			`]`,
+		string(out),
+	)
+
+	eq(t, ``, IdentName([2]uintptr{}))
+	eq(t, ``, IdentName(Ident(Func(nil))))
+}
+
+func TestURLs(t *testing.T) {
+	urls := CollectURLs(func(rou Rou) {
+		rou.Exa(`/one`).Name(`one`).Get().Func(reachableFunc)
+		rou.Exa(`/one`).Name(`one`).Post().Func(reachableFunc)
+		rou.Pat(`/two/{id}/three/{}`).Name(`two`).Get().Func(reachableFunc)
+		rou.Mux(`GET /files/{id}/{path...}`).Name(`files`).Func(reachableFunc)
+		rou.Col(`/users/:id`).Name(`user`).Func(reachableFunc)
+		rou.Reg(`^/reg$`).Name(`reg`).Func(reachableFunc)
+		rou.Exa(`/unnamed`).Func(reachableFunc)
+	})
+
+	eq(t, 5, len(urls))
+	eq(t, http.MethodGet, urls[`one`].Method)
+
+	test := func(exp, name string, args ...interface{}) {
+		t.Helper()
+		out, err := urls.For(name, args...)
+		try(err)
+		eq(t, exp, out)
+	}
+
+	test(`/one`, `one`)
+	test(`/two/12/three/a%2Fb%20c`, `two`, 12, `a/b c`)
+	test(`/files/10/one/two%20three`, `files`, 10, `one/two three`)
+	test(`/users/ten`, `user`, `ten`)
+
+	_, err := urls.For(`missing`)
+	errs(t, `unknown route name "missing"`, err)
+
+	_, err = urls.For(`two`, 12)
+	errs(t, `expected 2 args, got 1`, err)
+
+	_, err = urls.For(`one`, 12)
+	errs(t, `expected 0 args, got 1`, err)
+
+	_, err = urls.For(`reg`)
+	errs(t, `unsupported match "reg"`, err)
+
+	panics(t, `unknown route name "missing"`, func() { urls.MustFor(`missing`) })
+}
+
+func TestRou_Sub_captures(t *testing.T) {
+	var args []string
+	var named map[string]string
+
+	route := func(rou Rou) {
+		rou.Reg(`^/orgs/(?P<org>[^/]+)/`).Sub(func(rou Rou) {
+			rou.Sta(`/orgs`).Sub(func(rou Rou) {
+				rou.Reg(`^/orgs/[^/]+/repos/(?P<repo>[^/]+)$`).ParamFunc(func(_ hrew, _ hreq, val []string) {
+					args = val
+				})
+				rou.Reg(`^/orgs/[^/]+/teams/(?P<team>[^/]+)$`).ParamMapFunc(func(_ hrew, _ hreq, val map[string]string) {
+					named = val
+				})
+				rou.Exa(`/orgs/one/info`).ParamFunc(func(_ hrew, _ hreq, val []string) {
+					args = val
+				})
+			})
+		})
+	}
+
+	_, err := tRoute(tReq(http.MethodGet, `/orgs/one/repos/two`), route)
+	eq(t, nil, err)
+	eq(t, []string{`one`, `two`}, args)
+
+	_, err = tRoute(tReq(http.MethodGet, `/orgs/one/teams/three`), route)
+	eq(t, nil, err)
+	eq(t, map[string]string{`org`: `one`, `team`: `three`}, named)
+
+	_, err = tRoute(tReq(http.MethodGet, `/orgs/one/info`), route)
+	eq(t, nil, err)
+	eq(t, []string{`one`}, args)
+}
+
+func TestRou_Sub_captures_inherited(t *testing.T) {
+	test := func(exp []string, route func(Rou, ParamFunc)) {
+		t.Helper()
+		var out []string
+		_, err := tRoute(tReq(http.MethodGet, `/a/x`), func(rou Rou) {
+			route(rou, func(_ hrew, _ hreq, args []string) { out = args })
+		})
+		eq(t, nil, err)
+		eq(t, exp, out)
+	}
+
+	test([]string{`x`}, func(rou Rou, fun ParamFunc) {
+		rou.Pat(`/a/{}`).Sub(func(rou Rou) { rou.Get().ParamFunc(fun) })
+	})
+
+	test([]string{`x`}, func(rou Rou, fun ParamFunc) {
+		rou.Reg(`^/a/([^/]+)$`).Sub(func(rou Rou) { rou.Get().ParamFunc(fun) })
+	})
+
+	test([]string{`x`}, func(rou Rou, fun ParamFunc) {
+		rou.Pat(`/a/{}`).Methods(func(rou Rou) { rou.Get().ParamFunc(fun) })
+	})
+
+	test([]string{`x`}, func(rou Rou, fun ParamFunc) {
+		rou.Pat(`/a/{}`).Sub(func(rou Rou) {
+			rou.Methods(func(rou Rou) { rou.Get().ParamFunc(fun) })
+		})
+	})
+
+	test([]string{`x`}, func(rou Rou, fun ParamFunc) {
+		rou.Pat(`/a/{}`).Sub(func(rou Rou) {
+			rou.Sub(func(rou Rou) { rou.Get().ParamFunc(fun) })
+		})
+	})
+
+	test([]string{`x`, `x`}, func(rou Rou, fun ParamFunc) {
+		rou.Pat(`/a/{}`).Sub(func(rou Rou) {
+			rou.Reg(`^/a/([^/]+)$`).Get().ParamFunc(fun)
+		})
+	})
+}
+
+func TestRou_Sub_captures_repeated(t *testing.T) {
+	var args []string
+	var named map[string]string
+
+	route := func(rou Rou) {
+		rou.Sta(`/api`).Sub(func(rou Rou) {
+			rou.Pat(`/api/articles/{id}`).Sub(func(rou Rou) {
+				rou.Pat(`/api/articles/{id}`).Methods(func(rou Rou) {
+					rou.Get().ParamFunc(func(_ hrew, _ hreq, val []string) {
+						args = val
+					})
+					rou.Post().ParamMapFunc(func(_ hrew, _ hreq, val map[string]string) {
+						named = val
+					})
+				})
+			})
+		})
+	}
+
+	_, err := tRoute(tReq(http.MethodGet, `/api/articles/one`), route)
+	eq(t, nil, err)
+	eq(t, []string{`one`}, args)
+
+	_, err = tRoute(tReq(http.MethodGet, `/api/tags/one`), func(rou Rou) {
+		rou.Pat(`/api/tags/{}`).Sub(func(rou Rou) {
+			rou.Pat(`/api/tags/{}`).Get().ParamFunc(func(_ hrew, _ hreq, val []string) {
+				args = val
+			})
+		})
+	})
+	eq(t, nil, err)
+	eq(t, []string{`one`}, args)
+
+	_, err = tRoute(tReq(http.MethodPost, `/api/articles/two`), route)
+	eq(t, nil, err)
+	eq(t, map[string]string{`id`: `two`}, named)
+}
+
+func TestRou_ParamSta(t *testing.T) {
+	test := func(exp []string, pat, path string) {
+		t.Helper()
+		var out []string
+		_, _ = tRoute(tReq(http.MethodGet, path), func(rou Rou) {
+			rou.ParamSta(pat).ParamFunc(func(_ hrew, _ hreq, args []string) { out = args })
+		})
+		eq(t, exp, out)
+	}
+
+	test([]string{`/one/two`}, `/files`, `/files/one/two`)
+	test([]string{``}, `/files`, `/files`)
+	test([]string{`one/two`}, `/files/`, `/files/one/two`)
+	test([]string{`/files/one`}, ``, `/files/one`)
+	test(nil, `/files`, `/filesx/one`)
+
+	eq(t, `paramSta`, MatchParamSta.String())
+	eq(t, []string{``}, MatchParamSta.Names(`/files`))
+
+	reg := regexp.MustCompile(paramStaToReg(`/files`))
+	eq(t, []string{`/files/one`, `/one`}, reg.FindStringSubmatch(`/files/one`))
+	eq(t, []string{`/files`, ``}, reg.FindStringSubmatch(`/files`))
+	eq(t, []string(nil), reg.FindStringSubmatch(`/filesx`))
+
+	urls := CollectURLs(func(rou Rou) { rou.ParamSta(`/files`).Name(`files`).Func(nil) })
+	eq(t, `/files/one%20two/three`, urls.MustFor(`files`, `/one two/three`))
+}
+
+func TestDedupSlash(t *testing.T) {
+	eq(t, ``, dedupSlash(``))
+	eq(t, `/`, dedupSlash(`//`))
+	eq(t, `/one/two/`, dedupSlash(`/one/two/`))
+	eq(t, `/one/two/`, dedupSlash(`//one///two//`))
+}
+
+func TestRou_DedupSlash(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one/two`).Get().Func(reachableFunc)
+		rou.Pat(`/three/{}`).Get().ParamHan(func(_ hreq, args []string) hhan { return Str(args[0]) })
+	}
+
+	eq(t, 404, tStatus(tReq(http.MethodGet, `/one//two`), route))
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/one//two`), func(rou Rou) { rou.DedupSlash().Sub(route) }))
+
+	rew, err := tRoute(tReq(http.MethodGet, `//three//four`), func(rou Rou) { rou.DedupSlash().Sub(route) })
+	eq(t, nil, err)
+	eq(t, `four`, rew.Body.String())
+}
+
+func TestRou_DedupSlashRedirect(t *testing.T) {
+	route := func(rou Rou) {
+		rou.DedupSlashRedirect(0)
+		rou.Exa(`/one/two`).Get().Func(reachableFunc)
+	}
+
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/one/two`), route))
+
+	req := tReq(http.MethodGet, `/one//two`)
+	req.URL.RawQuery = `three=four`
+
+	rew, err := tRoute(req, route)
+	eq(t, nil, err)
+	eq(t, http.StatusMovedPermanently, rew.Code)
+	eq(t, `/one/two?three=four`, rew.Header().Get(`Location`))
+}
+
+func TestRou_Methods_allow(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Methods(func(rou Rou) {
+			rou.Get().Func(reachableFunc)
+			rou.Meths(http.MethodPut, http.MethodPatch).Func(reachableFunc)
+			rou.Get().Func(reachableFunc)
+		})
+		rou.Exa(`/two`).Methods(func(rou Rou) {
+			rou.Post().Func(reachableFunc)
+			rou.Options().Handler(Str(`options`))
+		})
+		rou.Exa(`/three`).Methods(func(rou Rou) {
+			rou.Meth(`PURGE`).Func(reachableFunc)
+			rou.Delete().Func(reachableFunc)
+			rou.Meths(`LOCK`, `PURGE`).Func(reachableFunc)
+		})
+	}
+
+	rew, err := tRoute(tReq(http.MethodPost, `/one`), route)
+	errs(t, `method not allowed`, err)
+	eq(t, `GET, HEAD, PUT, PATCH, OPTIONS`, rew.Header().Get(`Allow`))
+
+	rew, err = tRoute(tReq(http.MethodOptions, `/one`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusNoContent, rew.Code)
+	eq(t, `GET, HEAD, PUT, PATCH, OPTIONS`, rew.Header().Get(`Allow`))
+
+	rew, err = tRoute(tReq(http.MethodOptions, `/two`), route)
+	eq(t, nil, err)
+	eq(t, `options`, rew.Body.String())
+	eq(t, ``, rew.Header().Get(`Allow`))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	errs(t, `method not allowed`, err)
+	eq(t, `POST, OPTIONS`, rew.Header().Get(`Allow`))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	errs(t, `method not allowed`, err)
+	eq(t, `DELETE, OPTIONS, PURGE, LOCK`, rew.Header().Get(`Allow`))
+
+	req := tReq(http.MethodPost, `/two`)
+	eq(t, 1.0, testing.AllocsPerRun(64, func() {
+		try(MakeRou(NopRew{}, req).Route(route))
+	}))
+}
+
+func TestRou_Methods_allow_once(t *testing.T) {
+	var count int
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Methods(func(rou Rou) {
+			count++
+			rou.Rew.Header().Add(`X-Side`, `1`)
+			rou.Get().Func(reachableFunc)
+			rou.Post().Func(reachableFunc)
+		})
+	}
+
+	rew, err := tRoute(tReq(http.MethodPut, `/one`), route)
+	errs(t, `method not allowed`, err)
+	eq(t, 1, count)
+	eq(t, []string{`1`}, rew.Header().Values(`X-Side`))
+	eq(t, `GET, HEAD, POST, OPTIONS`, rew.Header().Get(`Allow`))
+}
+
+func TestRou_Methods_options_middleware(t *testing.T) {
+	cors := func(next hhan) hhan {
+		return http.HandlerFunc(func(rew hrew, req hreq) {
+			rew.Header().Set(`Access-Control-Allow-Origin`, `*`)
+			next.ServeHTTP(rew, req)
+		})
+	}
+
+	route := func(rou Rou) {
+		rou.Use(cors).Exa(`/one`).Methods(func(rou Rou) {
+			rou.Get().Func(reachableFunc)
+		})
+	}
+
+	rew, err := tRoute(tReq(http.MethodOptions, `/one`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusNoContent, rew.Code)
+	eq(t, `*`, rew.Header().Get(`Access-Control-Allow-Origin`))
+	eq(t, `GET, HEAD, OPTIONS`, rew.Header().Get(`Allow`))
+}
+
+func TestRou_head_fallback(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Handler(Str(`one`))
+		rou.Exa(`/two`).Methods(func(rou Rou) {
+			rou.Get().Handler(Str(`get`))
+			rou.Head().Func(func(rew hrew, _ hreq) { rew.WriteHeader(202) })
+		})
+		rou.Exa(`/three`).StrictHead().Get().Handler(Str(`three`))
+		rou.Exa(`/four`).Meths(http.MethodGet, http.MethodPost).Handler(Str(`four`))
+	}
+
+	rew, err := tRoute(tReq(http.MethodHead, `/one`), route)
+	eq(t, nil, err)
+	eq(t, 200, rew.Code)
+	eq(t, ``, rew.Body.String())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, `one`, rew.Body.String())
+
+	eq(t, 202, tStatus(tReq(http.MethodHead, `/two`), route))
+	eq(t, http.StatusMethodNotAllowed, tStatus(tReq(http.MethodHead, `/three`), route))
+
+	rew, err = tRoute(tReq(http.MethodHead, `/four`), route)
+	eq(t, nil, err)
+	eq(t, ``, rew.Body.String())
+
+	rou := MakeRou(ht.NewRecorder(), tReq(http.MethodHead, `/four`))
+	eq(t, nil, rou.Route(route))
+	eq(t, http.MethodGet, rou.Mut.Endpoint.Method)
+}
+
+func TestRou_HanAny(t *testing.T) {
+	miss := func(hreq) http.Handler { return nil }
+	found := func(hreq) http.Handler { return Str(`found`) }
+	never := func(hreq) http.Handler { panic(`unreachable`) }
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().HanAny(miss, nil, found, never)
+		rou.Exa(`/two`).Get().HanAny(miss)
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, `found`, rew.Body.String())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, nil, err)
+	eq(t, ``, rew.Body.String())
+
+	var endpoints []Endpoint
+	Visit(route, VisitorFunc(func(val Endpoint) { endpoints = append(endpoints, val) }))
+	eq(t, 2, len(endpoints))
+	eq(t, Ident(Han(miss)), endpoints[0].Handler)
+}
+
+func TestCoalesceRes(t *testing.T) {
+	miss := func(hreq) *http.Response { return nil }
+	found := func(hreq) *http.Response {
+		return &http.Response{StatusCode: 202, Body: io.NopCloser(strings.NewReader(`found`))}
+	}
+
+	rew := ht.NewRecorder()
+	CoalesceRes{miss, nil, found}.ServeHTTP(rew, tReq(http.MethodGet, `/`))
+	eq(t, 202, rew.Code)
+	eq(t, `found`, rew.Body.String())
+
+	route := func(rou Rou) { rou.Exa(`/one`).Get().ResAny(miss, found) }
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, 202, rew.Code)
+	eq(t, `found`, rew.Body.String())
+}
+
+func TestRou_Matched(t *testing.T) {
+	route := func(rou Rou) { rou.Pat(`/one/{}`).Get().Func(reachableFunc) }
+
+	rou := MakeRou(ht.NewRecorder(), tReq(http.MethodGet, `/one/two`))
+	eq(t, nil, rou.Route(route))
+
+	end, ok := rou.Matched()
+	eq(t, true, ok)
+	eq(t, Endpoint{
+		Pattern: `/one/{}`,
+		Match:   MatchPat,
+		Method:  http.MethodGet,
+		Handler: Ident(Func(reachableFunc)),
+	}, end)
+
+	rou = MakeRou(ht.NewRecorder(), tReq(http.MethodGet, `/two`))
+	errs(t, `no such endpoint`, rou.Route(route))
+
+	end, ok = rou.Matched()
+	eq(t, false, ok)
+	eq(t, Endpoint{}, end)
+
+	end, ok = (&Rou{}).Matched()
+	eq(t, false, ok)
+	eq(t, Endpoint{}, end)
+}
+
+func TestRoute_func(t *testing.T) {
+	route := func(rou Rou) { rou.Exa(`/one`).Get().Func(reachableFunc) }
+
+	rew := ht.NewRecorder()
+	eq(t, nil, Route(rew, tReq(http.MethodGet, `/one`), route))
+	eq(t, 201, rew.Code)
+
+	rew = ht.NewRecorder()
+	errs(t, `no such endpoint`, Route(rew, tReq(http.MethodGet, `/two`), route))
+
+	rew = ht.NewRecorder()
+	Serve(rew, tReq(http.MethodGet, `/two`), route)
+	eq(t, http.StatusNotFound, rew.Code)
+}
+
+func TestServer(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+		rou.Exa(`/two`).Get().Func(func(hrew, hreq) { panic(`three`) })
+	}
+
+	test := func(srv Server, path string) *ht.ResponseRecorder {
+		t.Helper()
+		rew := ht.NewRecorder()
+		srv.ServeHTTP(rew, tReq(http.MethodGet, path))
+		return rew
+	}
+
+	rew := test(Server{Routes: route}, `/one`)
+	eq(t, 201, rew.Code)
+
+	rew = test(Server{Routes: route}, `/three`)
+	eq(t, http.StatusNotFound, rew.Code)
+
+	srv := Server{
+		Routes: route,
+		OnErr: func(rew hrew, _ hreq, err error) {
+			rew.WriteHeader(ErrStatusFallback(err))
+			_, _ = io.WriteString(rew, `custom`)
 		},
-		endpoints,
-	)
+		OnPanic: func(rew hrew, _ hreq, err ErrPanic) {
+			rew.WriteHeader(err.HttpStatusCode())
+			_, _ = io.WriteString(rew, fmt.Sprint(err.Val))
+		},
+	}
+
+	rew = test(srv, `/three`)
+	eq(t, http.StatusNotFound, rew.Code)
+	eq(t, `custom`, rew.Body.String())
+
+	rew = test(srv, `/two`)
+	eq(t, http.StatusInternalServerError, rew.Code)
+	eq(t, `three`, rew.Body.String())
 }
 
-func TestPatternVisitor(t *testing.T) {
-	var (
-		hanExa = func(hreq) hhan { panic(`unreachable`) }
-		hanPat = func(hreq) hhan { panic(`unreachable`) }
-	)
+func TestRou_Lax(t *testing.T) {
+	articles := func(rou Rou) { rou.Exa(`/articles`).Get().Handler(Str(`get`)) }
+	admin := func(rou Rou) {
+		rou.Pat(`/articles/{}`).Delete().ParamFunc(func(rew hrew, _ hreq, _ []string) { rew.WriteHeader(202) })
+		rou.Exa(`/articles`).Post().Handler(Str(`post`))
+	}
+	items := func(rou Rou) { rou.Pat(`/articles/{}`).Get().Handler(Str(`item`)) }
 
-	notEq(t, Ident(hanExa), Ident(hanPat))
+	route := Join(articles, admin, items)
+	lax := func(rou Rou) { route(rou.Lax()) }
 
-	// This adapter supports only "exact" and "pattern" matches.
+	test := func(exp string, meth, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(meth, path), lax)
+		eq(t, nil, err)
+		eq(t, exp, rew.Body.String())
+	}
+
+	test(`get`, http.MethodGet, `/articles`)
+	test(`post`, http.MethodPost, `/articles`)
+	test(`item`, http.MethodGet, `/articles/one`)
+	eq(t, 202, tStatus(tReq(http.MethodDelete, `/articles/one`), lax))
+
+	eq(t, http.StatusMethodNotAllowed, tStatus(tReq(http.MethodPut, `/articles`), lax))
+	eq(t, http.StatusNotFound, tStatus(tReq(http.MethodPut, `/other`), lax))
+
+	eq(t, http.StatusMethodNotAllowed, tStatus(tReq(http.MethodPost, `/articles`), route))
+}
+
+func TestRou_Else(t *testing.T) {
+	api := func(rou Rou) {
+		rou.Exa(`/api/articles`).Get().Handler(Str(`articles`))
+	}
+	spa := func(rou Rou) {
+		rou.Get().Handler(Str(`shell`))
+	}
 	route := func(rou Rou) {
-		rou.Exa(`/one/exa`).Post().Han(hanExa)
-		rou.Pat(`/four/pat/{}`).Post().Han(hanPat)
+		rou.Sta(`/`).Else(api, spa)
 	}
 
+	test := func(exp string, meth, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(meth, path), route)
+		eq(t, nil, err)
+		eq(t, exp, rew.Body.String())
+	}
+
+	test(`articles`, http.MethodGet, `/api/articles`)
+	test(`shell`, http.MethodGet, `/articles`)
+	test(`shell`, http.MethodGet, `/`)
+	eq(t, http.StatusMethodNotAllowed, tStatus(tReq(http.MethodPost, `/articles`), route))
+	eq(t, http.StatusNotFound, tStatus(tReq(http.MethodGet, `/`), func(rou Rou) {
+		rou.Sta(`/`).Else(api, nil)
+	}))
+
 	var endpoints []Endpoint
+	Visit(route, VisitorFunc(func(val Endpoint) { endpoints = append(endpoints, val) }))
+	eq(t, 2, len(endpoints))
+}
 
-	vis := PatternVisitor{SimpleVisitorFunc(func(path, meth string, ident [2]uintptr) {
-		endpoints = append(endpoints, Endpoint{path, MatchPat, meth, ident})
-	})}
+func TestRou_Deprecated(t *testing.T) {
+	sunset := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
 
-	Visit(route, vis)
+	route := func(rou Rou) {
+		rou.Sta(`/v1`).Deprecated(sunset, `https://example.com/v2`).Sub(func(rou Rou) {
+			rou.Exa(`/v1/one`).Get().Func(reachableFunc)
+		})
+		rou.Exa(`/v2/one`).Get().Func(reachableFunc)
+	}
 
-	eq(
-		t,
-		[]Endpoint{
-			{`/one/exa`, MatchPat, http.MethodPost, Ident(hanExa)},
-			{`/four/pat/{}`, MatchPat, http.MethodPost, Ident(hanPat)},
-		},
-		endpoints,
+	rew, err := tRoute(tReq(http.MethodGet, `/v1/one`), route)
+	eq(t, nil, err)
+	eq(t, 201, rew.Code)
+	eq(t, `true`, rew.Header().Get(`Deprecation`))
+	eq(t, `Wed, 02 Jan 2030 03:04:05 GMT`, rew.Header().Get(`Sunset`))
+	eq(t, `<https://example.com/v2>; rel="deprecation"`, rew.Header().Get(`Link`))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/v2/one`), route)
+	eq(t, nil, err)
+	eq(t, ``, rew.Header().Get(`Deprecation`))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/v1/one`), func(rou Rou) {
+		rou.Deprecated(time.Time{}, ``).Exa(`/v1/one`).Func(reachableFunc)
+	})
+	eq(t, nil, err)
+	eq(t, `true`, rew.Header().Get(`Deprecation`))
+	eq(t, http.Header{`Deprecation`: {`true`}}, rew.Header())
+
+	endpoints := Endpoints(route)
+	eq(t, 2, len(endpoints))
+	eq(t, true, endpoints[0].Deprecated)
+	eq(t, sunset, endpoints[0].Sunset)
+	eq(t, false, endpoints[1].Deprecated)
+}
+
+func TestRou_Maintenance(t *testing.T) {
+	var down bool
+	enabled := func() bool { return down }
+
+	route := func(rou Rou) {
+		rou.Sta(`/one`).Maintenance(enabled, 1500*time.Millisecond).Sub(func(rou Rou) {
+			rou.Exa(`/one/two`).Get().Func(reachableFunc)
+		})
+		rou.Exa(`/two`).Get().Func(reachableFunc)
+	}
+
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/one/two`), route))
+
+	down = true
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one/two`), route)
+	errs(t, `service unavailable`, err)
+	eq(t, http.StatusServiceUnavailable, ErrStatus(err))
+	eq(t, `2`, rew.Header().Get(`Retry-After`))
+
+	eq(t, 201, tStatus(tReq(http.MethodGet, `/two`), route))
+	eq(t, http.StatusNotFound, tStatus(tReq(http.MethodGet, `/one/three`), route))
+}
+
+func TestRou_Proxy(t *testing.T) {
+	upstream := ht.NewServer(http.HandlerFunc(func(rew hrew, req hreq) {
+		rew.Header().Set(`X-Path`, req.URL.Path)
+		rew.WriteHeader(202)
+		_, _ = io.WriteString(rew, req.Method)
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL + `/base`)
+	try(err)
+
+	dead, err := url.Parse(`http://127.0.0.1:1`)
+	try(err)
+
+	one := NewProxy(target, ProxyStrip(`/one`))
+	two := NewProxy(target)
+	three := NewProxy(dead)
+
+	route := func(rou Rou) {
+		rou.Sta(`/one`).Proxy(one)
+		rou.Sta(`/two`).Proxy(two)
+		rou.Sta(`/three`).Proxy(three)
+		rou.Sta(`/four`).Redact().Proxy(three)
+	}
+
+	rew, err := tRoute(tReq(http.MethodPost, `/one/four`), route)
+	eq(t, nil, err)
+	eq(t, 202, rew.Code)
+	eq(t, `/base/four`, rew.Header().Get(`X-Path`))
+	eq(t, http.MethodPost, rew.Body.String())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two/four`), route)
+	eq(t, nil, err)
+	eq(t, `/base/two/four`, rew.Header().Get(`X-Path`))
+
+	_, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	eq(t, Err(`bad gateway`, http.StatusBadGateway, http.MethodGet, `/three`), err.Error())
+	eq(t, http.StatusBadGateway, ErrStatus(err))
+	eq(t, true, errors.Is(err, ErrBadGatewayBase))
+	eq(t, false, errors.Is(err, ErrClientAbort))
+
+	var opErr *net.OpError
+	eq(t, true, errors.As(err, &opErr))
+
+	_, err = tRoute(tReq(http.MethodGet, `/four`), route)
+	eq(t, `[rout] routing error (HTTP status 502): bad gateway`, err.Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tRoute(tReq(http.MethodGet, `/two`).WithContext(ctx), route)
+	eq(t, true, errors.Is(err, ErrClientAbort))
+	eq(t, true, errors.Is(err, context.Canceled))
+	eq(t, http.StatusBadGateway, ErrStatus(err))
+}
+
+func TestProxyStrip(t *testing.T) {
+	test := func(exp, prefix, path string) {
+		t.Helper()
+		val := &url.URL{Path: path}
+		proxyStrip(val, prefix)
+		eq(t, exp, val.Path)
+	}
+
+	test(`/two`, `/one`, `/one/two`)
+	test(`/`, `/one`, `/one`)
+	test(`/two`, `/one/`, `/one/two`)
+	test(`/other`, `/one`, `/other`)
+	test(`/one`, ``, `/one`)
+}
+
+func TestEvent_Append(t *testing.T) {
+	test := func(exp string, val Event) {
+		t.Helper()
+		eq(t, exp, string(val.Append(nil)))
+	}
+
+	test("data: \n\n", Event{})
+	test("data: one\n\n", Event{Data: `one`})
+	test("data: one\ndata: two\n\n", Event{Data: "one\r\ntwo"})
+	test(
+		"id: 1\nevent: update\nretry: 1500\ndata: one\n\n",
+		Event{Id: `1`, Event: `update`, Retry: 1500 * time.Millisecond, Data: `one`},
 	)
+}
 
-	routeReg := func(rou Rou) {
-		rou.Reg(`^/three/reg/([^/]+)$`).Post().Han(nil)
+func TestEventStream(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Handler(EventStream(func(_ hreq, send func(Event)) error {
+			send(Event{Data: `one`})
+			send(Event{Event: `two`, Data: `three`})
+			return nil
+		}))
+		rou.Exa(`/two`).Get().Handler(EventStream(func(hreq, func(Event)) error {
+			return ErrForbidden(`forbidden`)
+		}))
+		rou.Exa(`/three`).Get().Handler(EventStream(func(hreq, func(Event)) error { return nil }))
 	}
 
-	panics(
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, 200, rew.Code)
+	eq(t, true, rew.Flushed)
+	eq(t, `text/event-stream`, rew.Header().Get(`Content-Type`))
+	eq(t, "data: one\n\nevent: two\ndata: three\n\n", rew.Body.String())
+
+	_, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, http.StatusForbidden, ErrStatus(err))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	eq(t, nil, err)
+	eq(t, `text/event-stream`, rew.Header().Get(`Content-Type`))
+	eq(t, ``, rew.Body.String())
+}
+
+func TestEventStream_disconnect(t *testing.T) {
+	var canceled bool
+
+	EventStream(func(req hreq, send func(Event)) error {
+		send(Event{Data: `one`})
+		canceled = req.Context().Err() != nil
+		return nil
+	}).ServeHTTP(tFailRew{}, tReq(http.MethodGet, `/`))
+
+	eq(t, true, canceled)
+}
+
+func TestRou_Stream(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Stream(func(hreq) (http.Header, int, func(io.Writer) error) {
+			head := http.Header{`Content-Type`: {`text/plain`}}
+			return head, 202, func(out io.Writer) error {
+				_, _ = io.WriteString(out, `one`)
+				out.(http.Flusher).Flush()
+				_, _ = io.WriteString(out, `two`)
+				return nil
+			}
+		})
+		rou.Exa(`/two`).Get().Stream(func(hreq) (http.Header, int, func(io.Writer) error) {
+			return nil, 0, func(io.Writer) error { return io.ErrUnexpectedEOF }
+		})
+		rou.Exa(`/three`).Get().Stream(func(hreq) (http.Header, int, func(io.Writer) error) {
+			return nil, http.StatusNoContent, nil
+		})
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, 202, rew.Code)
+	eq(t, true, rew.Flushed)
+	eq(t, `text/plain`, rew.Header().Get(`Content-Type`))
+	eq(t, `onetwo`, rew.Body.String())
+
+	_, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, io.ErrUnexpectedEOF, err)
+
+	eq(t, http.StatusNoContent, tStatus(tReq(http.MethodGet, `/three`), route))
+}
+
+func TestRou_Reply(t *testing.T) {
+	encodeText := func(rew hrew, _ hreq, status int, val interface{}) error {
+		rew.Header().Set(`Content-Type`, `text/plain`)
+		rew.WriteHeader(status)
+		_, err := fmt.Fprint(rew, val)
+		return err
+	}
+
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Get().Reply(func(hreq) (int, interface{}) {
+			return http.StatusCreated, map[string]int{`one`: 1}
+		})
+		rou.Exa(`/two`).Get().Reply(func(hreq) (int, interface{}) {
+			return http.StatusNoContent, nil
+		})
+		rou.Exa(`/three`).Encoder(encodeText).Get().Reply(func(hreq) (int, interface{}) {
+			return http.StatusAccepted, `three`
+		})
+		rou.Exa(`/four`).Get().Reply(func(hreq) (int, interface{}) {
+			return 0, func() {}
+		})
+	}
+
+	rew, err := tRoute(tReq(http.MethodGet, `/one`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusCreated, rew.Code)
+	eq(t, `application/json`, rew.Header().Get(`Content-Type`))
+	eq(t, "{\"one\":1}\n", rew.Body.String())
+
+	rew, err = tRoute(tReq(http.MethodGet, `/two`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusNoContent, rew.Code)
+	eq(t, ``, rew.Header().Get(`Content-Type`))
+
+	rew, err = tRoute(tReq(http.MethodGet, `/three`), route)
+	eq(t, nil, err)
+	eq(t, http.StatusAccepted, rew.Code)
+	eq(t, `text/plain`, rew.Header().Get(`Content-Type`))
+	eq(t, `three`, rew.Body.String())
+
+	_, err = tRoute(tReq(http.MethodGet, `/four`), route)
+	errs(t, `unsupported type`, err)
+}
+
+func TestErrRoute(t *testing.T) {
+	_, err := tRoute(tReq(http.MethodPost, `/one`), func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+	})
+
+	var val ErrRoute
+	eq(t, true, errors.As(err, &val))
+	eq(t, ErrRoute{
+		Status: http.StatusMethodNotAllowed,
+		Method: http.MethodPost,
+		Path:   `/one`,
+		Msg:    `method not allowed`,
+		Allow:  `GET, HEAD`,
+	}, val)
+	eq(t, MethodNotAllowed(http.MethodPost, `/one`).Error(), val.Error())
+	eq(t, http.StatusMethodNotAllowed, ErrStatus(err))
+
+	var str ErrMethodNotAllowed
+	eq(t, true, errors.As(err, &str))
+	eq(t, MethodNotAllowed(http.MethodPost, `/one`), str)
+
+	var other ErrNotFound
+	eq(t, false, errors.As(err, &other))
+
+	out, err := json.Marshal(val)
+	try(err)
+	eq(
 		t,
-		`[rout] unable to convert match "reg" for route "^/three/reg/([^/]+)$" "POST" to OAS pattern`,
-		func() { Visit(routeReg, vis) },
+		`{"status":405,"method":"POST","path":"/one","message":"method not allowed","allow":"GET, HEAD"}`,
+		string(out),
 	)
+}
 
-	routeSta := func(rou Rou) {
-		rou.Sta(`/two/sta`).Post().Han(nil)
+func TestErrRoute_Is(t *testing.T) {
+	_, err := tRoute(tReq(http.MethodGet, `/two`), func(rou Rou) {
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+	})
+
+	eq(t, true, errors.Is(err, ErrNotFoundBase))
+	eq(t, true, errors.Is(fmt.Errorf(`wrapped: %w`, err), ErrNotFoundBase))
+	eq(t, false, errors.Is(err, ErrMethodNotAllowedBase))
+	eq(t, false, errors.Is(err, ErrRoute{Status: http.StatusNotFound, Path: `/one`}))
+
+	eq(t, true, errors.Is(NotFound(``, ``), ErrNotFoundBase))
+	eq(t, true, errors.Is(MethodNotAllowed(``, ``), ErrMethodNotAllowedBase))
+	eq(t, true, errors.Is(NotAcceptable(``, ``), ErrNotAcceptableBase))
+	eq(t, true, errors.Is(UnsupportedMediaType(``, ``), ErrUnsupportedMediaTypeBase))
+	eq(t, true, errors.Is(Forbidden(``, ``), ErrForbiddenBase))
+	eq(t, false, errors.Is(Forbidden(``, ``), ErrNotFoundBase))
+}
+
+func TestRegisterErrStatus(t *testing.T) {
+	errOne := errors.New(`one`)
+	errTwo := errors.New(`two`)
+
+	eq(t, 0, ErrStatus(errOne))
+
+	RegisterErrStatus(errOne, http.StatusNotFound)
+	RegisterErrStatusType[tRegisteredErr](http.StatusGatewayTimeout)
+
+	eq(t, http.StatusNotFound, ErrStatus(errOne))
+	eq(t, http.StatusNotFound, ErrStatus(fmt.Errorf(`wrapped: %w`, errOne)))
+	eq(t, 0, ErrStatus(errTwo))
+	eq(t, http.StatusInternalServerError, ErrStatusFallback(errTwo))
+
+	eq(t, http.StatusGatewayTimeout, ErrStatus(tRegisteredErr{}))
+	eq(t, http.StatusGatewayTimeout, ErrStatus(fmt.Errorf(`wrapped: %w`, tRegisteredErr{})))
+}
+
+func TestRou_allow(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Exa(`/one`).Meths(http.MethodPut, http.MethodPatch).Func(reachableFunc)
+		rou.Lax().Sub(func(rou Rou) {
+			rou.Exa(`/two`).Get().Func(reachableFunc)
+			rou.Exa(`/two`).Post().Func(reachableFunc)
+			rou.Exa(`/two`).StrictHead().Get().Func(reachableFunc)
+		})
 	}
 
-	panics(
+	test := func(exp string, path string) {
+		t.Helper()
+		rew, err := tRoute(tReq(http.MethodDelete, path), route)
+		eq(t, http.StatusMethodNotAllowed, ErrStatus(err))
+
+		var val ErrRoute
+		eq(t, true, errors.As(err, &val))
+		eq(t, exp, val.Allow)
+
+		WriteErr(rew, err)
+		eq(t, exp, rew.Header().Get(`Allow`))
+	}
+
+	test(`PUT, PATCH`, `/one`)
+	test(`GET, HEAD, POST`, `/two`)
+}
+
+func TestRou_Redact(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Sta(`/one`).Sub(func(rou Rou) {
+			rou.Exa(`/one/two`).Get().Func(reachableFunc)
+		})
+	}
+	redacted := func(rou Rou) { route(rou.Redact()) }
+
+	_, err := tRoute(tReq(http.MethodGet, `/one/secret`), redacted)
+	eq(t, `[rout] routing error (HTTP status 404): no such endpoint`, err.Error())
+
+	var val ErrRoute
+	eq(t, true, errors.As(err, &val))
+	eq(t, http.MethodGet, val.Method)
+	eq(t, `/one/secret`, val.Path)
+
+	rew := ht.NewRecorder()
+	WriteErrJSON(rew, err)
+	eq(t, `{"error":{"status":404,"message":"no such endpoint"}}`+"\n", rew.Body.String())
+
+	_, err = tRoute(tReq(http.MethodPost, `/one/two`), redacted)
+	eq(t, `[rout] routing error (HTTP status 405): method not allowed`, err.Error())
+
+	_, err = tRoute(tReq(http.MethodGet, `/one/secret`), route)
+	eq(t, NotFound(http.MethodGet, `/one/secret`).Error(), err.Error())
+}
+
+func TestRou_StackTraces(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Sta(`/one`).Sub(func(rou Rou) {
+			rou.Exa(`/one/three`).Get().Func(reachableFunc)
+		})
+	}
+
+	_, err := tRoute(tReq(http.MethodGet, `/one/two`), route)
+	var val ErrRoute
+	eq(t, true, errors.As(err, &val))
+	eq(t, ``, val.StackTrace())
+
+	_, err = tRoute(tReq(http.MethodGet, `/one/two`), func(rou Rou) { route(rou.StackTraces()) })
+	eq(t, true, errors.As(err, &val))
+	eq(t, true, strings.Contains(val.StackTrace(), `rout.Rou.Sub`))
+	eq(t, true, strings.Contains(val.StackTrace(), `TestRou_StackTraces`))
+	eq(t, false, strings.Contains(val.StackTrace(), `runtime.`))
+	eq(t, NotFound(http.MethodGet, `/one/two`).Error(), val.Error())
+
+	_, err = tRoute(tReq(http.MethodGet, `/one`), func(rou Rou) {
+		rou.Try().Exa(`/one`).Func(func(hrew, hreq) { panic(`two`) })
+	})
+	var pan ErrPanic
+	eq(t, true, errors.As(err, &pan))
+	eq(t, true, strings.Contains(pan.StackTrace(), `TestRou_StackTraces`))
+}
+
+func TestRou_ErrWriter(t *testing.T) {
+	route := func(rou Rou) { rou.Exa(`/one`).Get().Func(reachableFunc) }
+
+	custom := func(rew hrew, _ hreq, err error) {
+		rew.WriteHeader(ErrStatusFallback(err))
+		_, _ = io.WriteString(rew, `custom`)
+	}
+
+	rew := ht.NewRecorder()
+	MakeRou(rew, tReq(http.MethodGet, `/two`)).ErrWriter(custom).Serve(route)
+	eq(t, http.StatusNotFound, rew.Code)
+	eq(t, `custom`, rew.Body.String())
+
+	rew = ht.NewRecorder()
+	MakeRou(rew, tReq(http.MethodGet, `/one`)).ErrWriter(custom).Serve(route)
+	eq(t, 201, rew.Code)
+	eq(t, ``, rew.Body.String())
+
+	eq(t, true, DefaultErrWriter() == nil)
+	SetDefaultErrWriter(custom)
+	defer SetDefaultErrWriter(nil)
+	eq(t, true, DefaultErrWriter() != nil)
+
+	rew = ht.NewRecorder()
+	RouFunc(route).ServeHTTP(rew, tReq(http.MethodGet, `/two`))
+	eq(t, `custom`, rew.Body.String())
+
+	rew = ht.NewRecorder()
+	Server{Routes: route}.ServeHTTP(rew, tReq(http.MethodGet, `/two`))
+	eq(t, `custom`, rew.Body.String())
+}
+
+func TestRou_Suggest(t *testing.T) {
+	route := func(rou Rou) {
+		rou.Sta(`/api`).Sub(func(rou Rou) {
+			rou.Pat(`/api/articles/{}`).Get().Func(reachableFunc)
+			rou.Exa(`/api/articles`).Get().Func(reachableFunc)
+			rou.Exa(`/api/articles`).Post().Func(reachableFunc)
+			rou.Exa(`/api/users`).Get().Func(reachableFunc)
+			rou.Exa(`/api/other/path/far/away`).Func(reachableFunc)
+		})
+	}
+	suggest := func(meth, path string) error {
+		return MakeRou(ht.NewRecorder(), tReq(meth, path)).Suggest().Route(route)
+	}
+
+	err := suggest(http.MethodGet, `/api/article`)
+
+	var val ErrRoute
+	eq(t, true, errors.As(err, &val))
+	eq(t, `GET /api/articles, POST /api/articles, GET /api/articles/{}`, val.Suggest)
+	eq(
 		t,
-		`[rout] unable to convert match "sta" for route "/two/sta" "POST" to OAS pattern`,
-		func() { Visit(routeSta, vis) },
+		NotFound(http.MethodGet, `/api/article`).Error()+`; did you mean: GET /api/articles, POST /api/articles, GET /api/articles/{}`,
+		err.Error(),
 	)
+
+	_, err = tRoute(tReq(http.MethodGet, `/api/article`), route)
+	eq(t, NotFound(http.MethodGet, `/api/article`).Error(), err.Error())
+
+	_, err = tRoute(tReq(http.MethodGet, `/api/article`), func(rou Rou) { route(rou.Suggest()) })
+	eq(t, NotFound(http.MethodGet, `/api/article`).Error(), err.Error())
+
+	err = suggest(http.MethodPut, `/api/users`)
+	eq(t, MethodNotAllowed(http.MethodPut, `/api/users`).Error(), err.Error())
+
+	var count int
+	counted := func(rou Rou) {
+		count++
+		route(rou)
+	}
+	for range iter(3) {
+		err = MakeRou(ht.NewRecorder(), tReq(http.MethodGet, `/api/article`)).Suggest().Route(counted)
+		eq(t, true, errors.As(err, &val))
+		eq(t, `GET /api/articles, POST /api/articles, GET /api/articles/{}`, val.Suggest)
+	}
+	eq(t, 4, count)
+}
+
+func TestRou_Suggest_panic(t *testing.T) {
+	route := func(rou Rou) {
+		_ = rou.Req.TLS.ServerName
+		rou.Exa(`/one`).Get().Func(reachableFunc)
+	}
+
+	req := tReq(http.MethodGet, `/two`)
+	req.TLS = &tls.ConnectionState{ServerName: `example.com`}
+
+	err := MakeRou(ht.NewRecorder(), req).Suggest().Route(route)
+	eq(t, NotFound(http.MethodGet, `/two`).Error(), err.Error())
+}
+
+func TestEditDist(t *testing.T) {
+	eq(t, 0, editDist(``, ``))
+	eq(t, 3, editDist(``, `one`))
+	eq(t, 3, editDist(`one`, ``))
+	eq(t, 0, editDist(`one`, `one`))
+	eq(t, 1, editDist(`one`, `ones`))
+	eq(t, 3, editDist(`kitten`, `sitting`))
 }